@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	websocketText   = 0x1
+	websocketClose  = 0x8
+	websocketPing   = 0x9
+	websocketPong   = 0xA
+	websocketFinBit = 0x80
+
+	maxWebsocketFrame = 1024 * 1024
+)
+
+// longConnections serves connections that stay open until the client or the
+// app closes them: a streaming HTTP response on /_stream and a websocket on
+// /_websocket. Both write a line every interval, and the websocket also
+// echoes what it receives. Close ends them all so shutdown does not wait on
+// them.
+type longConnections struct {
+	interval time.Duration
+	stats    *stats
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+func newLongConnections(interval time.Duration, appStats *stats) *longConnections {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &longConnections{
+		interval: interval,
+		stats:    appStats,
+		closing:  make(chan struct{}),
+	}
+}
+
+func (l *longConnections) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/_stream", l.serveStream)
+	mux.HandleFunc("/_websocket", l.serveWebsocket)
+}
+
+func (l *longConnections) Close() {
+	l.closeOnce.Do(func() { close(l.closing) })
+}
+
+func (l *longConnections) opened() func() {
+	atomic.AddInt64(&l.stats.ConnectionsAccepted, 1)
+	atomic.AddInt64(&l.stats.ConnectionsOpen, 1)
+	return func() { atomic.AddInt64(&l.stats.ConnectionsOpen, -1) }
+}
+
+func (l *longConnections) serveStream(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	defer l.opened()()
+
+	rw.Header().Set("Content-Type", "text/plain")
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for sequence := 1; ; sequence++ {
+		if _, err := fmt.Fprintf(rw, "%s seq=%d\n", time.Now().Format(time.RFC3339Nano), sequence); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-l.closing:
+			return
+		}
+	}
+}
+
+func (l *longConnections) serveWebsocket(rw http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(rw, "expected a websocket upgrade", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		http.Error(rw, "websockets are not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	defer l.opened()()
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+	if err := buf.Flush(); err != nil {
+		return
+	}
+
+	var writeMutex sync.Mutex
+	write := func(header byte, payload []byte) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		if err := writeWebsocketFrame(buf.Writer, header, payload); err != nil {
+			return err
+		}
+		return buf.Flush()
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			header, payload, err := readWebsocketFrame(buf.Reader)
+			if err != nil {
+				return
+			}
+			switch header &^ websocketFinBit {
+			case websocketClose:
+				write(websocketFinBit|websocketClose, payload)
+				return
+			case websocketPing:
+				err = write(websocketFinBit|websocketPong, payload)
+			case websocketPong:
+			default:
+				err = write(header, payload)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for sequence := 1; ; sequence++ {
+		select {
+		case <-ticker.C:
+			line := fmt.Sprintf("%s seq=%d", time.Now().Format(time.RFC3339Nano), sequence)
+			if err := write(websocketFinBit|websocketText, []byte(line)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-l.closing:
+			write(websocketFinBit|websocketClose, []byte{0x03, 0xE9}) // 1001 going away
+			return
+		}
+	}
+}
+
+// readWebsocketFrame returns the first byte of the frame, holding the FIN bit
+// and opcode, and its unmasked payload.
+func readWebsocketFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxWebsocketFrame {
+		return 0, nil, errors.New("websocket frame too large")
+	}
+
+	var mask [4]byte
+	masked := head[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return head[0], payload, nil
+}
+
+// writeWebsocketFrame writes an unmasked frame, as servers must.
+func writeWebsocketFrame(w io.Writer, header byte, payload []byte) error {
+	frame := []byte{header}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// maskedFrame builds a frame the way a client must send it.
+func maskedFrame(header byte, payload []byte) []byte {
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{header, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+var _ = Describe("websocket frames", func() {
+	roundTrip := func(payload []byte) []byte {
+		var buf bytes.Buffer
+		Expect(writeWebsocketFrame(&buf, websocketFinBit|websocketText, payload)).To(Succeed())
+
+		header, read, err := readWebsocketFrame(bufio.NewReader(&buf))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header).To(Equal(byte(websocketFinBit | websocketText)))
+		return read
+	}
+
+	It("round-trips payloads of every length encoding", func() {
+		for _, length := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+			payload := bytes.Repeat([]byte{'x'}, length)
+			Expect(roundTrip(payload)).To(Equal(payload), fmt.Sprintf("length %d", length))
+		}
+	})
+
+	It("uses the 16 and 64 bit extended lengths", func() {
+		var buf bytes.Buffer
+		Expect(writeWebsocketFrame(&buf, websocketText, make([]byte, 126))).To(Succeed())
+		Expect(buf.Bytes()[1:4]).To(Equal([]byte{126, 0, 126}))
+
+		buf.Reset()
+		Expect(writeWebsocketFrame(&buf, websocketText, make([]byte, 0x10000))).To(Succeed())
+		Expect(buf.Bytes()[1:10]).To(Equal([]byte{127, 0, 0, 0, 0, 0, 1, 0, 0}))
+	})
+
+	It("unmasks client frames", func() {
+		header, payload, err := readWebsocketFrame(bufio.NewReader(bytes.NewReader(maskedFrame(websocketFinBit|websocketText, []byte("hello")))))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header).To(Equal(byte(websocketFinBit | websocketText)))
+		Expect(payload).To(Equal([]byte("hello")))
+	})
+
+	It("rejects frames larger than the limit", func() {
+		frame := []byte{websocketFinBit | websocketText, 127, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(frame[2:], maxWebsocketFrame+1)
+		_, _, err := readWebsocketFrame(bufio.NewReader(bytes.NewReader(frame)))
+		Expect(err).To(MatchError("websocket frame too large"))
+	})
+
+	It("fails on a truncated frame", func() {
+		frame := maskedFrame(websocketFinBit|websocketText, []byte("hello"))
+		_, _, err := readWebsocketFrame(bufio.NewReader(bytes.NewReader(frame[:len(frame)-1])))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("websocket connections", func() {
+	var (
+		connections *longConnections
+		appStats    *stats
+		server      *httptest.Server
+	)
+
+	BeforeEach(func() {
+		appStats = newStats()
+		connections = newLongConnections(time.Hour, appStats)
+		mux := http.NewServeMux()
+		connections.Register(mux)
+		server = httptest.NewServer(mux)
+	})
+
+	AfterEach(func() {
+		connections.Close()
+		server.Close()
+	})
+
+	// dial performs the opening handshake with the example key from RFC 6455.
+	dial := func() (net.Conn, *bufio.Reader, *http.Response) {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+		Expect(err).NotTo(HaveOccurred())
+
+		fmt.Fprintf(conn, "GET /_websocket HTTP/1.1\r\nHost: stress-app\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return conn, reader, resp
+	}
+
+	It("completes the opening handshake", func() {
+		conn, _, resp := dial()
+		defer conn.Close()
+
+		Expect(resp.StatusCode).To(Equal(http.StatusSwitchingProtocols))
+		Expect(resp.Header.Get("Upgrade")).To(Equal("websocket"))
+		Expect(resp.Header.Get("Sec-WebSocket-Accept")).To(Equal("s3pPLMBiTxaQ9kYGzzhZRbK+xOo="))
+	})
+
+	It("rejects requests that are not upgrades", func() {
+		resp, err := http.Get(server.URL + "/_websocket")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("echoes messages and answers pings", func() {
+		conn, reader, _ := dial()
+		defer conn.Close()
+
+		_, err := conn.Write(maskedFrame(websocketFinBit|websocketText, []byte("hello")))
+		Expect(err).NotTo(HaveOccurred())
+		header, payload, err := readWebsocketFrame(reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header).To(Equal(byte(websocketFinBit | websocketText)))
+		Expect(payload).To(Equal([]byte("hello")))
+
+		_, err = conn.Write(maskedFrame(websocketFinBit|websocketPing, []byte("ping")))
+		Expect(err).NotTo(HaveOccurred())
+		header, payload, err = readWebsocketFrame(reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header).To(Equal(byte(websocketFinBit | websocketPong)))
+		Expect(payload).To(Equal([]byte("ping")))
+	})
+
+	It("answers a close frame and counts the connection as closed", func() {
+		conn, reader, _ := dial()
+		defer conn.Close()
+		Eventually(func() int64 { return atomic.LoadInt64(&appStats.ConnectionsOpen) }).Should(Equal(int64(1)))
+
+		_, err := conn.Write(maskedFrame(websocketFinBit|websocketClose, []byte{0x03, 0xE8}))
+		Expect(err).NotTo(HaveOccurred())
+		header, payload, err := readWebsocketFrame(reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header).To(Equal(byte(websocketFinBit | websocketClose)))
+		Expect(payload).To(Equal([]byte{0x03, 0xE8}))
+
+		Eventually(func() int64 { return atomic.LoadInt64(&appStats.ConnectionsOpen) }).Should(BeZero())
+		Expect(atomic.LoadInt64(&appStats.ConnectionsAccepted)).To(Equal(int64(1)))
+	})
+
+	It("sends a going away close frame when the app shuts down", func() {
+		conn, reader, _ := dial()
+		defer conn.Close()
+		Eventually(func() int64 { return atomic.LoadInt64(&appStats.ConnectionsOpen) }).Should(Equal(int64(1)))
+
+		connections.Close()
+		header, payload, err := readWebsocketFrame(reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header).To(Equal(byte(websocketFinBit | websocketClose)))
+		Expect(payload).To(Equal([]byte{0x03, 0xE9}))
+	})
+})
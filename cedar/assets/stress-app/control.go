@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// control lets a driver change the app's behaviour at runtime through the
+// /_control endpoints. Every control request must carry the CONTROL_TOKEN in
+// an `Authorization: Bearer` header; without a token the endpoints are
+// disabled.
+type control struct {
+	token string
+
+	logs      *rateLoop
+	requests  *rateLoop
+	cpu       *cpuLoad
+	memory    *memoryLoad
+	responder *responder
+	health    *health
+	crash     func(mode string)
+}
+
+// controlSettings is both the current state reported by GET /_control and the
+// body accepted by PUT /_control, where missing fields are left unchanged.
+type controlSettings struct {
+	LogsPerSecond         *float64 `json:"logs_per_second,omitempty"`
+	RequestsPerSecond     *float64 `json:"requests_per_second,omitempty"`
+	CPULoadPercent        *float64 `json:"cpu_load_percent,omitempty"`
+	MemoryBaselineMB      *int     `json:"memory_baseline_mb,omitempty"`
+	MemoryLeakMBPerMinute *float64 `json:"memory_leak_mb_per_minute,omitempty"`
+	ResponseDelay         *string  `json:"response_delay,omitempty"`
+	ResponseDelayMax      *string  `json:"response_delay_max,omitempty"`
+	ResponseDistribution  *string  `json:"response_delay_distribution,omitempty"`
+	ResponseErrorPercent  *float64 `json:"response_error_percent,omitempty"`
+}
+
+func (c *control) Register(mux *http.ServeMux) {
+	mux.Handle("/_control", c.authorize(http.HandlerFunc(c.serveSettings)))
+	mux.Handle("/_control/crash", c.authorize(http.HandlerFunc(c.serveCrash)))
+	mux.Handle("/_control/health", c.authorize(c.health))
+}
+
+func (c *control) authorize(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if c.token == "" {
+			http.Error(rw, "control API disabled: CONTROL_TOKEN is not set", http.StatusForbidden)
+			return
+		}
+
+		expected := []byte("Bearer " + c.token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+func (c *control) settings() controlSettings {
+	logsPerSecond := c.logs.Rate()
+	requestsPerSecond := c.requests.Rate()
+	cpuLoadPercent := c.cpu.Percent()
+	memoryBaselineMB, memoryLeakMBPerMinute := c.memory.Targets()
+	response := c.responder.Settings()
+	responseDelay := response.Delay.String()
+	responseDelayMax := response.DelayMax.String()
+
+	return controlSettings{
+		LogsPerSecond:         &logsPerSecond,
+		RequestsPerSecond:     &requestsPerSecond,
+		CPULoadPercent:        &cpuLoadPercent,
+		MemoryBaselineMB:      &memoryBaselineMB,
+		MemoryLeakMBPerMinute: &memoryLeakMBPerMinute,
+		ResponseDelay:         &responseDelay,
+		ResponseDelayMax:      &responseDelayMax,
+		ResponseDistribution:  &response.DelayDistribution,
+		ResponseErrorPercent:  &response.ErrorPercent,
+	}
+}
+
+func (c *control) apply(settings controlSettings) error {
+	response := c.responder.Settings()
+	if settings.ResponseDelay != nil {
+		delay, err := time.ParseDuration(*settings.ResponseDelay)
+		if err != nil {
+			return err
+		}
+		response.Delay = delay
+	}
+	if settings.ResponseDelayMax != nil {
+		delayMax, err := time.ParseDuration(*settings.ResponseDelayMax)
+		if err != nil {
+			return err
+		}
+		response.DelayMax = delayMax
+	}
+	if settings.ResponseDistribution != nil {
+		if err := validateDelayDistribution(*settings.ResponseDistribution); err != nil {
+			return err
+		}
+		response.DelayDistribution = *settings.ResponseDistribution
+	}
+	if settings.ResponseErrorPercent != nil {
+		if err := validateErrorPercent(*settings.ResponseErrorPercent); err != nil {
+			return err
+		}
+		response.ErrorPercent = *settings.ResponseErrorPercent
+	}
+	c.responder.Set(response)
+	if settings.LogsPerSecond != nil {
+		c.logs.Set(*settings.LogsPerSecond)
+	}
+	if settings.RequestsPerSecond != nil {
+		c.requests.Set(*settings.RequestsPerSecond)
+	}
+	if settings.CPULoadPercent != nil {
+		c.cpu.Set(*settings.CPULoadPercent)
+	}
+	if settings.MemoryBaselineMB != nil || settings.MemoryLeakMBPerMinute != nil {
+		baselineMB, leakMBPerMinute := c.memory.Targets()
+		if settings.MemoryBaselineMB != nil {
+			baselineMB = *settings.MemoryBaselineMB
+		}
+		if settings.MemoryLeakMBPerMinute != nil {
+			leakMBPerMinute = *settings.MemoryLeakMBPerMinute
+		}
+		c.memory.Set(baselineMB, leakMBPerMinute)
+	}
+	return nil
+}
+
+func (c *control) serveSettings(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		var settings controlSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.apply(settings); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Println("Applied control settings")
+	default:
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(c.settings())
+}
+
+// serveCrash crashes in the mode given by the `mode` query parameter, or the
+// configured CRASH_MODE.
+func (c *control) serveCrash(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode != "" {
+		if err := validateCrashMode(mode); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	rw.WriteHeader(http.StatusAccepted)
+	if flusher, ok := rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	go c.crash(mode)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("control", func() {
+	var (
+		ctl       *control
+		mux       *http.ServeMux
+		responses *responder
+		crashes   chan string
+	)
+
+	BeforeEach(func() {
+		responses = newResponder([]byte("hello"), 0, responseSettings{
+			Delay:             time.Second,
+			DelayDistribution: fixedDelay,
+		})
+		crashes = make(chan string, 1)
+		ctl = &control{
+			token:     "secret",
+			logs:      newRateLoop(1),
+			requests:  newRateLoop(2),
+			cpu:       newCPULoad(0, 0),
+			memory:    newMemoryLoad(0, 0, 0, 0, 0),
+			responder: responses,
+			health:    newHealth(httpFailureMode, 0),
+			crash:     func(mode string) { crashes <- mode },
+		}
+		mux = http.NewServeMux()
+		ctl.Register(mux)
+	})
+
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	Describe("authorization", func() {
+		It("is disabled without a token", func() {
+			ctl.token = ""
+			Expect(request("GET", "/_control", "", "").Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("rejects a missing or wrong token", func() {
+			Expect(request("GET", "/_control", "", "").Code).To(Equal(http.StatusUnauthorized))
+			Expect(request("GET", "/_control", "wrong", "").Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("/_control", func() {
+		It("reports the current settings", func() {
+			recorder := request("GET", "/_control", "secret", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var settings controlSettings
+			Expect(json.NewDecoder(recorder.Body).Decode(&settings)).To(Succeed())
+			Expect(*settings.LogsPerSecond).To(Equal(1.0))
+			Expect(*settings.RequestsPerSecond).To(Equal(2.0))
+			Expect(*settings.ResponseDelay).To(Equal("1s"))
+		})
+
+		It("applies only the fields that are set", func() {
+			recorder := request("PUT", "/_control", "secret", `{"requests_per_second": 5, "response_delay": "250ms", "memory_leak_mb_per_minute": 3}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(ctl.logs.Rate()).To(Equal(1.0))
+			Expect(ctl.requests.Rate()).To(Equal(5.0))
+			Expect(responses.Settings().Delay).To(Equal(250 * time.Millisecond))
+			Expect(responses.Settings().DelayDistribution).To(Equal(fixedDelay))
+			baselineMB, leakMBPerMinute := ctl.memory.Targets()
+			Expect(baselineMB).To(Equal(0))
+			Expect(leakMBPerMinute).To(Equal(3.0))
+		})
+
+		It("rejects malformed JSON", func() {
+			Expect(request("PUT", "/_control", "secret", `{`).Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("rejects an invalid delay without applying anything", func() {
+			recorder := request("PUT", "/_control", "secret", `{"requests_per_second": 5, "response_delay": "soon"}`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(ctl.requests.Rate()).To(Equal(2.0))
+			Expect(responses.Settings().Delay).To(Equal(time.Second))
+		})
+
+		It("rejects an unknown delay distribution without applying anything", func() {
+			recorder := request("PUT", "/_control", "secret", `{"response_delay": "5s", "response_delay_distribution": "normal"}`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Body.String()).To(ContainSubstring("unknown delay distribution: normal"))
+			Expect(responses.Settings().Delay).To(Equal(time.Second))
+		})
+
+		It("rejects an error percent outside 0 to 100 without applying anything", func() {
+			for _, percent := range []string{"-1", "100.5"} {
+				recorder := request("PUT", "/_control", "secret", `{"response_delay": "5s", "response_error_percent": `+percent+`}`)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body.String()).To(ContainSubstring("between 0 and 100"))
+			}
+			Expect(responses.Settings().Delay).To(Equal(time.Second))
+			Expect(responses.Settings().ErrorPercent).To(BeZero())
+
+			Expect(request("PUT", "/_control", "secret", `{"response_error_percent": 100}`).Code).To(Equal(http.StatusOK))
+			Expect(responses.Settings().ErrorPercent).To(Equal(100.0))
+		})
+
+		It("rejects other methods", func() {
+			Expect(request("DELETE", "/_control", "secret", "").Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("/_control/crash", func() {
+		It("crashes in the requested mode", func() {
+			Expect(request("POST", "/_control/crash?mode=exit", "secret", "").Code).To(Equal(http.StatusAccepted))
+			Eventually(crashes).Should(Receive(Equal("exit")))
+		})
+
+		It("rejects an unknown mode", func() {
+			Expect(request("POST", "/_control/crash?mode=explode", "secret", "").Code).To(Equal(http.StatusBadRequest))
+			Consistently(crashes).ShouldNot(Receive())
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+const (
+	panicCrash = "panic"
+	exitCrash  = "exit"
+	oomCrash   = "oom"
+	hangCrash  = "hang"
+)
+
+func validateCrashMode(mode string) error {
+	switch mode {
+	case panicCrash, exitCrash, oomCrash, hangCrash:
+		return nil
+	default:
+		return fmt.Errorf("unknown crash mode: %s", mode)
+	}
+}
+
+// crasher takes the app down in one of several ways so Diego's crash
+// handling can be exercised:
+//
+//   - panic exits with a stack trace and status 2.
+//   - exit calls os.Exit with the configured code.
+//   - oom allocates memory until the container is killed.
+//   - hang stops answering app requests and blocks the caller forever. The
+//     port stays open unless the hang happens at startup.
+type crasher struct {
+	mode     string
+	exitCode int
+	hang     func()
+}
+
+func newCrasher(mode string, exitCode int, hang func()) *crasher {
+	return &crasher{mode: mode, exitCode: exitCode, hang: hang}
+}
+
+// Crash crashes in the given mode, or the configured one if mode is empty.
+func (c *crasher) Crash(mode string) {
+	if mode == "" {
+		mode = c.mode
+	}
+	log.Printf("Crashing with mode %s\n", mode)
+
+	switch mode {
+	case exitCrash:
+		os.Exit(c.exitCode)
+	case oomCrash:
+		var hoard [][]byte
+		for {
+			hoard = append(hoard, allocate(megabyte))
+		}
+	case hangCrash:
+		c.hang()
+		select {}
+	default:
+		panic("freak out")
+	}
+}
+
+// crashSeed seeds the crash schedule of one instance. A pinned RANDOM_SEED,
+// already offset by the instance index, is used as is so that schedules repeat
+// across runs. Otherwise CF_INSTANCE_GUID, which is new for every container, is
+// mixed in so that restarts do not all crash after the same delay.
+func crashSeed(seed int64, pinned bool, instanceGUID string) int64 {
+	if !pinned && instanceGUID != "" {
+		hash := fnv.New64a()
+		hash.Write([]byte(instanceGUID))
+		seed ^= int64(hash.Sum64())
+	}
+	return seed
+}
+
+// crashSchedule picks how long after startup to crash. crashAfter plus or
+// minus up to jitter takes precedence; otherwise a whole number of seconds
+// between minSeconds and maxSeconds inclusive is picked. It returns false if
+// no crash is configured.
+func crashSchedule(random *rand.Rand, crashAfter, jitter time.Duration, minSeconds, maxSeconds int) (time.Duration, bool) {
+	if crashAfter > 0 {
+		if jitter > 0 {
+			crashAfter += time.Duration(random.Int63n(2*int64(jitter)+1)) - jitter
+		}
+		if crashAfter < 0 {
+			crashAfter = 0
+		}
+		return crashAfter, true
+	}
+
+	if minSeconds > 0 && maxSeconds > 0 {
+		if maxSeconds < minSeconds {
+			minSeconds, maxSeconds = maxSeconds, minSeconds
+		}
+		seconds := minSeconds + random.Intn(maxSeconds-minSeconds+1)
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	return 0, false
+}
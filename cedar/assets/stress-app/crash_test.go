@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("crashSchedule", func() {
+	var random *rand.Rand
+
+	BeforeEach(func() {
+		random = rand.New(rand.NewSource(42))
+	})
+
+	It("does not crash when nothing is configured", func() {
+		_, scheduled := crashSchedule(random, 0, 0, 0, 0)
+		Expect(scheduled).To(BeFalse())
+
+		_, scheduled = crashSchedule(random, 0, 0, 30, 0)
+		Expect(scheduled).To(BeFalse())
+	})
+
+	It("crashes after exactly crashAfter without jitter", func() {
+		delay, scheduled := crashSchedule(random, time.Minute, 0, 10, 20)
+		Expect(scheduled).To(BeTrue())
+		Expect(delay).To(Equal(time.Minute))
+	})
+
+	It("keeps the jittered delay within crashAfter plus or minus jitter", func() {
+		for i := 0; i < 100; i++ {
+			delay, scheduled := crashSchedule(random, time.Minute, 10*time.Second, 0, 0)
+			Expect(scheduled).To(BeTrue())
+			Expect(delay).To(BeNumerically("~", time.Minute, 10*time.Second))
+		}
+	})
+
+	It("never returns a negative delay", func() {
+		for i := 0; i < 100; i++ {
+			delay, _ := crashSchedule(random, time.Second, time.Minute, 0, 0)
+			Expect(delay).To(BeNumerically(">=", 0))
+		}
+	})
+
+	It("picks whole seconds between the minimum and maximum inclusive", func() {
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 200; i++ {
+			delay, scheduled := crashSchedule(random, 0, 0, 3, 5)
+			Expect(scheduled).To(BeTrue())
+			seen[delay] = true
+		}
+		Expect(seen).To(Equal(map[time.Duration]bool{3 * time.Second: true, 4 * time.Second: true, 5 * time.Second: true}))
+	})
+
+	It("swaps a reversed minimum and maximum", func() {
+		for i := 0; i < 50; i++ {
+			delay, _ := crashSchedule(random, 0, 0, 5, 3)
+			Expect(delay).To(BeNumerically(">=", 3*time.Second))
+			Expect(delay).To(BeNumerically("<=", 5*time.Second))
+		}
+	})
+
+	It("is reproducible for a given seed", func() {
+		first, _ := crashSchedule(rand.New(rand.NewSource(7)), 0, 0, 1, 1000)
+		second, _ := crashSchedule(rand.New(rand.NewSource(7)), 0, 0, 1, 1000)
+		Expect(first).To(Equal(second))
+	})
+})
+
+var _ = Describe("crashSeed", func() {
+	It("ignores the instance GUID when the seed is pinned", func() {
+		Expect(crashSeed(42, true, "guid-1")).To(Equal(int64(42)))
+		Expect(crashSeed(42, true, "guid-1")).To(Equal(crashSeed(42, true, "guid-2")))
+	})
+
+	It("gives the same schedule for the same pinned seed and instance index", func() {
+		instanceIndex := int64(3)
+		schedule := func(instanceGUID string) []time.Duration {
+			random := rand.New(rand.NewSource(crashSeed(42+instanceIndex, true, instanceGUID)))
+			delays := []time.Duration{}
+			for i := 0; i < 5; i++ {
+				delay, _ := crashSchedule(random, 0, 0, 1, 1000)
+				delays = append(delays, delay)
+			}
+			return delays
+		}
+		Expect(schedule("guid-from-first-run")).To(Equal(schedule("guid-from-second-run")))
+	})
+
+	It("varies with the instance GUID when the seed is not pinned", func() {
+		Expect(crashSeed(42, false, "guid-1")).NotTo(Equal(crashSeed(42, false, "guid-2")))
+		Expect(crashSeed(42, false, "guid-1")).To(Equal(crashSeed(42, false, "guid-1")))
+	})
+
+	It("uses the seed as is without an instance GUID", func() {
+		Expect(crashSeed(42, false, "")).To(Equal(int64(42)))
+	})
+})
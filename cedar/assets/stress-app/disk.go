@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const throughputFileMB = 16
+
+// maxDiskRate keeps the interval between two disk writes at a nanosecond or
+// more.
+const maxDiskRate = float64(time.Second)
+
+// diskLoad exercises the container's disk quota. It fills a fixed percentage
+// of the quota once at startup, continuously rewrites a small file at a
+// target throughput, and creates and removes small files at a target rate.
+type diskLoad struct {
+	dir string
+
+	quotaMB          int
+	fillPercent      float64
+	writeMBPerSecond float64
+	churnPerSecond   float64
+	churnFileKB      int
+	churnMaxFiles    int
+
+	done chan struct{}
+}
+
+func newDiskLoad(dir string, quotaMB int, fillPercent, writeMBPerSecond, churnPerSecond float64, churnFileKB, churnMaxFiles int) *diskLoad {
+	return &diskLoad{
+		dir:              dir,
+		quotaMB:          quotaMB,
+		fillPercent:      fillPercent,
+		writeMBPerSecond: writeMBPerSecond,
+		churnPerSecond:   churnPerSecond,
+		churnFileKB:      churnFileKB,
+		churnMaxFiles:    churnMaxFiles,
+		done:             make(chan struct{}),
+	}
+}
+
+func (d *diskLoad) validate() error {
+	if d.fillPercent < 0 || d.fillPercent > 100 {
+		return fmt.Errorf("DISK_FILL_PERCENT must be between 0 and 100, got %g", d.fillPercent)
+	}
+	if d.writeMBPerSecond < 0 || d.writeMBPerSecond > maxDiskRate {
+		return fmt.Errorf("DISK_WRITE_MB_PER_SECOND must be between 0 and %g, got %g", maxDiskRate, d.writeMBPerSecond)
+	}
+	if d.churnPerSecond < 0 || d.churnPerSecond > maxDiskRate {
+		return fmt.Errorf("DISK_FILES_PER_SECOND must be between 0 and %g, got %g", maxDiskRate, d.churnPerSecond)
+	}
+	if d.churnFileKB <= 0 {
+		return fmt.Errorf("DISK_FILE_KB must be positive, got %d", d.churnFileKB)
+	}
+	if d.churnMaxFiles < 0 {
+		return fmt.Errorf("DISK_MAX_FILES must not be negative, got %d", d.churnMaxFiles)
+	}
+	return nil
+}
+
+func (d *diskLoad) Enabled() bool {
+	return d.fillPercent > 0 || d.writeMBPerSecond > 0 || d.churnPerSecond > 0
+}
+
+// Stop stops the write and churn loops started by Run.
+func (d *diskLoad) Stop() {
+	close(d.done)
+}
+
+func (d *diskLoad) Run() {
+	if !d.Enabled() {
+		return
+	}
+
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		log.Printf("failed to create disk load dir: %s\n", err)
+		return
+	}
+
+	if d.fillPercent > 0 {
+		d.fill()
+	}
+	if d.writeMBPerSecond > 0 {
+		go d.write()
+	}
+	if d.churnPerSecond > 0 {
+		go d.churn()
+	}
+}
+
+func (d *diskLoad) fill() {
+	if d.quotaMB <= 0 {
+		log.Println("cannot fill disk: quota unknown, set DISK_QUOTA_MB")
+		return
+	}
+
+	fillMB := int(float64(d.quotaMB) * d.fillPercent / 100)
+	log.Printf("Filling %dMB of the %dMB disk quota\n", fillMB, d.quotaMB)
+
+	f, err := os.Create(filepath.Join(d.dir, "fill"))
+	if err != nil {
+		log.Printf("failed to create fill file: %s\n", err)
+		return
+	}
+	defer f.Close()
+
+	chunk := allocate(megabyte)
+	for i := 0; i < fillMB; i++ {
+		if _, err := f.Write(chunk); err != nil {
+			log.Printf("failed to fill disk after %dMB: %s\n", i, err)
+			return
+		}
+	}
+	if err := f.Sync(); err != nil {
+		log.Printf("failed to sync fill file: %s\n", err)
+	}
+}
+
+func (d *diskLoad) write() {
+	f, err := os.Create(filepath.Join(d.dir, "throughput"))
+	if err != nil {
+		log.Printf("failed to create throughput file: %s\n", err)
+		return
+	}
+	defer f.Close()
+
+	chunk := allocate(megabyte)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / d.writeMBPerSecond))
+	defer ticker.Stop()
+	for offset := 0; ; offset = (offset + 1) % throughputFileMB {
+		select {
+		case <-ticker.C:
+		case <-d.done:
+			return
+		}
+		if _, err := f.WriteAt(chunk, int64(offset*megabyte)); err != nil {
+			log.Printf("failed to write throughput file: %s\n", err)
+			continue
+		}
+		if err := f.Sync(); err != nil {
+			log.Printf("failed to sync throughput file: %s\n", err)
+		}
+	}
+}
+
+func (d *diskLoad) churn() {
+	chunk := allocate(d.churnFileKB * 1024)
+	files := []string{}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / d.churnPerSecond))
+	defer ticker.Stop()
+	for sequence := 0; ; sequence++ {
+		select {
+		case <-ticker.C:
+		case <-d.done:
+			return
+		}
+		path := filepath.Join(d.dir, fmt.Sprintf("churn-%d", sequence))
+		if err := ioutil.WriteFile(path, chunk, 0644); err != nil {
+			log.Printf("failed to write churn file: %s\n", err)
+			continue
+		}
+		files = append(files, path)
+
+		for len(files) > d.churnMaxFiles {
+			if err := os.Remove(files[0]); err != nil {
+				log.Printf("failed to remove churn file: %s\n", err)
+			}
+			files = files[1:]
+		}
+	}
+}
+
+// diskQuotaMB reads the disk limit Diego reports in VCAP_APPLICATION.
+func diskQuotaMB(vcapApplication string) int {
+	var app struct {
+		Limits struct {
+			Disk int `json:"disk"`
+		} `json:"limits"`
+	}
+	if err := json.Unmarshal([]byte(vcapApplication), &app); err != nil {
+		return 0
+	}
+	return app.Limits.Disk
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("diskLoad", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "stress-app-disk")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	size := func(name string) func() int64 {
+		return func() int64 {
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				return 0
+			}
+			return info.Size()
+		}
+	}
+
+	It("is disabled without a fill, write or churn rate", func() {
+		disk := newDiskLoad(dir, 100, 0, 0, 0, 4, 100)
+		Expect(disk.Enabled()).To(BeFalse())
+		disk.Run()
+
+		files, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+
+	It("fills the percentage of the quota at startup", func() {
+		disk := newDiskLoad(dir, 10, 50, 0, 0, 4, 100)
+		disk.Run()
+
+		Expect(size("fill")()).To(Equal(int64(5 * megabyte)))
+	})
+
+	It("does not fill the disk when the quota is unknown", func() {
+		disk := newDiskLoad(dir, 0, 50, 0, 0, 4, 100)
+		disk.Run()
+
+		Expect(size("fill")()).To(BeZero())
+	})
+
+	It("rewrites the throughput file at the target rate", func() {
+		disk := newDiskLoad(dir, 0, 0, 100, 0, 4, 100)
+		disk.Run()
+		defer disk.Stop()
+
+		Eventually(size("throughput")).Should(BeNumerically(">=", 2*megabyte))
+		Consistently(size("throughput"), 300*time.Millisecond).Should(BeNumerically("<=", throughputFileMB*megabyte))
+	})
+
+	It("creates and removes files, keeping at most the max number", func() {
+		disk := newDiskLoad(dir, 0, 0, 0, 200, 1, 3)
+		disk.Run()
+		defer disk.Stop()
+
+		Eventually(size("churn-10")).Should(Equal(int64(1024)))
+		files, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(files)).To(BeNumerically("<=", 4))
+		Expect(filepath.Join(dir, "churn-0")).NotTo(BeAnExistingFile())
+	})
+
+	It("stops writing once stopped", func() {
+		disk := newDiskLoad(dir, 0, 0, 0, 200, 1, 1000)
+		disk.Run()
+		Eventually(size("churn-1")).ShouldNot(BeZero())
+		disk.Stop()
+
+		time.Sleep(50 * time.Millisecond)
+		files, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Consistently(func() int {
+			files, _ := ioutil.ReadDir(dir)
+			return len(files)
+		}, 100*time.Millisecond).Should(Equal(len(files)))
+	})
+
+	Describe("validate", func() {
+		It("accepts the defaults", func() {
+			Expect(newDiskLoad(dir, 0, 0, 0, 0, 4, 100).validate()).To(Succeed())
+		})
+
+		It("rejects rates too high to tick at", func() {
+			Expect(newDiskLoad(dir, 0, 0, 2e9, 0, 4, 100).validate()).To(MatchError(ContainSubstring("DISK_WRITE_MB_PER_SECOND")))
+			Expect(newDiskLoad(dir, 0, 0, 0, 2e9, 4, 100).validate()).To(MatchError(ContainSubstring("DISK_FILES_PER_SECOND")))
+		})
+
+		It("rejects negative rates", func() {
+			Expect(newDiskLoad(dir, 0, 0, -1, 0, 4, 100).validate()).To(HaveOccurred())
+			Expect(newDiskLoad(dir, 0, 0, 0, -1, 4, 100).validate()).To(HaveOccurred())
+		})
+
+		It("rejects a fill percentage outside 0 to 100", func() {
+			Expect(newDiskLoad(dir, 100, 101, 0, 0, 4, 100).validate()).To(MatchError(ContainSubstring("DISK_FILL_PERCENT")))
+			Expect(newDiskLoad(dir, 100, -1, 0, 0, 4, 100).validate()).To(HaveOccurred())
+		})
+
+		It("rejects churn files without a size and a negative max number of files", func() {
+			Expect(newDiskLoad(dir, 0, 0, 0, 0, 0, 100).validate()).To(MatchError(ContainSubstring("DISK_FILE_KB")))
+			Expect(newDiskLoad(dir, 0, 0, 0, 0, 4, -1).validate()).To(MatchError(ContainSubstring("DISK_MAX_FILES")))
+		})
+	})
+})
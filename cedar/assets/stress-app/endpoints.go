@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	randomSelection     = "random"
+	roundRobinSelection = "round-robin"
+)
+
+type endpointCounts struct {
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+}
+
+// endpointPool picks the endpoints outbound requests are sent to and counts
+// how each of them responds. The static endpoints are always included; the
+// discovered ones are replaced on every refresh.
+type endpointPool struct {
+	mutex sync.Mutex
+
+	selection  string
+	static     []string
+	discovered []string
+	next       int
+
+	counts map[string]*endpointCounts
+}
+
+func newEndpointPool(static []string, selection string) *endpointPool {
+	return &endpointPool{
+		selection: selection,
+		static:    static,
+		counts:    make(map[string]*endpointCounts),
+	}
+}
+
+// Next returns the endpoint for the next request, or false if there are none.
+func (p *endpointPool) Next() (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	count := len(p.static) + len(p.discovered)
+	if count == 0 {
+		return "", false
+	}
+
+	var i int
+	if p.selection == roundRobinSelection {
+		i = p.next % count
+		p.next = i + 1
+	} else {
+		i = rand.Intn(count)
+	}
+
+	if i < len(p.static) {
+		return p.static[i], true
+	}
+	return p.discovered[i-len(p.static)], true
+}
+
+func (p *endpointPool) Record(endpoint string, succeeded bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	counts, ok := p.counts[endpoint]
+	if !ok {
+		counts = &endpointCounts{}
+		p.counts[endpoint] = counts
+	}
+	if succeeded {
+		counts.Succeeded++
+	} else {
+		counts.Failed++
+	}
+}
+
+func (p *endpointPool) Counts() map[string]endpointCounts {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	counts := make(map[string]endpointCounts, len(p.counts))
+	for endpoint, c := range p.counts {
+		counts[endpoint] = *c
+	}
+	return counts
+}
+
+// Discover refreshes the discovered endpoints every interval. A failed
+// discovery keeps the previous endpoints.
+func (p *endpointPool) Discover(discover func() ([]string, error), interval time.Duration) {
+	for {
+		endpoints, err := discover()
+		if err != nil {
+			log.Printf("failed to discover endpoints: %s\n", err)
+		} else {
+			p.mutex.Lock()
+			p.discovered = endpoints
+			p.mutex.Unlock()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// splitEndpoints parses a comma-separated list of endpoints.
+func splitEndpoints(list string) []string {
+	endpoints := []string{}
+	for _, endpoint := range strings.Split(list, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// endpointsFromFile reads one endpoint per line, skipping blank lines and
+// lines starting with #.
+func endpointsFromFile(path string) func() ([]string, error) {
+	return func() ([]string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		endpoints := []string{}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				endpoints = append(endpoints, line)
+			}
+		}
+		return endpoints, scanner.Err()
+	}
+}
+
+// endpointsFromInternalRoute resolves a container-to-container internal route
+// such as "stress-app.apps.internal:8080" to one endpoint per app instance.
+func endpointsFromInternalRoute(route string) func() ([]string, error) {
+	return func() ([]string, error) {
+		host, port, err := net.SplitHostPort(route)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, err := net.LookupHost(host)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoints := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			endpoints = append(endpoints, fmt.Sprintf("http://%s/", net.JoinHostPort(addr, port)))
+		}
+		return endpoints, nil
+	}
+}
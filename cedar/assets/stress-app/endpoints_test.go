@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("endpointPool", func() {
+	It("has nothing to return without endpoints", func() {
+		pool := newEndpointPool([]string{}, roundRobinSelection)
+		_, ok := pool.Next()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("cycles through the static and discovered endpoints in round-robin mode", func() {
+		pool := newEndpointPool([]string{"a", "b"}, roundRobinSelection)
+		go pool.Discover(func() ([]string, error) { return []string{"c"}, nil }, time.Hour)
+		Eventually(func() int {
+			pool.mutex.Lock()
+			defer pool.mutex.Unlock()
+			return len(pool.discovered)
+		}).Should(Equal(1))
+
+		picked := []string{}
+		for i := 0; i < 6; i++ {
+			endpoint, ok := pool.Next()
+			Expect(ok).To(BeTrue())
+			picked = append(picked, endpoint)
+		}
+		Expect(picked).To(Equal([]string{"a", "b", "c", "a", "b", "c"}))
+	})
+
+	It("only returns known endpoints in random mode", func() {
+		pool := newEndpointPool([]string{"a", "b", "c"}, randomSelection)
+		for i := 0; i < 20; i++ {
+			endpoint, ok := pool.Next()
+			Expect(ok).To(BeTrue())
+			Expect(endpoint).To(BeElementOf("a", "b", "c"))
+		}
+	})
+
+	It("keeps the previous endpoints when a discovery fails", func() {
+		pool := newEndpointPool([]string{}, roundRobinSelection)
+		discoveries := make(chan error)
+		go pool.Discover(func() ([]string, error) {
+			if err := <-discoveries; err != nil {
+				return nil, err
+			}
+			return []string{"discovered"}, nil
+		}, time.Millisecond)
+
+		discoveries <- nil
+		discoveries <- errors.New("lookup failed")
+		discoveries <- errors.New("lookup failed")
+
+		endpoint, ok := pool.Next()
+		Expect(ok).To(BeTrue())
+		Expect(endpoint).To(Equal("discovered"))
+	})
+
+	It("counts the successes and failures of each endpoint", func() {
+		pool := newEndpointPool([]string{"a", "b"}, roundRobinSelection)
+		pool.Record("a", true)
+		pool.Record("a", false)
+		pool.Record("b", true)
+
+		Expect(pool.Counts()).To(Equal(map[string]endpointCounts{
+			"a": {Succeeded: 1, Failed: 1},
+			"b": {Succeeded: 1},
+		}))
+	})
+})
+
+var _ = Describe("splitEndpoints", func() {
+	It("trims the endpoints and skips empty ones", func() {
+		Expect(splitEndpoints(" http://a/ ,, http://b/")).To(Equal([]string{"http://a/", "http://b/"}))
+		Expect(splitEndpoints("")).To(BeEmpty())
+	})
+})
+
+var _ = Describe("endpointsFromFile", func() {
+	It("reads one endpoint per line and skips blank lines and comments", func() {
+		dir, err := ioutil.TempDir("", "endpoints")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "endpoints")
+		Expect(ioutil.WriteFile(path, []byte("# peers\nhttp://a/\n\n  http://b/  \n"), 0644)).To(Succeed())
+
+		endpoints, err := endpointsFromFile(path)()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(endpoints).To(Equal([]string{"http://a/", "http://b/"}))
+	})
+
+	It("fails when the file is missing", func() {
+		_, err := endpointsFromFile("/does/not/exist")()
+		Expect(err).To(HaveOccurred())
+	})
+})
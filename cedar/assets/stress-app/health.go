@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthy   = "healthy"
+	unhealthy = "unhealthy"
+	hanging   = "hanging"
+
+	// httpFailureMode answers app requests with a 503 while unhealthy, which
+	// fails http health checks. portFailureMode closes the listening socket
+	// instead, which fails port health checks.
+	httpFailureMode = "http"
+	portFailureMode = "port"
+)
+
+// server is implemented by http.Server and tcpEchoServer.
+type server interface {
+	Serve(listener net.Listener) error
+}
+
+// health controls how the app responds to Diego's health checks. The state
+// changes on timers configured at startup and through the control endpoint.
+type health struct {
+	mutex   sync.Mutex
+	state   string
+	changed chan struct{}
+
+	failureMode  string
+	startupDelay time.Duration
+	stopFlapping chan struct{}
+}
+
+func validateFailureMode(mode string) error {
+	switch mode {
+	case httpFailureMode, portFailureMode:
+		return nil
+	default:
+		return fmt.Errorf("unknown health failure mode: %s", mode)
+	}
+}
+
+func newHealth(failureMode string, startupDelay time.Duration) *health {
+	return &health{
+		state:        healthy,
+		changed:      make(chan struct{}),
+		failureMode:  failureMode,
+		startupDelay: startupDelay,
+	}
+}
+
+// Schedule makes the app unhealthy after failAfter, hang after hangAfter, and
+// alternate between healthy and unhealthy every flapPeriod. Zero durations
+// are ignored.
+func (h *health) Schedule(failAfter, hangAfter, flapPeriod time.Duration) {
+	if failAfter > 0 {
+		time.AfterFunc(h.startupDelay+failAfter, func() { h.Set(unhealthy) })
+	}
+	if hangAfter > 0 {
+		time.AfterFunc(h.startupDelay+hangAfter, func() { h.Set(hanging) })
+	}
+	if flapPeriod > 0 {
+		time.AfterFunc(h.startupDelay, func() { h.Flap(flapPeriod) })
+	}
+}
+
+func (h *health) Set(state string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.state == state {
+		return
+	}
+	log.Printf("Health changed from %s to %s\n", h.state, state)
+	h.state = state
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// Flap toggles between healthy and unhealthy every period until Flap is
+// called again. A zero period just stops flapping.
+func (h *health) Flap(period time.Duration) {
+	h.mutex.Lock()
+	if h.stopFlapping != nil {
+		close(h.stopFlapping)
+		h.stopFlapping = nil
+	}
+	if period <= 0 {
+		h.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	h.stopFlapping = stop
+	h.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if state, _ := h.watch(); state == healthy {
+					h.Set(unhealthy)
+				} else {
+					h.Set(healthy)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (h *health) watch() (string, <-chan struct{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.state, h.changed
+}
+
+func (h *health) portDown(state string) bool {
+	return h.failureMode == portFailureMode && state == unhealthy
+}
+
+// ListenAndServe waits for the startup delay before opening the port, and in
+// port failure mode closes and reopens it as the app becomes unhealthy and
+// healthy again. It returns http.ErrServerClosed once server is shut down.
+func (h *health) ListenAndServe(addr string, server server) error {
+	if h.startupDelay > 0 {
+		log.Printf("Delaying startup for %s\n", h.startupDelay)
+		time.Sleep(h.startupDelay)
+	}
+
+	for {
+		for {
+			state, changed := h.watch()
+			if !h.portDown(state) {
+				break
+			}
+			<-changed
+		}
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		go h.closeWhenPortDown(listener)
+
+		err = server.Serve(listener)
+		if state, _ := h.watch(); err == http.ErrServerClosed || !h.portDown(state) {
+			return err
+		}
+		log.Println("Closed port while unhealthy")
+	}
+}
+
+func (h *health) closeWhenPortDown(listener net.Listener) {
+	for {
+		state, changed := h.watch()
+		if h.portDown(state) {
+			listener.Close()
+			return
+		}
+		<-changed
+	}
+}
+
+// Wrap applies the current health to app requests: they block while hanging
+// and fail with a 503 while unhealthy in http failure mode.
+func (h *health) Wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		state, changed := h.watch()
+		for state == hanging {
+			select {
+			case <-changed:
+				state, changed = h.watch()
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if state == unhealthy {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+// ServeHTTP reports the current health on GET, and on PUT or POST changes it
+// using the `state` (healthy, unhealthy or hanging) and `flap` (a duration,
+// 0 to stop) query parameters.
+func (h *health) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		if flap := r.URL.Query().Get("flap"); flap != "" {
+			period, err := time.ParseDuration(flap)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			h.Flap(period)
+		}
+
+		switch state := r.URL.Query().Get("state"); state {
+		case "":
+		case healthy, unhealthy, hanging:
+			h.Set(state)
+		default:
+			http.Error(rw, "unknown state: "+state, http.StatusBadRequest)
+			return
+		}
+	default:
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	state, _ := h.watch()
+	json.NewEncoder(rw).Encode(map[string]string{
+		"state":        state,
+		"failure_mode": h.failureMode,
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("validateFailureMode", func() {
+	It("accepts the http and port failure modes", func() {
+		Expect(validateFailureMode(httpFailureMode)).To(Succeed())
+		Expect(validateFailureMode(portFailureMode)).To(Succeed())
+	})
+
+	It("rejects an unknown failure mode", func() {
+		Expect(validateFailureMode("tcp")).To(MatchError("unknown health failure mode: tcp"))
+	})
+})
+
+var _ = Describe("health", func() {
+	const period = 50 * time.Millisecond
+
+	var appHealth *health
+
+	state := func() string {
+		state, _ := appHealth.watch()
+		return state
+	}
+
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("ok"))
+	})
+
+	serve := func(ctx context.Context) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		appHealth.Wrap(ok).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+		return recorder
+	}
+
+	Context("in http failure mode", func() {
+		BeforeEach(func() {
+			appHealth = newHealth(httpFailureMode, 0)
+		})
+
+		It("fails app requests with a 503 after the fail-after time", func() {
+			appHealth.Schedule(period, 0, 0)
+			Expect(serve(context.Background()).Code).To(Equal(http.StatusOK))
+
+			Eventually(state).Should(Equal(unhealthy))
+			Expect(serve(context.Background()).Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("holds app requests after the hang-after time until the app recovers", func() {
+			appHealth.Schedule(0, period, 0)
+			Eventually(state).Should(Equal(hanging))
+
+			served := make(chan int, 1)
+			go func() { served <- serve(context.Background()).Code }()
+			Consistently(served, 2*period).ShouldNot(Receive())
+
+			appHealth.Set(healthy)
+			Eventually(served).Should(Receive(Equal(http.StatusOK)))
+		})
+
+		It("gives up on a hanging request once the client goes away", func() {
+			appHealth.Set(hanging)
+			ctx, cancel := context.WithTimeout(context.Background(), period)
+			defer cancel()
+
+			recorder := serve(ctx)
+			Expect(recorder.Body.String()).To(BeEmpty())
+		})
+
+		It("alternates between healthy and unhealthy every flap period until stopped", func() {
+			appHealth.Schedule(0, 0, period)
+			Eventually(state).Should(Equal(unhealthy))
+			Eventually(state).Should(Equal(healthy))
+			Eventually(state).Should(Equal(unhealthy))
+
+			appHealth.Flap(0)
+			stopped := state()
+			Consistently(state, 3*period).Should(Equal(stopped))
+		})
+	})
+
+	Context("in port failure mode", func() {
+		var (
+			addr   string
+			server *http.Server
+			served chan error
+		)
+
+		dial := func() error {
+			conn, err := net.Dial("tcp", addr)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}
+
+		BeforeEach(func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			addr = listener.Addr().String()
+			listener.Close()
+
+			server = &http.Server{Handler: ok}
+			served = make(chan error, 1)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		listen := func() {
+			go func() { served <- appHealth.ListenAndServe(addr, server) }()
+		}
+
+		It("opens the port only after the startup delay", func() {
+			appHealth = newHealth(portFailureMode, 4*period)
+			listen()
+
+			Consistently(dial, 2*period).Should(HaveOccurred())
+			Eventually(dial).Should(Succeed())
+		})
+
+		It("closes the port while unhealthy and reopens it once healthy", func() {
+			appHealth = newHealth(portFailureMode, 0)
+			listen()
+			Eventually(dial).Should(Succeed())
+
+			appHealth.Set(unhealthy)
+			Eventually(dial).Should(HaveOccurred())
+			Consistently(dial, 2*period).Should(HaveOccurred())
+
+			appHealth.Set(healthy)
+			Eventually(dial).Should(Succeed())
+
+			Expect(server.Shutdown(context.Background())).To(Succeed())
+			Eventually(served).Should(Receive(Equal(http.ErrServerClosed)))
+		})
+	})
+})
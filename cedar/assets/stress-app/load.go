@@ -0,0 +1,167 @@
+package main
+
+import (
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	megabyte = 1024 * 1024
+	pageSize = 4096
+)
+
+// cpuLoad keeps the app busy for a target percentage of one core. Each burner
+// goroutine spins for its share of every duty cycle and sleeps for the rest,
+// so a percentage above 100 spreads across several cores.
+type cpuLoad struct {
+	mutex     sync.Mutex
+	percent   float64
+	dutyCycle time.Duration
+}
+
+func newCPULoad(percent float64, dutyCycle time.Duration) *cpuLoad {
+	if dutyCycle <= 0 {
+		dutyCycle = 100 * time.Millisecond
+	}
+	return &cpuLoad{percent: percent, dutyCycle: dutyCycle}
+}
+
+func (c *cpuLoad) Set(percent float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.percent = percent
+}
+
+func (c *cpuLoad) Percent() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.percent
+}
+
+func (c *cpuLoad) Run() {
+	burners := runtime.NumCPU()
+	for i := 0; i < burners; i++ {
+		go c.burn(burners)
+	}
+}
+
+func (c *cpuLoad) burn(burners int) {
+	for {
+		share := c.Percent() / float64(burners) / 100
+		if share > 1 {
+			share = 1
+		}
+		if share <= 0 {
+			time.Sleep(c.dutyCycle)
+			continue
+		}
+
+		busy := time.Duration(share * float64(c.dutyCycle))
+		deadline := time.Now().Add(busy)
+		for time.Now().Before(deadline) {
+		}
+		time.Sleep(c.dutyCycle - busy)
+	}
+}
+
+// memoryLoad holds a steady baseline of resident memory, leaks at a fixed
+// rate on top of it and periodically allocates a temporary spike. Allocated
+// pages are written to so they count against the container's RSS.
+type memoryLoad struct {
+	mutex sync.Mutex
+
+	baselineMB      int
+	leakMBPerMinute float64
+	spikeMB         int
+	spikeInterval   time.Duration
+	spikeDuration   time.Duration
+
+	baseline [][]byte
+	leaked   [][]byte
+	leakDebt float64
+}
+
+func newMemoryLoad(baselineMB int, leakMBPerMinute float64, spikeMB int, spikeInterval, spikeDuration time.Duration) *memoryLoad {
+	return &memoryLoad{
+		baselineMB:      baselineMB,
+		leakMBPerMinute: leakMBPerMinute,
+		spikeMB:         spikeMB,
+		spikeInterval:   spikeInterval,
+		spikeDuration:   spikeDuration,
+	}
+}
+
+func (m *memoryLoad) Set(baselineMB int, leakMBPerMinute float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.baselineMB = baselineMB
+	m.leakMBPerMinute = leakMBPerMinute
+}
+
+func (m *memoryLoad) Targets() (int, float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.baselineMB, m.leakMBPerMinute
+}
+
+// AllocatedMB reports the baseline and leaked memory currently held.
+func (m *memoryLoad) AllocatedMB() (int, int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.baseline), len(m.leaked)
+}
+
+func (m *memoryLoad) Run() {
+	if m.spikeMB > 0 && m.spikeInterval > 0 {
+		go m.spike()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	for range ticker.C {
+		m.tick()
+	}
+}
+
+func (m *memoryLoad) tick() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.baseline) > m.baselineMB {
+		// Copy the kept buffers so the backing array no longer references
+		// the dropped ones and they can actually be freed.
+		kept := make([][]byte, m.baselineMB)
+		copy(kept, m.baseline)
+		m.baseline = kept
+		debug.FreeOSMemory()
+	}
+	for len(m.baseline) < m.baselineMB {
+		m.baseline = append(m.baseline, allocate(megabyte))
+	}
+
+	m.leakDebt += m.leakMBPerMinute / 60
+	for ; m.leakDebt >= 1; m.leakDebt-- {
+		m.leaked = append(m.leaked, allocate(megabyte))
+	}
+}
+
+func (m *memoryLoad) spike() {
+	ticker := time.NewTicker(m.spikeInterval)
+	for range ticker.C {
+		log.Printf("Allocating a %dMB memory spike for %s\n", m.spikeMB, m.spikeDuration)
+		spike := allocate(m.spikeMB * megabyte)
+		time.Sleep(m.spikeDuration)
+		runtime.KeepAlive(spike)
+		debug.FreeOSMemory()
+	}
+}
+
+func allocate(size int) []byte {
+	b := make([]byte, size)
+	for i := 0; i < len(b); i += pageSize {
+		b[i] = 1
+	}
+	return b
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("memoryLoad", func() {
+	It("grows the baseline to the target", func() {
+		memory := newMemoryLoad(3, 0, 0, 0, 0)
+		memory.tick()
+
+		baseline, leaked := memory.AllocatedMB()
+		Expect(baseline).To(Equal(3))
+		Expect(leaked).To(Equal(0))
+	})
+
+	It("releases the dropped buffers when the baseline shrinks", func() {
+		memory := newMemoryLoad(4, 0, 0, 0, 0)
+		memory.tick()
+		kept := &memory.baseline[0][0]
+
+		memory.Set(1, 0)
+		memory.tick()
+
+		baseline, _ := memory.AllocatedMB()
+		Expect(baseline).To(Equal(1))
+		Expect(cap(memory.baseline)).To(Equal(1))
+		Expect(&memory.baseline[0][0]).To(BeIdenticalTo(kept))
+	})
+
+	It("leaks whole megabytes at the configured rate", func() {
+		memory := newMemoryLoad(0, 90, 0, 0, 0)
+		memory.tick()
+		_, leaked := memory.AllocatedMB()
+		Expect(leaked).To(Equal(1))
+
+		memory.tick()
+		_, leaked = memory.AllocatedMB()
+		Expect(leaked).To(Equal(3))
+	})
+})
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// logGenerator writes log entries for loggregator to carry. When sequenced,
+// every line is tagged with the app instance and a per-instance entry number
+// so a reader can find lost lines by looking for gaps in the sequence.
+type logGenerator struct {
+	mutex sync.Mutex
+
+	stdout io.Writer
+	stderr io.Writer
+
+	payload        []byte
+	stderrPercent  float64
+	linesPerEntry  int
+	sequenced      bool
+	instanceGuid   string
+	instanceIndex  string
+	sequenceNumber uint64
+}
+
+// newLogGenerator pads or truncates payload to lineBytes, unless lineBytes is
+// zero.
+func newLogGenerator(stdout, stderr io.Writer, payload []byte, lineBytes int, stderrPercent float64, linesPerEntry int, sequenced bool, instanceGuid, instanceIndex string) *logGenerator {
+	payload = bytes.Replace(payload, []byte("\n"), []byte(" "), -1)
+	if lineBytes > 0 {
+		pattern := append(append([]byte{}, payload...), ' ')
+		payload = bytes.Repeat(pattern, lineBytes/len(pattern)+1)[:lineBytes]
+	}
+	if linesPerEntry < 1 {
+		linesPerEntry = 1
+	}
+
+	return &logGenerator{
+		stdout:        stdout,
+		stderr:        stderr,
+		payload:       payload,
+		stderrPercent: stderrPercent,
+		linesPerEntry: linesPerEntry,
+		sequenced:     sequenced,
+		instanceGuid:  instanceGuid,
+		instanceIndex: instanceIndex,
+	}
+}
+
+// Emit writes a single entry, which is linesPerEntry lines long, to either
+// stdout or stderr.
+func (g *logGenerator) Emit() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	out := g.stdout
+	if rand.Float64()*100 < g.stderrPercent {
+		out = g.stderr
+	}
+
+	g.sequenceNumber++
+	timestamp := time.Now().Format("2006/01/02 15:04:05.000000")
+
+	entry := &bytes.Buffer{}
+	for line := 1; line <= g.linesPerEntry; line++ {
+		entry.WriteString(timestamp)
+		if g.sequenced {
+			fmt.Fprintf(entry, " seq=%d instance=%s index=%s line=%d/%d",
+				g.sequenceNumber, g.instanceGuid, g.instanceIndex, line, g.linesPerEntry)
+		}
+		entry.WriteByte(' ')
+		entry.Write(g.payload)
+		entry.WriteByte('\n')
+	}
+	out.Write(entry.Bytes())
+}
+
+func validateBurstInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("LOG_BURST_INTERVAL must be positive, got %s", interval)
+	}
+	return nil
+}
+
+// Burst emits size entries back to back every interval.
+func (g *logGenerator) Burst(size int, interval time.Duration, emitted func()) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		for i := 0; i < size; i++ {
+			g.Emit()
+			emitted()
+		}
+	}
+}
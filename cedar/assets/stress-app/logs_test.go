@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("logGenerator", func() {
+	var stdout, stderr *gbytes.Buffer
+
+	// Burst never returns, so every spec writes into its own buffers to stay
+	// unaffected by the bursts of earlier specs.
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+	})
+
+	lines := func(buffer *gbytes.Buffer) []string {
+		return strings.Split(strings.TrimSuffix(string(buffer.Contents()), "\n"), "\n")
+	}
+
+	Describe("Emit", func() {
+		It("pads the payload to the line size and replaces its newlines", func() {
+			generator := newLogGenerator(stdout, stderr, []byte("a\nb"), 10, 0, 1, false, "guid", "0")
+			generator.Emit()
+
+			Expect(lines(stdout)).To(HaveLen(1))
+			Expect(lines(stdout)[0]).To(HaveSuffix(" a b a b a "))
+			Expect(stderr.Contents()).To(BeEmpty())
+		})
+
+		It("writes every entry to stderr at a stderr percent of 100", func() {
+			generator := newLogGenerator(stdout, stderr, []byte("hello"), 0, 100, 1, false, "guid", "0")
+			for i := 0; i < 10; i++ {
+				generator.Emit()
+			}
+
+			Expect(lines(stderr)).To(HaveLen(10))
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+
+		It("tags every line of a multi-line entry with its sequence number", func() {
+			generator := newLogGenerator(stdout, stderr, []byte("hello"), 0, 0, 2, true, "guid", "3")
+			generator.Emit()
+			generator.Emit()
+
+			Expect(lines(stdout)).To(HaveLen(4))
+			Expect(lines(stdout)[0]).To(HaveSuffix(" seq=1 instance=guid index=3 line=1/2 hello"))
+			Expect(lines(stdout)[1]).To(HaveSuffix(" seq=1 instance=guid index=3 line=2/2 hello"))
+			Expect(lines(stdout)[3]).To(HaveSuffix(" seq=2 instance=guid index=3 line=2/2 hello"))
+		})
+
+		It("writes at least one line per entry", func() {
+			generator := newLogGenerator(stdout, stderr, []byte("hello"), 0, 0, 0, false, "guid", "0")
+			generator.Emit()
+
+			Expect(bytes.Count(stdout.Contents(), []byte("\n"))).To(Equal(1))
+		})
+	})
+
+	Describe("Burst", func() {
+		It("emits a burst of entries every interval", func() {
+			emitted := new(int64)
+			generator := newLogGenerator(stdout, stderr, []byte("hello"), 0, 0, 1, false, "guid", "0")
+			go generator.Burst(5, 20*time.Millisecond, func() { atomic.AddInt64(emitted, 1) })
+
+			Eventually(func() int64 { return atomic.LoadInt64(emitted) }).Should(BeNumerically(">=", 10))
+		})
+	})
+
+	Describe("validateBurstInterval", func() {
+		It("accepts a positive interval", func() {
+			Expect(validateBurstInterval(time.Millisecond)).To(Succeed())
+		})
+
+		It("rejects an interval that would panic the ticker", func() {
+			Expect(validateBurstInterval(0)).To(MatchError("LOG_BURST_INTERVAL must be positive, got 0s"))
+			Expect(validateBurstInterval(-time.Second)).To(HaveOccurred())
+		})
+	})
+})
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
@@ -30,68 +38,237 @@ func main() {
 		maxSecondsTilCrash = 0
 	}
 
+	cpu := newCPULoad(
+		envFloat("CPU_LOAD_PERCENT", 0),
+		envDuration("CPU_DUTY_CYCLE", 100*time.Millisecond),
+	)
+	cpu.Run()
+
+	memory := newMemoryLoad(
+		envInt("MEMORY_BASELINE_MB", 0),
+		envFloat("MEMORY_LEAK_MB_PER_MINUTE", 0),
+		envInt("MEMORY_SPIKE_MB", 0),
+		envDuration("MEMORY_SPIKE_INTERVAL", 0),
+		envDuration("MEMORY_SPIKE_DURATION", 10*time.Second),
+	)
+	go memory.Run()
+
 	vcapApplication := os.Getenv("VCAP_APPLICATION")
 	vcapApplicationBytes := []byte(vcapApplication)
 
-	var requestTicker, logTicker *time.Ticker
-	var crashTimer *time.Timer
+	disk := newDiskLoad(
+		envString("DISK_LOAD_DIR", filepath.Join(os.TempDir(), "stress-app-disk")),
+		envInt("DISK_QUOTA_MB", diskQuotaMB(vcapApplication)),
+		envFloat("DISK_FILL_PERCENT", 0),
+		envFloat("DISK_WRITE_MB_PER_SECOND", 0),
+		envFloat("DISK_FILES_PER_SECOND", 0),
+		envInt("DISK_FILE_KB", 4),
+		envInt("DISK_MAX_FILES", 100),
+	)
+	if err := disk.validate(); err != nil {
+		log.Fatal(err)
+	}
+	go disk.Run()
 
-	if requestRate > 0 {
-		requestTicker = time.NewTicker(time.Duration(float64(time.Second) / requestRate))
-	} else {
-		requestTicker = time.NewTicker(time.Hour)
-		requestTicker.Stop()
+	// Instances are offset by their index so a fixed RANDOM_SEED does not
+	// make them all behave alike.
+	seed := int64(envInt("RANDOM_SEED", int(time.Now().UnixNano())))
+	if instanceIndex, err := strconv.Atoi(os.Getenv("CF_INSTANCE_INDEX")); err == nil {
+		seed += int64(instanceIndex)
 	}
+	rand.Seed(seed)
+	crashRandomSeed := crashSeed(seed, os.Getenv("RANDOM_SEED") != "", os.Getenv("CF_INSTANCE_GUID"))
+	log.Printf("Using random seed %d and crash seed %d\n", seed, crashRandomSeed)
+	crashRandom := rand.New(rand.NewSource(crashRandomSeed))
 
-	if logRate > 0 {
-		logTicker = time.NewTicker(time.Duration(float64(time.Second) / logRate))
-	} else {
-		logTicker = time.NewTicker(time.Hour)
-		logTicker.Stop()
+	appStats := newStats()
+
+	endpointSelection := envString("ENDPOINT_SELECTION", randomSelection)
+	if endpointSelection != randomSelection && endpointSelection != roundRobinSelection {
+		log.Fatalf("unknown endpoint selection: %s", endpointSelection)
+	}
+	endpoints := newEndpointPool(
+		append(splitEndpoints(endpointToHit), splitEndpoints(os.Getenv("ENDPOINTS_TO_HIT"))...),
+		endpointSelection,
+	)
+	refreshInterval := envDuration("ENDPOINTS_REFRESH_INTERVAL", 30*time.Second)
+	if endpointsFile := os.Getenv("ENDPOINTS_FILE"); endpointsFile != "" {
+		go endpoints.Discover(endpointsFromFile(endpointsFile), refreshInterval)
+	} else if internalRoute := os.Getenv("ENDPOINTS_INTERNAL_ROUTE"); internalRoute != "" {
+		go endpoints.Discover(endpointsFromInternalRoute(internalRoute), refreshInterval)
 	}
 
-	rand.Seed(int64(time.Now().Nanosecond()))
+	requests := newRateLoop(requestRate)
+	go requests.Run(func() {
+		if endpoint, ok := endpoints.Next(); ok {
+			hitEndpoint(endpoint, appStats, endpoints)
+		}
+	})
 
-	if minSecondsTilCrash > 0 && maxSecondsTilCrash > 0 {
-		secondsTilCrash := rand.Intn(maxSecondsTilCrash-minSecondsTilCrash) + minSecondsTilCrash
-		log.Printf("Crashing in %d seconds\n", secondsTilCrash)
-		crashTimer = time.NewTimer(time.Second * time.Duration(secondsTilCrash))
-	} else {
-		crashTimer = time.NewTimer(time.Hour)
-		crashTimer.Stop()
+	logGenerator := newLogGenerator(
+		os.Stdout,
+		os.Stderr,
+		vcapApplicationBytes,
+		envInt("LOG_LINE_BYTES", 0),
+		envFloat("LOG_STDERR_PERCENT", 100),
+		envInt("LOG_LINES_PER_ENTRY", 1),
+		envBool("LOG_SEQUENCE_NUMBERS", false),
+		os.Getenv("CF_INSTANCE_GUID"),
+		os.Getenv("CF_INSTANCE_INDEX"),
+	)
+	logWritten := func() {
+		atomic.AddInt64(&appStats.LogsWritten, 1)
 	}
 
-	go func() {
-		for {
-			select {
-			case <-requestTicker.C:
-				go hitEndpoint(endpointToHit)
-			case <-logTicker.C:
-				go log.Println(vcapApplication)
-			case <-crashTimer.C:
-				panic("freak out")
-			}
+	logs := newRateLoop(logRate)
+	go logs.Run(func() {
+		logGenerator.Emit()
+		logWritten()
+	})
+
+	if burstSize := envInt("LOG_BURST_SIZE", 0); burstSize > 0 {
+		burstInterval := envDuration("LOG_BURST_INTERVAL", time.Minute)
+		if err := validateBurstInterval(burstInterval); err != nil {
+			log.Fatal(err)
 		}
-	}()
+		go logGenerator.Burst(burstSize, burstInterval, logWritten)
+	}
 
-	err = http.ListenAndServe("0.0.0.0:"+os.Getenv("PORT"), http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		rw.Write(vcapApplicationBytes)
-	}))
+	failureMode := envString("HEALTH_FAILURE_MODE", httpFailureMode)
+	if err := validateFailureMode(failureMode); err != nil {
+		log.Fatal(err)
+	}
+	appHealth := newHealth(
+		failureMode,
+		envDuration("HEALTH_STARTUP_DELAY", 0),
+	)
+	appHealth.Schedule(
+		envDuration("HEALTH_FAIL_AFTER", 0),
+		envDuration("HEALTH_HANG_AFTER", 0),
+		envDuration("HEALTH_FLAP_PERIOD", 0),
+	)
 
-	if err != nil {
+	crashMode := envString("CRASH_MODE", panicCrash)
+	if err := validateCrashMode(crashMode); err != nil {
+		log.Fatal(err)
+	}
+	appCrasher := newCrasher(crashMode, envInt("CRASH_EXIT_CODE", 1), func() {
+		appHealth.Set(hanging)
+	})
+	if envBool("CRASH_AT_STARTUP", false) {
+		appCrasher.Crash("")
+	}
+	crashDelay, crashScheduled := crashSchedule(
+		crashRandom,
+		envDuration("CRASH_AFTER", 0),
+		envDuration("CRASH_JITTER", 0),
+		minSecondsTilCrash,
+		maxSecondsTilCrash,
+	)
+	if crashScheduled {
+		log.Printf("Crashing in %s\n", crashDelay)
+		time.AfterFunc(crashDelay, func() { appCrasher.Crash("") })
+	}
+
+	responseDistribution := envString("RESPONSE_DELAY_DISTRIBUTION", fixedDelay)
+	if err := validateDelayDistribution(responseDistribution); err != nil {
 		log.Fatal(err)
 	}
+	responseErrorPercent := envFloat("RESPONSE_ERROR_PERCENT", 0)
+	if err := validateErrorPercent(responseErrorPercent); err != nil {
+		log.Fatal(err)
+	}
+	appResponder := newResponder(vcapApplicationBytes, envInt("RESPONSE_BODY_BYTES", 0), responseSettings{
+		Delay:             envDuration("RESPONSE_DELAY", 0),
+		DelayMax:          envDuration("RESPONSE_DELAY_MAX", 0),
+		DelayDistribution: responseDistribution,
+		ErrorPercent:      responseErrorPercent,
+		ErrorStatus:       envInt("RESPONSE_ERROR_STATUS", http.StatusInternalServerError),
+		Chunked:           envBool("RESPONSE_CHUNKED", false),
+		ChunkBytes:        envInt("RESPONSE_CHUNK_BYTES", 1024),
+		ChunkInterval:     envDuration("RESPONSE_CHUNK_INTERVAL", 0),
+	})
+
+	appControl := &control{
+		token:     os.Getenv("CONTROL_TOKEN"),
+		logs:      logs,
+		requests:  requests,
+		cpu:       cpu,
+		memory:    memory,
+		responder: appResponder,
+		health:    appHealth,
+		crash:     appCrasher.Crash,
+	}
+
+	appDrainer := newDrainer(
+		envDuration("SHUTDOWN_DRAIN_TIME", 0),
+		envDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		appStats,
+	)
+
+	var appServer server
+	var shutdown func(context.Context) error
+
+	switch listenerMode := envString("LISTENER_MODE", httpListener); listenerMode {
+	case tcpListener:
+		echoServer := newTCPEchoServer(appStats)
+		appServer, shutdown = echoServer, echoServer.Shutdown
+
+	case httpListener, http2Listener:
+		connections := newLongConnections(envDuration("STREAM_INTERVAL", time.Second), appStats)
+
+		mux := http.NewServeMux()
+		appControl.Register(mux)
+		connections.Register(mux)
+		mux.Handle("/_stats", statsHandler{stats: appStats, control: appControl, endpoints: endpoints, drainer: appDrainer})
+		mux.Handle("/", appStats.CountServed(appHealth.Wrap(appResponder)))
+
+		httpServer := &http.Server{Handler: mux}
+		if listenerMode == http2Listener {
+			// Accept HTTP/2 with prior knowledge, as the gorouter sends it to
+			// http2 routes, alongside HTTP/1.1.
+			httpServer.Handler = h2c.NewHandler(mux, &http2.Server{})
+		}
+		httpServer.RegisterOnShutdown(connections.Close)
+		appServer, shutdown = httpServer, httpServer.Shutdown
+
+	default:
+		log.Fatalf("unknown listener mode: %s", listenerMode)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go appDrainer.Watch(shutdown, signals)
+
+	go func() {
+		err := appHealth.ListenAndServe("0.0.0.0:"+os.Getenv("PORT"), appServer)
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-appDrainer.Done()
 }
 
-func hitEndpoint(endpoint string) {
+// hitEndpoint counts a request as failed when it errors or gets a 4xx or 5xx
+// response.
+func hitEndpoint(endpoint string, appStats *stats, endpoints *endpointPool) {
+	atomic.AddInt64(&appStats.RequestsSent, 1)
 	resp, err := http.Get(endpoint)
 	if err != nil {
+		atomic.AddInt64(&appStats.RequestsFailed, 1)
+		endpoints.Record(endpoint, false)
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
+	succeeded := err == nil && resp.StatusCode < http.StatusBadRequest
+	if !succeeded {
+		atomic.AddInt64(&appStats.RequestsFailed, 1)
+	}
+	endpoints.Record(endpoint, succeeded)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return
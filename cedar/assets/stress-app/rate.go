@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLoop calls a function at a rate that can be changed while it runs. A
+// rate of zero or less pauses it.
+type rateLoop struct {
+	mutex   sync.Mutex
+	rate    float64
+	changed chan struct{}
+}
+
+func newRateLoop(rate float64) *rateLoop {
+	return &rateLoop{rate: rate, changed: make(chan struct{})}
+}
+
+func (l *rateLoop) Set(rate float64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.rate = rate
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func (l *rateLoop) Rate() float64 {
+	rate, _ := l.watch()
+	return rate
+}
+
+func (l *rateLoop) watch() (float64, <-chan struct{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.rate, l.changed
+}
+
+func (l *rateLoop) Run(fn func()) {
+	for {
+		rate, changed := l.watch()
+		if rate <= 0 {
+			<-changed
+			continue
+		}
+
+		timer := time.NewTimer(time.Duration(float64(time.Second) / rate))
+		select {
+		case <-timer.C:
+			go fn()
+		case <-changed:
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("rateLoop", func() {
+	var (
+		call  func()
+		count func() int64
+	)
+
+	// Run never returns, so every spec counts into its own variable to stay
+	// unaffected by the loops of earlier specs.
+	BeforeEach(func() {
+		calls := new(int64)
+		call = func() { atomic.AddInt64(calls, 1) }
+		count = func() int64 { return atomic.LoadInt64(calls) }
+	})
+
+	It("calls the function at the configured rate", func() {
+		loop := newRateLoop(100)
+		go loop.Run(call)
+
+		Eventually(count).Should(BeNumerically(">=", 10))
+	})
+
+	It("stays paused until a positive rate is set", func() {
+		loop := newRateLoop(0)
+		go loop.Run(call)
+
+		Consistently(count, 200*time.Millisecond).Should(BeZero())
+
+		loop.Set(100)
+		Expect(loop.Rate()).To(Equal(100.0))
+		Eventually(count).Should(BeNumerically(">=", 10))
+	})
+
+	It("pauses when the rate is set to zero", func() {
+		loop := newRateLoop(100)
+		go loop.Run(call)
+		Eventually(count).Should(BeNumerically(">", 0))
+
+		loop.Set(0)
+		paused := count()
+		Consistently(count, 200*time.Millisecond).Should(BeNumerically("<=", paused+1))
+	})
+
+	It("picks up a new rate without waiting for the old interval", func() {
+		loop := newRateLoop(0.01)
+		go loop.Run(call)
+
+		loop.Set(100)
+		Eventually(count, time.Second).Should(BeNumerically(">", 0))
+	})
+})
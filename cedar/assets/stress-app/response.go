@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	fixedDelay       = "fixed"
+	uniformDelay     = "uniform"
+	exponentialDelay = "exponential"
+)
+
+// responseSettings shape the app's responses so router latency and error
+// measurements can be checked against known values. Delay is the fixed
+// delay, the minimum of a uniform delay or the mean of an exponential one;
+// DelayMax is the maximum of a uniform delay.
+type responseSettings struct {
+	Delay             time.Duration
+	DelayMax          time.Duration
+	DelayDistribution string
+
+	ErrorPercent float64
+	ErrorStatus  int
+
+	Chunked       bool
+	ChunkBytes    int
+	ChunkInterval time.Duration
+}
+
+// responder serves the app's own requests. Every response carries the delay
+// it was given in an X-Stress-App-Delay header.
+type responder struct {
+	mutex    sync.Mutex
+	settings responseSettings
+	body     []byte
+}
+
+// newResponder pads or truncates body to bodyBytes, unless bodyBytes is zero.
+func newResponder(body []byte, bodyBytes int, settings responseSettings) *responder {
+	if bodyBytes > 0 {
+		pattern := append(append([]byte{}, body...), '\n')
+		body = bytes.Repeat(pattern, bodyBytes/len(pattern)+1)[:bodyBytes]
+	}
+	return &responder{body: body, settings: settings}
+}
+
+func (r *responder) Set(settings responseSettings) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.settings = settings
+}
+
+func (r *responder) Settings() responseSettings {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.settings
+}
+
+func validateDelayDistribution(distribution string) error {
+	switch distribution {
+	case fixedDelay, uniformDelay, exponentialDelay:
+		return nil
+	default:
+		return fmt.Errorf("unknown delay distribution: %s", distribution)
+	}
+}
+
+func validateErrorPercent(percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("response error percent must be between 0 and 100, got %g", percent)
+	}
+	return nil
+}
+
+func (s responseSettings) nextDelay() time.Duration {
+	switch s.DelayDistribution {
+	case uniformDelay:
+		if s.DelayMax <= s.Delay {
+			return s.Delay
+		}
+		return s.Delay + time.Duration(rand.Int63n(int64(s.DelayMax-s.Delay)))
+	case exponentialDelay:
+		return time.Duration(rand.ExpFloat64() * float64(s.Delay))
+	default:
+		return s.Delay
+	}
+}
+
+func (s responseSettings) shouldFail() bool {
+	return s.ErrorPercent > 0 && rand.Float64()*100 < s.ErrorPercent
+}
+
+func (r *responder) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	settings := r.Settings()
+
+	delay := settings.nextDelay()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+	rw.Header().Set("X-Stress-App-Delay", delay.String())
+
+	if settings.shouldFail() {
+		status := settings.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		http.Error(rw, fmt.Sprintf("injected %d error", status), status)
+		return
+	}
+
+	flusher, ok := rw.(http.Flusher)
+	if !settings.Chunked || !ok || settings.ChunkBytes <= 0 {
+		rw.Write(r.body)
+		return
+	}
+
+	for offset := 0; offset < len(r.body); offset += settings.ChunkBytes {
+		end := offset + settings.ChunkBytes
+		if end > len(r.body) {
+			end = len(r.body)
+		}
+		if _, err := rw.Write(r.body[offset:end]); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		if settings.ChunkInterval > 0 && end < len(r.body) {
+			select {
+			case <-time.After(settings.ChunkInterval):
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("responder", func() {
+	const draws = 1000
+
+	Describe("nextDelay", func() {
+		It("always returns the fixed delay", func() {
+			settings := responseSettings{Delay: 20 * time.Millisecond, DelayMax: time.Second, DelayDistribution: fixedDelay}
+			for i := 0; i < draws; i++ {
+				Expect(settings.nextDelay()).To(Equal(20 * time.Millisecond))
+			}
+		})
+
+		It("keeps a uniform delay between the minimum and the maximum", func() {
+			settings := responseSettings{Delay: 10 * time.Millisecond, DelayMax: 30 * time.Millisecond, DelayDistribution: uniformDelay}
+			var lowest, highest time.Duration = settings.DelayMax, 0
+			for i := 0; i < draws; i++ {
+				delay := settings.nextDelay()
+				Expect(delay).To(BeNumerically(">=", settings.Delay))
+				Expect(delay).To(BeNumerically("<", settings.DelayMax))
+				if delay < lowest {
+					lowest = delay
+				}
+				if delay > highest {
+					highest = delay
+				}
+			}
+			Expect(lowest).To(BeNumerically("<", 15*time.Millisecond))
+			Expect(highest).To(BeNumerically(">", 25*time.Millisecond))
+		})
+
+		It("falls back to the minimum when the uniform maximum is not above it", func() {
+			settings := responseSettings{Delay: 10 * time.Millisecond, DelayMax: 5 * time.Millisecond, DelayDistribution: uniformDelay}
+			Expect(settings.nextDelay()).To(Equal(10 * time.Millisecond))
+		})
+
+		It("keeps an exponential delay non-negative around the mean", func() {
+			settings := responseSettings{Delay: 10 * time.Millisecond, DelayDistribution: exponentialDelay}
+			var total time.Duration
+			for i := 0; i < draws; i++ {
+				delay := settings.nextDelay()
+				Expect(delay).To(BeNumerically(">=", 0))
+				total += delay
+			}
+			Expect(total / draws).To(BeNumerically("~", settings.Delay, 2*time.Millisecond))
+		})
+	})
+
+	Describe("ServeHTTP", func() {
+		serve := func(settings responseSettings) int {
+			recorder := httptest.NewRecorder()
+			newResponder([]byte("hello"), 0, settings).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+			return recorder.Code
+		}
+
+		It("never fails at an error percent of 0", func() {
+			for i := 0; i < draws; i++ {
+				Expect(serve(responseSettings{ErrorPercent: 0})).To(Equal(http.StatusOK))
+			}
+		})
+
+		It("always fails at an error percent of 100", func() {
+			for i := 0; i < draws; i++ {
+				Expect(serve(responseSettings{ErrorPercent: 100, ErrorStatus: http.StatusBadGateway})).To(Equal(http.StatusBadGateway))
+			}
+		})
+
+		It("reports the delay it was given", func() {
+			recorder := httptest.NewRecorder()
+			newResponder([]byte("hello"), 0, responseSettings{Delay: time.Millisecond}).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+			Expect(recorder.Header().Get("X-Stress-App-Delay")).To(Equal("1ms"))
+			Expect(recorder.Body.String()).To(Equal("hello"))
+		})
+	})
+
+	Describe("validateErrorPercent", func() {
+		It("accepts 0 to 100", func() {
+			Expect(validateErrorPercent(0)).To(Succeed())
+			Expect(validateErrorPercent(100)).To(Succeed())
+		})
+
+		It("rejects values outside 0 to 100", func() {
+			Expect(validateErrorPercent(-0.1)).To(MatchError("response error percent must be between 0 and 100, got -0.1"))
+			Expect(validateErrorPercent(100.1)).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// drainer shuts the app down gracefully when it is signalled. It keeps
+// serving for the drain time, then stops accepting connections and waits up
+// to the shutdown timeout for in-flight requests, logging how long each step
+// took so cell drains and rolling updates can be timed.
+type drainer struct {
+	drainTime       time.Duration
+	shutdownTimeout time.Duration
+	stats           *stats
+
+	draining int32
+	done     chan struct{}
+}
+
+func newDrainer(drainTime, shutdownTimeout time.Duration, appStats *stats) *drainer {
+	return &drainer{
+		drainTime:       drainTime,
+		shutdownTimeout: shutdownTimeout,
+		stats:           appStats,
+		done:            make(chan struct{}),
+	}
+}
+
+func (d *drainer) Draining() bool {
+	return atomic.LoadInt32(&d.draining) == 1
+}
+
+// Done is closed once the server has shut down.
+func (d *drainer) Done() <-chan struct{} {
+	return d.done
+}
+
+// Watch waits for the first signal and calls shutdown, which should behave
+// like http.Server's Shutdown.
+func (d *drainer) Watch(shutdown func(context.Context) error, signals <-chan os.Signal) {
+	sig := <-signals
+	start := time.Now()
+	atomic.StoreInt32(&d.draining, 1)
+	servedAtSignal := atomic.LoadInt64(&d.stats.RequestsServed)
+	log.Printf("Received %s, draining for %s with %d requests in flight\n",
+		sig, d.drainTime, atomic.LoadInt64(&d.stats.RequestsInFlight))
+
+	if d.drainTime > 0 {
+		ticker := time.NewTicker(time.Second)
+		drained := time.After(d.drainTime)
+	drain:
+		for {
+			select {
+			case <-ticker.C:
+				log.Printf("Draining for %s with %d requests in flight\n",
+					time.Since(start), atomic.LoadInt64(&d.stats.RequestsInFlight))
+			case <-drained:
+				break drain
+			}
+		}
+		ticker.Stop()
+	}
+
+	log.Printf("Shutting down %s after signal with %d requests in flight\n",
+		time.Since(start), atomic.LoadInt64(&d.stats.RequestsInFlight))
+	ctx, cancel := context.WithTimeout(context.Background(), d.shutdownTimeout)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down cleanly: %s\n", err)
+	}
+
+	log.Printf("Exiting %s after signal, served %d requests while draining, %d requests in flight\n",
+		time.Since(start),
+		atomic.LoadInt64(&d.stats.RequestsServed)-servedAtSignal,
+		atomic.LoadInt64(&d.stats.RequestsInFlight))
+	close(d.done)
+}
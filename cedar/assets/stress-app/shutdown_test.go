@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("drainer", func() {
+	var (
+		listener net.Listener
+		server   *http.Server
+		signals  chan os.Signal
+		started  chan struct{}
+		release  func()
+	)
+
+	BeforeEach(func() {
+		released := make(chan struct{})
+		var once sync.Once
+		release = func() { once.Do(func() { close(released) }) }
+		started = make(chan struct{}, 10)
+		signals = make(chan os.Signal, 1)
+
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server = &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				started <- struct{}{}
+				<-released
+			}
+			rw.Write([]byte("done"))
+		})}
+		go server.Serve(listener)
+	})
+
+	AfterEach(func() {
+		release()
+		server.Close()
+	})
+
+	get := func(path string) <-chan string {
+		result := make(chan string, 1)
+		go func() {
+			defer GinkgoRecover()
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+			resp, err := client.Get("http://" + listener.Addr().String() + path)
+			if err != nil {
+				result <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			result <- string(body)
+		}()
+		return result
+	}
+
+	refused := func() bool {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	}
+
+	It("lets in-flight requests finish and refuses new connections", func() {
+		appDrainer := newDrainer(0, 5*time.Second, &stats{})
+		go appDrainer.Watch(server.Shutdown, signals)
+
+		inFlight := get("/slow")
+		Eventually(started).Should(Receive())
+
+		signals <- syscall.SIGTERM
+		Eventually(refused).Should(BeTrue())
+		Expect(appDrainer.Draining()).To(BeTrue())
+		Consistently(appDrainer.Done(), 100*time.Millisecond).ShouldNot(BeClosed())
+
+		release()
+		Eventually(inFlight).Should(Receive(Equal("done")))
+		Eventually(appDrainer.Done()).Should(BeClosed())
+	})
+
+	It("keeps serving new requests for the drain time", func() {
+		drainTime := 300 * time.Millisecond
+		appDrainer := newDrainer(drainTime, 5*time.Second, &stats{})
+		go appDrainer.Watch(server.Shutdown, signals)
+
+		signalled := time.Now()
+		signals <- syscall.SIGTERM
+		Eventually(appDrainer.Draining).Should(BeTrue())
+		Eventually(get("/")).Should(Receive(Equal("done")))
+
+		Eventually(appDrainer.Done()).Should(BeClosed())
+		Expect(time.Since(signalled)).To(BeNumerically(">=", drainTime))
+		Expect(refused()).To(BeTrue())
+	})
+
+	It("stops waiting for in-flight requests after the shutdown timeout", func() {
+		shutdownTimeout := 200 * time.Millisecond
+		appDrainer := newDrainer(0, shutdownTimeout, &stats{})
+		go appDrainer.Watch(server.Shutdown, signals)
+
+		inFlight := get("/slow")
+		Eventually(started).Should(Receive())
+
+		signalled := time.Now()
+		signals <- syscall.SIGTERM
+		Eventually(appDrainer.Done()).Should(BeClosed())
+		Expect(time.Since(signalled)).To(BeNumerically(">=", shutdownTimeout))
+		Expect(time.Since(signalled)).To(BeNumerically("<", time.Second))
+		Expect(inFlight).NotTo(Receive())
+	})
+})
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// stats counts what the app has done since it started. Fields are updated
+// atomically.
+type stats struct {
+	startTime time.Time
+
+	RequestsServed      int64 `json:"requests_served"`
+	RequestsInFlight    int64 `json:"requests_in_flight"`
+	ConnectionsAccepted int64 `json:"connections_accepted"`
+	ConnectionsOpen     int64 `json:"connections_open"`
+	LogsWritten         int64 `json:"logs_written"`
+	RequestsSent        int64 `json:"requests_sent"`
+	RequestsFailed      int64 `json:"requests_failed"`
+}
+
+func newStats() *stats {
+	return &stats{startTime: time.Now()}
+}
+
+func (s *stats) snapshot() stats {
+	return stats{
+		startTime:           s.startTime,
+		RequestsServed:      atomic.LoadInt64(&s.RequestsServed),
+		RequestsInFlight:    atomic.LoadInt64(&s.RequestsInFlight),
+		ConnectionsAccepted: atomic.LoadInt64(&s.ConnectionsAccepted),
+		ConnectionsOpen:     atomic.LoadInt64(&s.ConnectionsOpen),
+		LogsWritten:         atomic.LoadInt64(&s.LogsWritten),
+		RequestsSent:        atomic.LoadInt64(&s.RequestsSent),
+		RequestsFailed:      atomic.LoadInt64(&s.RequestsFailed),
+	}
+}
+
+// CountServed wraps an app handler to count the requests it serves and the
+// ones it is still serving.
+func (s *stats) CountServed(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.RequestsServed, 1)
+		atomic.AddInt64(&s.RequestsInFlight, 1)
+		defer atomic.AddInt64(&s.RequestsInFlight, -1)
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+type statsResponse struct {
+	stats
+	UptimeSeconds    float64         `json:"uptime_seconds"`
+	MemoryBaselineMB int             `json:"memory_baseline_allocated_mb"`
+	MemoryLeakedMB   int             `json:"memory_leaked_mb"`
+	Settings         controlSettings `json:"settings"`
+	Health           string          `json:"health"`
+	Draining         bool            `json:"draining"`
+
+	Endpoints map[string]endpointCounts `json:"endpoints"`
+}
+
+type statsHandler struct {
+	stats     *stats
+	control   *control
+	endpoints *endpointPool
+	drainer   *drainer
+}
+
+func (h statsHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	snapshot := h.stats.snapshot()
+	health, _ := h.control.health.watch()
+	baselineMB, leakedMB := h.control.memory.AllocatedMB()
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(statsResponse{
+		stats:            snapshot,
+		UptimeSeconds:    time.Since(snapshot.startTime).Seconds(),
+		MemoryBaselineMB: baselineMB,
+		MemoryLeakedMB:   leakedMB,
+		Settings:         h.control.settings(),
+		Health:           health,
+		Draining:         h.drainer.Draining(),
+		Endpoints:        h.endpoints.Counts(),
+	})
+}
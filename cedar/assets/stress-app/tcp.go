@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	httpListener  = "http"
+	http2Listener = "http2"
+	tcpListener   = "tcp"
+)
+
+// tcpEchoServer echoes everything written to it back to the client, for
+// testing TCP routing. Like http.Server it can Serve a new listener after the
+// previous one is closed, and Serve returns http.ErrServerClosed after
+// Shutdown.
+type tcpEchoServer struct {
+	stats *stats
+
+	mutex    sync.Mutex
+	shutdown bool
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	active   sync.WaitGroup
+}
+
+func newTCPEchoServer(appStats *stats) *tcpEchoServer {
+	return &tcpEchoServer{
+		stats: appStats,
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+func (s *tcpEchoServer) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.shutdown {
+		s.mutex.Unlock()
+		listener.Close()
+		return http.ErrServerClosed
+	}
+	s.listener = listener
+	s.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			if s.shutdown {
+				return http.ErrServerClosed
+			}
+			return err
+		}
+
+		if !s.track(conn) {
+			conn.Close()
+			continue
+		}
+		go s.echo(conn)
+	}
+}
+
+func (s *tcpEchoServer) track(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.shutdown {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.active.Add(1)
+	return true
+}
+
+func (s *tcpEchoServer) echo(conn net.Conn) {
+	atomic.AddInt64(&s.stats.ConnectionsAccepted, 1)
+	atomic.AddInt64(&s.stats.ConnectionsOpen, 1)
+	defer func() {
+		conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		atomic.AddInt64(&s.stats.ConnectionsOpen, -1)
+		s.active.Done()
+	}()
+
+	io.Copy(conn, conn)
+}
+
+// Shutdown stops accepting connections and waits for open ones to be closed
+// by their clients, closing whatever is left when ctx is done.
+func (s *tcpEchoServer) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.shutdown = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.mutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		s.mutex.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mutex.Unlock()
+		return ctx.Err()
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
@@ -21,12 +22,18 @@ type CFClient interface {
 	Cf(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error)
 	Cleanup(ctx context.Context)
 	Pool() chan string
+	WithTarget(target Target) CFClient
 }
 
 type CFPooledClient struct {
 	poolSize int
 	pool     chan string
 	homeDir  string
+
+	// targets are the org and space each pool slot was last pointed at by
+	// WithTarget, until Cf resets the slot.
+	targets      map[string]Target
+	targetsMutex sync.Mutex
 }
 
 func NewCfClient(ctx context.Context, poolSize int) CFClient {
@@ -65,6 +72,7 @@ func NewCfClient(ctx context.Context, poolSize int) CFClient {
 		homeDir:  homeDir,
 		pool:     pool,
 		poolSize: poolSize,
+		targets:  map[string]Target{},
 	}
 }
 
@@ -72,10 +80,78 @@ func (cfcli *CFPooledClient) Pool() chan string {
 	return cfcli.pool
 }
 
+// Cf runs a cf command in the org and space targeted by the cf config the
+// client was created from. A pool slot that WithTarget pointed elsewhere gets
+// that config back first.
 func (cfcli *CFPooledClient) Cf(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
 	cfDir := <-cfcli.pool
 	defer func() { cfcli.pool <- cfDir }()
 
+	if err := cfcli.resetTarget(cfDir); err != nil {
+		logger.Error("failed-resetting-target", err, lager.Data{"cfdir": cfDir})
+		return nil, err
+	}
+	return cfcli.run(logger, ctx, timeout, cfDir, args...)
+}
+
+// resetTarget copies the cf config from the home directory back into a pool
+// slot that was pointed at another org and space. The slot must be taken
+// from the pool.
+func (cfcli *CFPooledClient) resetTarget(cfDir string) error {
+	cfcli.targetsMutex.Lock()
+	_, targeted := cfcli.targets[cfDir]
+	cfcli.targetsMutex.Unlock()
+	if !targeted {
+		return nil
+	}
+
+	config, err := ioutil.ReadFile(filepath.Join(cfcli.homeDir, ".cf", "config.json"))
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(cfDir, ".cf", "config.json"), config, 0600); err != nil {
+		return err
+	}
+
+	cfcli.targetsMutex.Lock()
+	delete(cfcli.targets, cfDir)
+	cfcli.targetsMutex.Unlock()
+	return nil
+}
+
+// WithTarget returns a client that runs cf commands in the given org and
+// space, pointing each pool slot at it before use.
+func (cfcli *CFPooledClient) WithTarget(target Target) CFClient {
+	return &targetedClient{CFPooledClient: cfcli, target: target}
+}
+
+type targetedClient struct {
+	*CFPooledClient
+	target Target
+}
+
+func (c *targetedClient) Cf(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+	cfDir := <-c.pool
+	defer func() { c.pool <- cfDir }()
+
+	c.targetsMutex.Lock()
+	current := c.targets[cfDir]
+	c.targetsMutex.Unlock()
+
+	if current != c.target {
+		_, err := c.run(logger, ctx, timeout, cfDir, "target", "-o", c.target.Org, "-s", c.target.Space)
+		if err != nil {
+			return nil, err
+		}
+
+		c.targetsMutex.Lock()
+		c.targets[cfDir] = c.target
+		c.targetsMutex.Unlock()
+	}
+	return c.run(logger, ctx, timeout, cfDir, args...)
+}
+
+func (cfcli *CFPooledClient) run(logger lager.Logger, ctx context.Context, timeout time.Duration, cfDir string, args ...string) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	cmd := exec.Command("cf", args...)
 	cmd.Env = append(os.Environ(), "CF_HOME="+cfDir)
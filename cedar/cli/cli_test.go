@@ -1,6 +1,10 @@
 package cli_test
 
 import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
 	"time"
 
 	. "code.cloudfoundry.org/diego-stress-tests/cedar/cli"
@@ -72,4 +76,44 @@ var _ = Describe("Cli", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Context("when a targeted client used a pool slot", func() {
+		var path, binDir, homeConfig string
+
+		BeforeEach(func() {
+			client.Cleanup(ctx)
+			client = NewCfClient(ctx, 1)
+
+			// A fake cf that records the target in the slot's config and
+			// prints that config.
+			var err error
+			binDir, err = ioutil.TempDir("", "fake-cf")
+			Expect(err).NotTo(HaveOccurred())
+			script := "#!/bin/sh\nif [ \"$1\" = target ]; then echo \"$@\" > \"$CF_HOME/.cf/config.json\"; fi\ncat \"$CF_HOME/.cf/config.json\"\n"
+			Expect(ioutil.WriteFile(filepath.Join(binDir, "cf"), []byte(script), 0755)).To(Succeed())
+			path = os.Getenv("PATH")
+			os.Setenv("PATH", binDir+string(os.PathListSeparator)+path)
+
+			currentUser, err := user.Current()
+			Expect(err).NotTo(HaveOccurred())
+			homeConfig = filepath.Join(currentUser.HomeDir, ".cf", "config.json")
+		})
+
+		AfterEach(func() {
+			os.Setenv("PATH", path)
+			os.RemoveAll(binDir)
+		})
+
+		It("resets the slot to the home cf config before running an untargeted command", func() {
+			output, err := client.WithTarget(Target{Org: "stress", Space: "cedar"}).Cf(fakeLogger, ctx, 30*time.Second, "apps")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(output)).To(Equal("target -o stress -s cedar\n"))
+
+			output, err = client.Cf(fakeLogger, ctx, 30*time.Second, "apps")
+			Expect(err).NotTo(HaveOccurred())
+			expected, err := ioutil.ReadFile(homeConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(Equal(expected))
+		})
+	})
 })
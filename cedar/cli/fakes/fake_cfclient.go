@@ -41,6 +41,17 @@ type FakeCFClient struct {
 	poolReturnsOnCall map[int]struct {
 		result1 chan string
 	}
+	WithTargetStub        func(target cli.Target) cli.CFClient
+	withTargetMutex       sync.RWMutex
+	withTargetArgsForCall []struct {
+		target cli.Target
+	}
+	withTargetReturns struct {
+		result1 cli.CFClient
+	}
+	withTargetReturnsOnCall map[int]struct {
+		result1 cli.CFClient
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -163,6 +174,54 @@ func (fake *FakeCFClient) PoolReturnsOnCall(i int, result1 chan string) {
 	}{result1}
 }
 
+func (fake *FakeCFClient) WithTarget(target cli.Target) cli.CFClient {
+	fake.withTargetMutex.Lock()
+	ret, specificReturn := fake.withTargetReturnsOnCall[len(fake.withTargetArgsForCall)]
+	fake.withTargetArgsForCall = append(fake.withTargetArgsForCall, struct {
+		target cli.Target
+	}{target})
+	fake.recordInvocation("WithTarget", []interface{}{target})
+	fake.withTargetMutex.Unlock()
+	if fake.WithTargetStub != nil {
+		return fake.WithTargetStub(target)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.withTargetReturns.result1
+}
+
+func (fake *FakeCFClient) WithTargetCallCount() int {
+	fake.withTargetMutex.RLock()
+	defer fake.withTargetMutex.RUnlock()
+	return len(fake.withTargetArgsForCall)
+}
+
+func (fake *FakeCFClient) WithTargetArgsForCall(i int) cli.Target {
+	fake.withTargetMutex.RLock()
+	defer fake.withTargetMutex.RUnlock()
+	return fake.withTargetArgsForCall[i].target
+}
+
+func (fake *FakeCFClient) WithTargetReturns(result1 cli.CFClient) {
+	fake.WithTargetStub = nil
+	fake.withTargetReturns = struct {
+		result1 cli.CFClient
+	}{result1}
+}
+
+func (fake *FakeCFClient) WithTargetReturnsOnCall(i int, result1 cli.CFClient) {
+	fake.WithTargetStub = nil
+	if fake.withTargetReturnsOnCall == nil {
+		fake.withTargetReturnsOnCall = make(map[int]struct {
+			result1 cli.CFClient
+		})
+	}
+	fake.withTargetReturnsOnCall[i] = struct {
+		result1 cli.CFClient
+	}{result1}
+}
+
 func (fake *FakeCFClient) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -172,6 +231,8 @@ func (fake *FakeCFClient) Invocations() map[string][][]interface{} {
 	defer fake.cleanupMutex.RUnlock()
 	fake.poolMutex.RLock()
 	defer fake.poolMutex.RUnlock()
+	fake.withTargetMutex.RLock()
+	defer fake.withTargetMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -0,0 +1,353 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"code.cloudfoundry.org/lager"
+)
+
+var (
+	ErrNoTarget      = errors.New("no org and space targeted")
+	ErrOrgNotFound   = errors.New("org not returned by CC")
+	ErrSpaceNotFound = errors.New("space not returned by CC")
+	ErrQuotaNotFound = errors.New("quota definition not returned by CC")
+)
+
+// appNamesPerQuery keeps the filters of app name queries to a reasonable URL
+// length.
+const appNamesPerQuery = 50
+
+type Target struct {
+	Org   string `json:"org"`
+	Space string `json:"space"`
+}
+
+// GetTarget returns the org and space that cf targets.
+func GetTarget(logger lager.Logger, cfClient CFClient) (Target, error) {
+	logger = logger.Session("get-target")
+	logger.Info("starting")
+	defer logger.Info("finished")
+
+	out, err := cfClient.Cf(logger, context.Background(), 30*time.Second, "target")
+	if err != nil {
+		return Target{}, err
+	}
+
+	var target Target
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(fields[1])
+		switch strings.ToLower(strings.TrimSpace(fields[0])) {
+		case "org":
+			target.Org = value
+		case "space":
+			target.Space = value
+		}
+	}
+	if target.Org == "" || target.Space == "" {
+		return Target{}, ErrNoTarget
+	}
+	return target, nil
+}
+
+// Quota is an org quota, or a space quota when Space is set, and how much
+// memory the org or space already uses. Limits of -1 are unlimited.
+type Quota struct {
+	Org                   string `json:"org"`
+	Space                 string `json:"space,omitempty"`
+	Name                  string `json:"name"`
+	MemoryLimitMB         int64  `json:"memory_limit_mb"`
+	InstanceMemoryLimitMB int64  `json:"instance_memory_limit_mb"`
+	AppInstanceLimit      int    `json:"app_instance_limit"`
+	MemoryUsageMB         int64  `json:"memory_usage_mb"`
+}
+
+// Applies reports whether the quota limits apps pushed to the target.
+func (q Quota) Applies(target Target) bool {
+	return q.Org == target.Org && (q.Space == "" || q.Space == target.Space)
+}
+
+func (q Quota) String() string {
+	if q.Space != "" {
+		return "space quota " + q.Name + " of " + q.Org + "/" + q.Space
+	}
+	return "org quota " + q.Name + " of " + q.Org
+}
+
+type resourcesResponse struct {
+	Resources []resource `json:"resources"`
+}
+
+type resource struct {
+	Metadata struct {
+		Guid string `json:"guid"`
+	} `json:"metadata"`
+	Entity struct {
+		QuotaDefinitionGuid      string `json:"quota_definition_guid"`
+		SpaceQuotaDefinitionGuid string `json:"space_quota_definition_guid"`
+	} `json:"entity"`
+}
+
+type quotaDefinitionResponse struct {
+	Entity struct {
+		Name                string `json:"name"`
+		MemoryLimit         int64  `json:"memory_limit"`
+		InstanceMemoryLimit int64  `json:"instance_memory_limit"`
+		AppInstanceLimit    int    `json:"app_instance_limit"`
+	} `json:"entity"`
+}
+
+type quotaDefinitionsResponse struct {
+	Resources []quotaDefinitionResponse `json:"resources"`
+}
+
+type memoryUsageResponse struct {
+	MemoryUsageInMB int64 `json:"memory_usage_in_mb"`
+}
+
+type spaceSummaryResponse struct {
+	Apps []struct {
+		Memory    int64  `json:"memory"`
+		Instances int64  `json:"instances"`
+		State     string `json:"state"`
+	} `json:"apps"`
+}
+
+// GetQuotas returns the quota of the target's org, followed by the quota of
+// its space if it has one. Without a space only the org quota is returned.
+func GetQuotas(logger lager.Logger, cfClient CFClient, target Target) ([]Quota, error) {
+	logger = logger.Session("get-quotas", lager.Data{"org": target.Org, "space": target.Space})
+	logger.Info("starting")
+	defer logger.Info("finished")
+
+	org, err := getOrg(logger, cfClient, target.Org)
+	if err != nil {
+		return nil, err
+	}
+
+	orgQuota, err := getQuota(logger, cfClient, "/v2/quota_definitions/"+org.Entity.QuotaDefinitionGuid)
+	if err != nil {
+		return nil, err
+	}
+	orgQuota.Org = target.Org
+
+	var usage memoryUsageResponse
+	if err := curl(logger, cfClient, "/v2/organizations/"+org.Metadata.Guid+"/memory_usage", &usage); err != nil {
+		return nil, err
+	}
+	orgQuota.MemoryUsageMB = usage.MemoryUsageInMB
+	quotas := []Quota{orgQuota}
+	if target.Space == "" {
+		return quotas, nil
+	}
+
+	space, err := getSpace(logger, cfClient, org.Metadata.Guid, target.Space)
+	if err != nil {
+		return nil, err
+	}
+	if space.Entity.SpaceQuotaDefinitionGuid == "" {
+		return quotas, nil
+	}
+
+	spaceQuota, err := getQuota(logger, cfClient, "/v2/space_quota_definitions/"+space.Entity.SpaceQuotaDefinitionGuid)
+	if err != nil {
+		return nil, err
+	}
+	spaceQuota.Org, spaceQuota.Space = target.Org, target.Space
+
+	var summary spaceSummaryResponse
+	if err := curl(logger, cfClient, "/v2/spaces/"+space.Metadata.Guid+"/summary", &summary); err != nil {
+		return nil, err
+	}
+	for _, app := range summary.Apps {
+		if app.State == "STARTED" {
+			spaceQuota.MemoryUsageMB += app.Memory * app.Instances
+		}
+	}
+	return append(quotas, spaceQuota), nil
+}
+
+// GetDefaultOrgQuota returns the default quota definition, which orgs that do
+// not exist yet are created with, as the quota of org.
+func GetDefaultOrgQuota(logger lager.Logger, cfClient CFClient, org string) (Quota, error) {
+	logger = logger.Session("get-default-org-quota", lager.Data{"org": org})
+	logger.Info("starting")
+	defer logger.Info("finished")
+
+	var definitions quotaDefinitionsResponse
+	if err := curl(logger, cfClient, "/v2/quota_definitions?q="+url.QueryEscape("name:default"), &definitions); err != nil {
+		return Quota{}, err
+	}
+	if len(definitions.Resources) == 0 {
+		return Quota{}, ErrQuotaNotFound
+	}
+
+	quota := newQuota(definitions.Resources[0])
+	quota.Org = org
+	return quota, nil
+}
+
+func getOrg(logger lager.Logger, cfClient CFClient, org string) (resource, error) {
+	var orgs resourcesResponse
+	if err := curl(logger, cfClient, "/v2/organizations?q="+url.QueryEscape("name:"+org), &orgs); err != nil {
+		return resource{}, err
+	}
+	if len(orgs.Resources) == 0 {
+		return resource{}, ErrOrgNotFound
+	}
+	return orgs.Resources[0], nil
+}
+
+func getSpace(logger lager.Logger, cfClient CFClient, orgGuid, space string) (resource, error) {
+	var spaces resourcesResponse
+	query := "q=" + url.QueryEscape("name:"+space) + "&q=" + url.QueryEscape("organization_guid:"+orgGuid)
+	if err := curl(logger, cfClient, "/v2/spaces?"+query, &spaces); err != nil {
+		return resource{}, err
+	}
+	if len(spaces.Resources) == 0 {
+		return resource{}, ErrSpaceNotFound
+	}
+	return spaces.Resources[0], nil
+}
+
+func getQuota(logger lager.Logger, cfClient CFClient, path string) (Quota, error) {
+	var definition quotaDefinitionResponse
+	if err := curl(logger, cfClient, path, &definition); err != nil {
+		return Quota{}, err
+	}
+	return newQuota(definition), nil
+}
+
+func newQuota(definition quotaDefinitionResponse) Quota {
+	return Quota{
+		Name:                  definition.Entity.Name,
+		MemoryLimitMB:         definition.Entity.MemoryLimit,
+		InstanceMemoryLimitMB: definition.Entity.InstanceMemoryLimit,
+		AppInstanceLimit:      definition.Entity.AppInstanceLimit,
+	}
+}
+
+// CreateSpace creates the target's org and space unless they already exist,
+// and reports which of them it created.
+func CreateSpace(logger lager.Logger, cfClient CFClient, target Target) (createdOrg, createdSpace bool, err error) {
+	logger = logger.Session("create-space", lager.Data{"org": target.Org, "space": target.Space})
+	logger.Info("starting")
+	defer logger.Info("finished")
+
+	org, err := getOrg(logger, cfClient, target.Org)
+	switch err {
+	case nil:
+	case ErrOrgNotFound:
+		if _, err := cfClient.Cf(logger, context.Background(), 30*time.Second, "create-org", target.Org); err != nil {
+			return false, false, err
+		}
+		createdOrg = true
+		if org, err = getOrg(logger, cfClient, target.Org); err != nil {
+			return createdOrg, false, err
+		}
+	default:
+		return false, false, err
+	}
+
+	_, err = getSpace(logger, cfClient, org.Metadata.Guid, target.Space)
+	switch err {
+	case nil:
+		return createdOrg, false, nil
+	case ErrSpaceNotFound:
+		if _, err := cfClient.Cf(logger, context.Background(), 30*time.Second, "create-space", target.Space, "-o", target.Org); err != nil {
+			return createdOrg, false, err
+		}
+		return createdOrg, true, nil
+	default:
+		return createdOrg, false, err
+	}
+}
+
+func DeleteSpace(logger lager.Logger, cfClient CFClient, target Target) error {
+	logger = logger.Session("delete-space", lager.Data{"org": target.Org, "space": target.Space})
+	_, err := cfClient.Cf(logger, context.Background(), 5*time.Minute, "delete-space", target.Space, "-o", target.Org, "-f")
+	return err
+}
+
+func DeleteOrg(logger lager.Logger, cfClient CFClient, org string) error {
+	logger = logger.Session("delete-org", lager.Data{"org": org})
+	_, err := cfClient.Cf(logger, context.Background(), 5*time.Minute, "delete-org", org, "-f")
+	return err
+}
+
+type featureFlagResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// FeatureFlagEnabled reports whether a CC feature flag such as diego_docker
+// is enabled.
+func FeatureFlagEnabled(logger lager.Logger, cfClient CFClient, name string) (bool, error) {
+	logger = logger.Session("feature-flag", lager.Data{"name": name})
+	var flag featureFlagResponse
+	if err := curl(logger, cfClient, "/v2/config/feature_flags/"+name, &flag); err != nil {
+		return false, err
+	}
+	return flag.Enabled, nil
+}
+
+type appsResponse struct {
+	NextURL   *string `json:"next_url"`
+	Resources []struct {
+		Entity struct {
+			Name string `json:"name"`
+		} `json:"entity"`
+	} `json:"resources"`
+}
+
+// FindApps returns which of the given app names are already taken by apps
+// the user can see.
+func FindApps(logger lager.Logger, cfClient CFClient, names []string) ([]string, error) {
+	logger = logger.Session("find-apps", lager.Data{"names": len(names)})
+	logger.Info("starting")
+	defer logger.Info("finished")
+
+	found := []string{}
+	for start := 0; start < len(names); start += appNamesPerQuery {
+		end := start + appNamesPerQuery
+		if end > len(names) {
+			end = len(names)
+		}
+
+		path := "/v2/apps?results-per-page=100&q=" + url.QueryEscape("name IN "+strings.Join(names[start:end], ","))
+		for path != "" {
+			var apps appsResponse
+			if err := curl(logger, cfClient, path, &apps); err != nil {
+				return nil, err
+			}
+			for _, app := range apps.Resources {
+				found = append(found, app.Entity.Name)
+			}
+
+			path = ""
+			if apps.NextURL != nil {
+				path = *apps.NextURL
+			}
+		}
+	}
+	return found, nil
+}
+
+func curl(logger lager.Logger, cfClient CFClient, path string, response interface{}) error {
+	out, err := cfClient.Cf(logger, context.Background(), 30*time.Second, "curl", path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(out, response)
+}
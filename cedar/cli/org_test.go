@@ -0,0 +1,245 @@
+package cli_test
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli/fakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Org", func() {
+	var (
+		cfCli      *fakes.FakeCFClient
+		testLogger *lagertest.TestLogger
+		responses  map[string]string
+	)
+
+	BeforeEach(func() {
+		testLogger = lagertest.NewTestLogger("cfcli")
+		responses = map[string]string{}
+		cfCli = &fakes.FakeCFClient{}
+		cfCli.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+			response, ok := responses[strings.Join(args, " ")]
+			if !ok {
+				return nil, errors.New("unexpected command")
+			}
+			return []byte(response), nil
+		}
+	})
+
+	Describe("GetTarget", func() {
+		It("parses the output of cf target", func() {
+			responses["target"] = `
+api endpoint:   https://api.bosh-lite.com
+api version:    2.75.0
+user:           admin
+org:            stress
+space:          cedar
+`
+			target, err := cli.GetTarget(testLogger, cfCli)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(target).To(Equal(cli.Target{Org: "stress", Space: "cedar"}))
+		})
+
+		It("returns an error when no space is targeted", func() {
+			responses["target"] = "api endpoint:   https://api.bosh-lite.com\norg:            stress\n"
+			_, err := cli.GetTarget(testLogger, cfCli)
+			Expect(err).To(MatchError(cli.ErrNoTarget))
+		})
+	})
+
+	Describe("GetQuotas", func() {
+		target := cli.Target{Org: "stress", Space: "cedar"}
+		spacesQuery := "curl /v2/spaces?q=name%3Acedar&q=organization_guid%3Aorg-guid"
+
+		BeforeEach(func() {
+			responses["curl /v2/organizations?q=name%3Astress"] = `{"resources": [{"metadata": {"guid": "org-guid"}, "entity": {"quota_definition_guid": "quota-guid"}}]}`
+			responses["curl /v2/quota_definitions/quota-guid"] = `{"entity": {"name": "default", "memory_limit": 10240, "instance_memory_limit": -1, "app_instance_limit": 100}}`
+			responses["curl /v2/organizations/org-guid/memory_usage"] = `{"memory_usage_in_mb": 2048}`
+			responses[spacesQuery] = `{"resources": [{"metadata": {"guid": "space-guid"}, "entity": {"space_quota_definition_guid": null}}]}`
+		})
+
+		It("returns the quota and memory usage of the org", func() {
+			quotas, err := cli.GetQuotas(testLogger, cfCli, target)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(quotas).To(Equal([]cli.Quota{{
+				Org:                   "stress",
+				Name:                  "default",
+				MemoryLimitMB:         10240,
+				InstanceMemoryLimitMB: -1,
+				AppInstanceLimit:      100,
+				MemoryUsageMB:         2048,
+			}}))
+		})
+
+		Context("when the space has a quota", func() {
+			BeforeEach(func() {
+				responses[spacesQuery] = `{"resources": [{"metadata": {"guid": "space-guid"}, "entity": {"space_quota_definition_guid": "space-quota-guid"}}]}`
+				responses["curl /v2/space_quota_definitions/space-quota-guid"] = `{"entity": {"name": "small", "memory_limit": 4096, "instance_memory_limit": 1024, "app_instance_limit": -1}}`
+				responses["curl /v2/spaces/space-guid/summary"] = `{"apps": [
+					{"memory": 256, "instances": 2, "state": "STARTED"},
+					{"memory": 1024, "instances": 1, "state": "STOPPED"}
+				]}`
+			})
+
+			It("also returns the quota and memory usage of the space", func() {
+				quotas, err := cli.GetQuotas(testLogger, cfCli, target)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(quotas).To(HaveLen(2))
+				Expect(quotas[1]).To(Equal(cli.Quota{
+					Org:                   "stress",
+					Space:                 "cedar",
+					Name:                  "small",
+					MemoryLimitMB:         4096,
+					InstanceMemoryLimitMB: 1024,
+					AppInstanceLimit:      -1,
+					MemoryUsageMB:         512,
+				}))
+				Expect(quotas[1].String()).To(Equal("space quota small of stress/cedar"))
+				Expect(quotas[1].Applies(target)).To(BeTrue())
+				Expect(quotas[1].Applies(cli.Target{Org: "stress", Space: "other"})).To(BeFalse())
+				Expect(quotas[0].Applies(cli.Target{Org: "stress", Space: "other"})).To(BeTrue())
+			})
+		})
+
+		It("returns only the org quota when no space is given", func() {
+			quotas, err := cli.GetQuotas(testLogger, cfCli, cli.Target{Org: "stress"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(quotas).To(HaveLen(1))
+			Expect(quotas[0].String()).To(Equal("org quota default of stress"))
+		})
+
+		It("returns an error when the org does not exist", func() {
+			responses["curl /v2/organizations?q=name%3Astress"] = `{"resources": []}`
+			_, err := cli.GetQuotas(testLogger, cfCli, target)
+			Expect(err).To(MatchError(cli.ErrOrgNotFound))
+		})
+
+		It("returns an error when the space does not exist", func() {
+			responses[spacesQuery] = `{"resources": []}`
+			_, err := cli.GetQuotas(testLogger, cfCli, target)
+			Expect(err).To(MatchError(cli.ErrSpaceNotFound))
+		})
+	})
+
+	Describe("GetDefaultOrgQuota", func() {
+		query := "curl /v2/quota_definitions?q=name%3Adefault"
+
+		It("returns the default quota definition as the quota of the org", func() {
+			responses[query] = `{"resources": [{"entity": {"name": "default", "memory_limit": 10240, "instance_memory_limit": -1, "app_instance_limit": 100}}]}`
+			quota, err := cli.GetDefaultOrgQuota(testLogger, cfCli, "new-org")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(quota).To(Equal(cli.Quota{
+				Org:                   "new-org",
+				Name:                  "default",
+				MemoryLimitMB:         10240,
+				InstanceMemoryLimitMB: -1,
+				AppInstanceLimit:      100,
+			}))
+		})
+
+		It("returns an error when there is no default quota definition", func() {
+			responses[query] = `{"resources": []}`
+			_, err := cli.GetDefaultOrgQuota(testLogger, cfCli, "new-org")
+			Expect(err).To(MatchError(cli.ErrQuotaNotFound))
+		})
+	})
+
+	Describe("CreateSpace", func() {
+		target := cli.Target{Org: "stress", Space: "cedar"}
+		orgQuery := "curl /v2/organizations?q=name%3Astress"
+		spacesQuery := "curl /v2/spaces?q=name%3Acedar&q=organization_guid%3Aorg-guid"
+
+		BeforeEach(func() {
+			responses[orgQuery] = `{"resources": [{"metadata": {"guid": "org-guid"}}]}`
+			responses[spacesQuery] = `{"resources": [{"metadata": {"guid": "space-guid"}}]}`
+			responses["create-org stress"] = "OK"
+			responses["create-space cedar -o stress"] = "OK"
+		})
+
+		It("uses the org and space when they exist", func() {
+			createdOrg, createdSpace, err := cli.CreateSpace(testLogger, cfCli, target)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(createdOrg).To(BeFalse())
+			Expect(createdSpace).To(BeFalse())
+			Expect(cfCli.CfCallCount()).To(Equal(2))
+		})
+
+		It("creates the space when it does not exist", func() {
+			responses[spacesQuery] = `{"resources": []}`
+			createdOrg, createdSpace, err := cli.CreateSpace(testLogger, cfCli, target)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(createdOrg).To(BeFalse())
+			Expect(createdSpace).To(BeTrue())
+		})
+
+		It("creates the org and the space when the org does not exist", func() {
+			created := false
+			cfCli.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+				command := strings.Join(args, " ")
+				switch {
+				case command == orgQuery && !created:
+					return []byte(`{"resources": []}`), nil
+				case command == "create-org stress":
+					created = true
+				case command == spacesQuery:
+					return []byte(`{"resources": []}`), nil
+				}
+				return []byte(responses[command]), nil
+			}
+
+			createdOrg, createdSpace, err := cli.CreateSpace(testLogger, cfCli, target)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(createdOrg).To(BeTrue())
+			Expect(createdSpace).To(BeTrue())
+		})
+	})
+
+	Describe("FeatureFlagEnabled", func() {
+		It("reports whether the flag is enabled", func() {
+			responses["curl /v2/config/feature_flags/diego_docker"] = `{"name": "diego_docker", "enabled": false}`
+			enabled, err := cli.FeatureFlagEnabled(testLogger, cfCli, "diego_docker")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(enabled).To(BeFalse())
+		})
+	})
+
+	Describe("FindApps", func() {
+		query := func(names ...string) string {
+			return "curl /v2/apps?results-per-page=100&q=" + url.QueryEscape("name IN "+strings.Join(names, ","))
+		}
+
+		It("returns the names that are taken", func() {
+			responses[query("app-1", "app-2", "app-3")] = `{"next_url": "/v2/apps?page=2", "resources": [{"entity": {"name": "app-1"}}]}`
+			responses["curl /v2/apps?page=2"] = `{"next_url": null, "resources": [{"entity": {"name": "app-3"}}]}`
+
+			found, err := cli.FindApps(testLogger, cfCli, []string{"app-1", "app-2", "app-3"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(Equal([]string{"app-1", "app-3"}))
+		})
+
+		It("queries the names in chunks", func() {
+			names := []string{}
+			for i := 0; i < 60; i++ {
+				names = append(names, fmt.Sprintf("app-%d", i))
+			}
+			responses[query(names[:50]...)] = `{"resources": []}`
+			responses[query(names[50:]...)] = `{"resources": [{"entity": {"name": "app-59"}}]}`
+
+			found, err := cli.FindApps(testLogger, cfCli, names)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(Equal([]string{"app-59"}))
+			Expect(cfCli.CfCallCount()).To(Equal(2))
+		})
+	})
+})
@@ -1,19 +1,66 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
-	"os"
+	"math/rand"
 	"time"
 
 	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
 	"code.cloudfoundry.org/lager"
 )
 
+const (
+	// AbortPolicy cancels the whole run once the failure budget is exhausted.
+	AbortPolicy = "abort"
+	// ContinueWithoutStartPolicy stops pushing once the failure budget is
+	// exhausted, but still starts the apps that were pushed.
+	ContinueWithoutStartPolicy = "continue-without-start"
+)
+
+const (
+	// PreflightOff pushes without checking the quotas first.
+	PreflightOff = "off"
+	// PreflightRefuse refuses to run when the apps do not fit in the quotas.
+	PreflightRefuse = "refuse"
+	// PreflightScaleDown drops apps until the rest fit in the quotas.
+	PreflightScaleDown = "scale-down"
+)
+
+const (
+	// PushModeStage uploads and stages the payload of every app.
+	PushModeStage = "stage"
+	// PushModeCopyDroplet stages the payload of one app of each type and
+	// copies its droplet to the other apps of the type.
+	PushModeCopyDroplet = "copy-droplet"
+)
+
+const (
+	BuildpackLifecycle = "buildpack"
+	DockerLifecycle    = "docker"
+)
+
 type AppDefinition struct {
-	ManifestPath  string `json:"manifestPath"`
-	AppNamePrefix string `json:"appNamePrefix"`
-	AppCount      int    `json:"appCount"`
+	ManifestPath  string  `json:"manifestPath,omitempty"`
+	AppNamePrefix string  `json:"appNamePrefix"`
+	AppCount      int     `json:"appCount"`
+	Weight        float64 `json:"weight,omitempty"`
+
+	// Tolerance is the fraction of this type's apps allowed to fail. Types
+	// without one share the global tolerance.
+	Tolerance *float64 `json:"tolerance,omitempty"`
+
+	AppProfile
+}
+
+// Lifecycle is how Diego runs the apps: DockerLifecycle for apps pushed from
+// a docker image, and BuildpackLifecycle otherwise.
+func (d AppDefinition) Lifecycle() string {
+	if d.DockerImage != "" {
+		return DockerLifecycle
+	}
+	return BuildpackLifecycle
 }
 
 //go:generate counterfeiter -o fakes/fake_config.go . Config
@@ -21,7 +68,7 @@ type Config interface {
 	NumBatches() int
 	MaxInFlight() int
 	MaxPollingErrors() int
-	// Tolerance() float64
+	Tolerance() float64
 	AppPayload() string
 	Prefix() string
 	Domain() string
@@ -33,12 +80,21 @@ type Config interface {
 	TotalAppCount() int
 	MaxAllowedFailures() int
 	AppTypes() []AppDefinition
+	WeightedAppCount() int
+	Seed() int64
+	Shuffle() bool
+	FailurePolicy() string
+	Preflight() string
+	PushMode() string
+	Orgs() int
+	SpacesPerOrg() int
 }
 
 type config struct {
 	numBatches            int
 	maxInFlight           int
 	maxPollingErrors      int
+	weightedAppCount      int
 	tolerance             float64
 	domain                string
 	useTLS                bool
@@ -48,32 +104,64 @@ type config struct {
 	configFile            string
 	outputFile            string
 	timeout               time.Duration
+	seed                  int64
+	shuffle               bool
+	failurePolicy         string
+	preflight             string
+	pushMode              string
+	orgs                  int
+	spacesPerOrg          int
 
 	appTypes []AppDefinition
 }
 
-func NewConfig(
-	logger lager.Logger,
-	cfClient cli.CFClient,
-	numBatches, maxInFlight, maxPollingErrors int,
-	tolerance float64,
-	appPayload, prefix, domain, configFile, outputFile string,
-	timeout time.Duration,
-	useTLS, skipVerifyCertificate bool,
-) (Config, error) {
+// Options are the run settings given on the command line, after the config
+// file's settings have been applied to them.
+type Options struct {
+	NumBatches            int
+	MaxInFlight           int
+	MaxPollingErrors      int
+	WeightedAppCount      int
+	Orgs                  int
+	SpacesPerOrg          int
+	Tolerance             float64
+	AppPayload            string
+	Prefix                string
+	Domain                string
+	ConfigFile            string
+	OutputFile            string
+	FailurePolicy         string
+	Preflight             string
+	PushMode              string
+	Timeout               time.Duration
+	Seed                  int64
+	UseTLS                bool
+	SkipVerifyCertificate bool
+	Shuffle               bool
+}
+
+func NewConfig(logger lager.Logger, cfClient cli.CFClient, opts Options) (Config, error) {
 	c := &config{
-		numBatches:            numBatches,
-		maxInFlight:           maxInFlight,
-		maxPollingErrors:      maxPollingErrors,
-		tolerance:             tolerance,
-		appPayload:            appPayload,
-		prefix:                prefix,
-		domain:                domain,
-		useTLS:                useTLS,
-		skipVerifyCertificate: skipVerifyCertificate,
-		configFile:            configFile,
-		outputFile:            outputFile,
-		timeout:               timeout,
+		numBatches:            opts.NumBatches,
+		maxInFlight:           opts.MaxInFlight,
+		maxPollingErrors:      opts.MaxPollingErrors,
+		weightedAppCount:      opts.WeightedAppCount,
+		tolerance:             opts.Tolerance,
+		appPayload:            opts.AppPayload,
+		prefix:                opts.Prefix,
+		domain:                opts.Domain,
+		useTLS:                opts.UseTLS,
+		skipVerifyCertificate: opts.SkipVerifyCertificate,
+		configFile:            opts.ConfigFile,
+		outputFile:            opts.OutputFile,
+		timeout:               opts.Timeout,
+		seed:                  opts.Seed,
+		shuffle:               opts.Shuffle,
+		failurePolicy:         opts.FailurePolicy,
+		preflight:             opts.Preflight,
+		pushMode:              opts.PushMode,
+		orgs:                  opts.Orgs,
+		spacesPerOrg:          opts.SpacesPerOrg,
 	}
 	err := c.init(logger, cfClient)
 	if err != nil {
@@ -98,6 +186,10 @@ func (c *config) AppTypes() []AppDefinition {
 	return c.appTypes
 }
 
+func (c *config) Tolerance() float64 {
+	return c.tolerance
+}
+
 func (c *config) MaxAllowedFailures() int {
 	return int(math.Floor(c.tolerance * float64(c.TotalAppCount())))
 }
@@ -106,7 +198,60 @@ func (c *config) MaxInFlight() int {
 	return c.maxInFlight
 }
 
+// WeightedAppCount is the number of apps to generate across all batches with
+// the app types picked by weight, or 0 to generate each type's AppCount apps
+// in every batch.
+func (c *config) WeightedAppCount() int {
+	return c.weightedAppCount
+}
+
+// Seed seeds every random choice made while generating apps, so a run can be
+// reproduced.
+func (c *config) Seed() int64 {
+	return c.seed
+}
+
+// Shuffle reports whether apps are pushed in random order. Weighted app
+// mixes are always shuffled.
+func (c *config) Shuffle() bool {
+	return c.shuffle || c.weightedAppCount > 0
+}
+
+// FailurePolicy is what happens once the failure budget is exhausted, either
+// AbortPolicy or ContinueWithoutStartPolicy.
+func (c *config) FailurePolicy() string {
+	return c.failurePolicy
+}
+
+// PushMode is how the apps get their droplets: PushModeStage or
+// PushModeCopyDroplet.
+func (c *config) PushMode() string {
+	return c.pushMode
+}
+
+// Preflight is how the quotas are checked before pushing: PreflightOff,
+// PreflightRefuse or PreflightScaleDown.
+func (c *config) Preflight() string {
+	return c.preflight
+}
+
+// Orgs is the number of orgs the batches are distributed across, or 0 to
+// push every app to the space cf targets.
+func (c *config) Orgs() int {
+	return c.orgs
+}
+
+// SpacesPerOrg is the number of spaces in each org the batches are
+// distributed across.
+func (c *config) SpacesPerOrg() int {
+	return c.spacesPerOrg
+}
+
 func (c *config) TotalAppCount() int {
+	if c.weightedAppCount > 0 {
+		return c.weightedAppCount
+	}
+
 	var totalAppCount int
 	for _, appDef := range c.appTypes {
 		totalAppCount += appDef.AppCount
@@ -142,13 +287,122 @@ func (c *config) AppPayload() string {
 func (c *config) init(logger lager.Logger, cfClient cli.CFClient) error {
 	logger = logger.Session("config")
 
-	c.setAppDefinitionTypes(logger)
+	if err := c.validateFailurePolicy(); err != nil {
+		logger.Error("invalid-failure-policy", err)
+		return err
+	}
+	if c.orgs < 0 || (c.orgs > 0 && c.spacesPerOrg < 1) {
+		err := errors.New("orgs must not be negative and every org needs at least one space")
+		logger.Error("invalid-spaces", err)
+		return err
+	}
+	if err := c.validatePreflight(); err != nil {
+		logger.Error("invalid-preflight", err)
+		return err
+	}
+	if err := c.validatePushMode(); err != nil {
+		logger.Error("invalid-push-mode", err)
+		return err
+	}
+	if err := c.setAppDefinitionTypes(logger); err != nil {
+		return err
+	}
+	if err := c.validateWeights(); err != nil {
+		logger.Error("invalid-app-weights", err)
+		return err
+	}
+	if c.seed == 0 {
+		c.seed = time.Now().UnixNano()
+	}
+	logger.Info("seed", lager.Data{"seed": c.seed})
+	if err := c.validateDockerManifests(); err != nil {
+		logger.Error("invalid-docker-manifest", err)
+		return err
+	}
 	if err := c.initializeDomain(logger, cfClient); err != nil {
 		return err
 	}
 	return nil
 }
 
+func (c *config) validateFailurePolicy() error {
+	switch c.failurePolicy {
+	case "":
+		c.failurePolicy = AbortPolicy
+	case AbortPolicy, ContinueWithoutStartPolicy:
+	default:
+		return fmt.Errorf("unknown failure policy %q", c.failurePolicy)
+	}
+	return nil
+}
+
+func (c *config) validatePreflight() error {
+	switch c.preflight {
+	case "":
+		c.preflight = PreflightOff
+	case PreflightOff, PreflightRefuse, PreflightScaleDown:
+	default:
+		return fmt.Errorf("unknown preflight %q", c.preflight)
+	}
+	return nil
+}
+
+func (c *config) validatePushMode() error {
+	switch c.pushMode {
+	case "":
+		c.pushMode = PushModeStage
+	case PushModeStage, PushModeCopyDroplet:
+	default:
+		return fmt.Errorf("unknown push mode %q", c.pushMode)
+	}
+	return nil
+}
+
+func (c *config) validateWeights() error {
+	for _, appDef := range c.appTypes {
+		if appDef.Weight < 0 {
+			return fmt.Errorf("app type %s has a negative weight of %g", appDef.AppNamePrefix, appDef.Weight)
+		}
+	}
+	if c.weightedAppCount <= 0 {
+		return nil
+	}
+	for _, appDef := range c.appTypes {
+		if appDef.Weight > 0 {
+			return nil
+		}
+	}
+	return errors.New("a weighted app count needs at least one app type with a weight")
+}
+
+// validateDockerManifests rejects docker app types whose manifest file sets a
+// buildpack, which cf push refuses to combine with a docker image. The
+// manifest is rendered with one draw of the app type's variables.
+func (c *config) validateDockerManifests() error {
+	random := rand.New(rand.NewSource(c.seed))
+	for _, appDef := range c.appTypes {
+		if appDef.Lifecycle() != DockerLifecycle || appDef.ManifestPath == "" {
+			continue
+		}
+
+		manifest, err := appDef.RenderManifest(TemplateVariables{
+			AppNamePrefix: appDef.AppNamePrefix,
+			Vars:          appDef.DrawVariables(random),
+		})
+		if err != nil {
+			return err
+		}
+		setsBuildpack, err := manifestSetsBuildpack(manifest)
+		if err != nil {
+			return fmt.Errorf("%s: %s", appDef.ManifestPath, err)
+		}
+		if setsBuildpack {
+			return fmt.Errorf("docker app type %q cannot use %s, which sets a buildpack", appDef.AppNamePrefix, appDef.ManifestPath)
+		}
+	}
+	return nil
+}
+
 func (c *config) initializeDomain(logger lager.Logger, cfClient cli.CFClient) error {
 	if c.domain == "" {
 		var err error
@@ -161,19 +415,14 @@ func (c *config) initializeDomain(logger lager.Logger, cfClient cli.CFClient) er
 	return nil
 }
 
-func (c *config) setAppDefinitionTypes(logger lager.Logger) {
-	conf, err := os.Open(c.configFile)
-	defer conf.Close()
-
+func (c *config) setAppDefinitionTypes(logger lager.Logger) error {
+	file, err := LoadFile(c.configFile)
 	if err != nil {
-		logger.Error("error-opening-config-file", err)
-		panic("error-opening-config-file")
+		logger.Error("error-loading-config-file", err)
+		return err
 	}
 
-	jsonParser := json.NewDecoder(conf)
-	if err = jsonParser.Decode(&c.appTypes); err != nil {
-		logger.Error("error-parsing-config-file", err)
-		panic("error-parsing-config-file")
-	}
-	logger.Info("app-types", lager.Data{"size": len(c.appTypes)})
+	c.appTypes = file.Apps
+	logger.Info("app-types", lager.Data{"size": len(c.appTypes), "version": file.Version})
+	return nil
 }
@@ -1,6 +1,8 @@
 package config_test
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"time"
 
 	"code.cloudfoundry.org/diego-stress-tests/cedar/cli/fakes"
@@ -13,44 +15,32 @@ import (
 var _ = Describe("Cedar", func() {
 	// sample config json file, read and verify, calculating timeout
 	var (
-		config                                             Config
-		err                                                error
-		cfClient                                           *fakes.FakeCFClient
-		numBatches, maxInFlight, maxPollingErrors          int
-		tolerance                                          float64
-		domain, appPayload, prefix, configFile, outputFile string
-		timeout                                            time.Duration
-		useTLS                                             bool
-		skipVerifyCertificate                              bool
+		config   Config
+		err      error
+		cfClient *fakes.FakeCFClient
+		opts     Options
 	)
 
 	BeforeEach(func() {
-		numBatches = 1
-		maxInFlight = 1
-		maxPollingErrors = 1
-		tolerance = 0.5
-		domain = "bosh-lite.com"
-		useTLS = false
-		skipVerifyCertificate = false
-		appPayload = "assets/temp-app"
-		prefix = "cedarapp"
-		configFile = fakeConfigFile
-		outputFile = "tmp/output.json"
-		timeout = 30 * time.Second
+		opts = Options{
+			NumBatches:       1,
+			MaxInFlight:      1,
+			MaxPollingErrors: 1,
+			SpacesPerOrg:     1,
+			Seed:             42,
+			Tolerance:        0.5,
+			Domain:           "bosh-lite.com",
+			AppPayload:       "assets/temp-app",
+			Prefix:           "cedarapp",
+			ConfigFile:       fakeConfigFile,
+			OutputFile:       "tmp/output.json",
+			Timeout:          30 * time.Second,
+		}
 		cfClient = &fakes.FakeCFClient{}
 	})
 
 	JustBeforeEach(func() {
-		config, err = NewConfig(
-			fakeLogger,
-			cfClient,
-			numBatches, maxInFlight, maxPollingErrors,
-			tolerance,
-			appPayload, prefix, domain, configFile, outputFile,
-			timeout,
-			useTLS,
-			skipVerifyCertificate,
-		)
+		config, err = NewConfig(fakeLogger, cfClient, opts)
 	})
 
 	Context("when passing in a json config", func() {
@@ -90,7 +80,7 @@ var _ = Describe("Cedar", func() {
 
 		Context("if the domain is not set", func() {
 			BeforeEach(func() {
-				domain = ""
+				opts.Domain = ""
 			})
 
 			It("gets shared domains from the cf client", func() {
@@ -100,4 +90,181 @@ var _ = Describe("Cedar", func() {
 			})
 		})
 	})
+
+	Context("when generating a weighted app mix", func() {
+		BeforeEach(func() {
+			opts.WeightedAppCount = 20
+			opts.ConfigFile = filepath.Join(filepath.Dir(fakeConfigFile), "weighted-config.json")
+			Expect(ioutil.WriteFile(opts.ConfigFile, []byte(`{
+				"version": 2,
+				"apps": [
+					{"appNamePrefix": "light", "weight": 0.7},
+					{"appNamePrefix": "heavy", "weight": 0.3}
+				]
+			}`), 0644)).To(Succeed())
+		})
+
+		It("uses the weighted app count as the total", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.TotalAppCount()).To(Equal(20))
+			Expect(config.MaxAllowedFailures()).To(Equal(10))
+		})
+
+		It("always shuffles", func() {
+			Expect(config.Shuffle()).To(BeTrue())
+		})
+
+		Context("when no app type has a weight", func() {
+			BeforeEach(func() {
+				opts.ConfigFile = fakeConfigFile
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ContainSubstring("at least one app type with a weight")))
+			})
+		})
+
+		Context("when an app type has a negative weight", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(opts.ConfigFile, []byte(`{
+					"version": 2,
+					"apps": [
+						{"appNamePrefix": "light", "weight": 0.7},
+						{"appNamePrefix": "heavy", "weight": -0.3}
+					]
+				}`), 0644)).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ContainSubstring("apps[1].weight must not be negative")))
+			})
+		})
+	})
+
+	Context("when no seed is given", func() {
+		BeforeEach(func() {
+			opts.Seed = 0
+		})
+
+		It("picks one", func() {
+			Expect(config.Seed()).NotTo(BeZero())
+		})
+	})
+
+	Context("when no failure policy is given", func() {
+		It("aborts", func() {
+			Expect(config.FailurePolicy()).To(Equal(AbortPolicy))
+		})
+	})
+
+	Context("when the failure policy is unknown", func() {
+		BeforeEach(func() {
+			opts.FailurePolicy = "retry"
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(`unknown failure policy "retry"`))
+		})
+	})
+
+	Context("when no preflight is given", func() {
+		It("does not check the quotas", func() {
+			Expect(config.Preflight()).To(Equal(PreflightOff))
+		})
+	})
+
+	Context("when the preflight is unknown", func() {
+		BeforeEach(func() {
+			opts.Preflight = "maybe"
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(`unknown preflight "maybe"`))
+		})
+	})
+
+	Context("when no push mode is given", func() {
+		It("stages every app", func() {
+			Expect(config.PushMode()).To(Equal(PushModeStage))
+		})
+	})
+
+	Context("when the push mode is unknown", func() {
+		BeforeEach(func() {
+			opts.PushMode = "docker"
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(`unknown push mode "docker"`))
+		})
+	})
+
+	Context("when distributing apps across orgs without spaces", func() {
+		BeforeEach(func() {
+			opts.Orgs = 2
+			opts.SpacesPerOrg = 0
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("at least one space")))
+		})
+	})
+
+	Context("when a docker app type uses a manifest file", func() {
+		var manifestPath string
+
+		BeforeEach(func() {
+			dir := filepath.Dir(fakeConfigFile)
+			manifestPath = filepath.Join(dir, "manifest-docker.yml")
+			opts.ConfigFile = filepath.Join(dir, "docker-config.json")
+			Expect(ioutil.WriteFile(opts.ConfigFile, []byte(`{
+				"version": 2,
+				"apps": [
+					{"appNamePrefix": "docker", "appCount": 1, "dockerImage": "stress-app", "manifestPath": "`+manifestPath+`"}
+				]
+			}`), 0644)).To(Succeed())
+		})
+
+		Context("that does not set a buildpack", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(manifestPath, []byte("applications:\n- memory: 128M\n"), 0644)).To(Succeed())
+			})
+
+			It("accepts it", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("that sets a buildpack", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(manifestPath, []byte("applications:\n- memory: 128M\n  buildpack: binary_buildpack\n"), 0644)).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ContainSubstring(`docker app type "docker" cannot use`)))
+			})
+		})
+
+		Context("that sets buildpacks at the top level", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(manifestPath, []byte("buildpacks: [binary_buildpack]\napplications:\n- memory: 128M\n"), 0644)).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ContainSubstring("which sets a buildpack")))
+			})
+		})
+	})
+
+	Context("when the config file is invalid", func() {
+		BeforeEach(func() {
+			opts.ConfigFile = filepath.Join(filepath.Dir(fakeConfigFile), "invalid-config.json")
+			Expect(ioutil.WriteFile(opts.ConfigFile, []byte(`{"version": 2, "apps": []}`), 0644)).To(Succeed())
+		})
+
+		It("returns a validation error", func() {
+			Expect(err).To(MatchError(ContainSubstring("at least one app is required")))
+			Expect(config).To(BeNil())
+		})
+	})
 })
@@ -117,6 +117,15 @@ type FakeConfig struct {
 	maxAllowedFailuresReturnsOnCall map[int]struct {
 		result1 int
 	}
+	ToleranceStub        func() float64
+	toleranceMutex       sync.RWMutex
+	toleranceArgsForCall []struct{}
+	toleranceReturns     struct {
+		result1 float64
+	}
+	toleranceReturnsOnCall map[int]struct {
+		result1 float64
+	}
 	AppTypesStub        func() []config.AppDefinition
 	appTypesMutex       sync.RWMutex
 	appTypesArgsForCall []struct{}
@@ -126,6 +135,78 @@ type FakeConfig struct {
 	appTypesReturnsOnCall map[int]struct {
 		result1 []config.AppDefinition
 	}
+	WeightedAppCountStub        func() int
+	weightedAppCountMutex       sync.RWMutex
+	weightedAppCountArgsForCall []struct{}
+	weightedAppCountReturns     struct {
+		result1 int
+	}
+	weightedAppCountReturnsOnCall map[int]struct {
+		result1 int
+	}
+	SeedStub        func() int64
+	seedMutex       sync.RWMutex
+	seedArgsForCall []struct{}
+	seedReturns     struct {
+		result1 int64
+	}
+	seedReturnsOnCall map[int]struct {
+		result1 int64
+	}
+	ShuffleStub        func() bool
+	shuffleMutex       sync.RWMutex
+	shuffleArgsForCall []struct{}
+	shuffleReturns     struct {
+		result1 bool
+	}
+	shuffleReturnsOnCall map[int]struct {
+		result1 bool
+	}
+	FailurePolicyStub        func() string
+	failurePolicyMutex       sync.RWMutex
+	failurePolicyArgsForCall []struct{}
+	failurePolicyReturns     struct {
+		result1 string
+	}
+	failurePolicyReturnsOnCall map[int]struct {
+		result1 string
+	}
+	PreflightStub        func() string
+	preflightMutex       sync.RWMutex
+	preflightArgsForCall []struct{}
+	preflightReturns     struct {
+		result1 string
+	}
+	preflightReturnsOnCall map[int]struct {
+		result1 string
+	}
+	OrgsStub        func() int
+	orgsMutex       sync.RWMutex
+	orgsArgsForCall []struct{}
+	orgsReturns     struct {
+		result1 int
+	}
+	orgsReturnsOnCall map[int]struct {
+		result1 int
+	}
+	SpacesPerOrgStub        func() int
+	spacesPerOrgMutex       sync.RWMutex
+	spacesPerOrgArgsForCall []struct{}
+	spacesPerOrgReturns     struct {
+		result1 int
+	}
+	spacesPerOrgReturnsOnCall map[int]struct {
+		result1 int
+	}
+	PushModeStub        func() string
+	pushModeMutex       sync.RWMutex
+	pushModeArgsForCall []struct{}
+	pushModeReturns     struct {
+		result1 string
+	}
+	pushModeReturnsOnCall map[int]struct {
+		result1 string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -610,6 +691,46 @@ func (fake *FakeConfig) MaxAllowedFailuresReturnsOnCall(i int, result1 int) {
 	}{result1}
 }
 
+func (fake *FakeConfig) Tolerance() float64 {
+	fake.toleranceMutex.Lock()
+	ret, specificReturn := fake.toleranceReturnsOnCall[len(fake.toleranceArgsForCall)]
+	fake.toleranceArgsForCall = append(fake.toleranceArgsForCall, struct{}{})
+	fake.recordInvocation("Tolerance", []interface{}{})
+	fake.toleranceMutex.Unlock()
+	if fake.ToleranceStub != nil {
+		return fake.ToleranceStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.toleranceReturns.result1
+}
+
+func (fake *FakeConfig) ToleranceCallCount() int {
+	fake.toleranceMutex.RLock()
+	defer fake.toleranceMutex.RUnlock()
+	return len(fake.toleranceArgsForCall)
+}
+
+func (fake *FakeConfig) ToleranceReturns(result1 float64) {
+	fake.ToleranceStub = nil
+	fake.toleranceReturns = struct {
+		result1 float64
+	}{result1}
+}
+
+func (fake *FakeConfig) ToleranceReturnsOnCall(i int, result1 float64) {
+	fake.ToleranceStub = nil
+	if fake.toleranceReturnsOnCall == nil {
+		fake.toleranceReturnsOnCall = make(map[int]struct {
+			result1 float64
+		})
+	}
+	fake.toleranceReturnsOnCall[i] = struct {
+		result1 float64
+	}{result1}
+}
+
 func (fake *FakeConfig) AppTypes() []config.AppDefinition {
 	fake.appTypesMutex.Lock()
 	ret, specificReturn := fake.appTypesReturnsOnCall[len(fake.appTypesArgsForCall)]
@@ -650,6 +771,326 @@ func (fake *FakeConfig) AppTypesReturnsOnCall(i int, result1 []config.AppDefinit
 	}{result1}
 }
 
+func (fake *FakeConfig) WeightedAppCount() int {
+	fake.weightedAppCountMutex.Lock()
+	ret, specificReturn := fake.weightedAppCountReturnsOnCall[len(fake.weightedAppCountArgsForCall)]
+	fake.weightedAppCountArgsForCall = append(fake.weightedAppCountArgsForCall, struct{}{})
+	fake.recordInvocation("WeightedAppCount", []interface{}{})
+	fake.weightedAppCountMutex.Unlock()
+	if fake.WeightedAppCountStub != nil {
+		return fake.WeightedAppCountStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.weightedAppCountReturns.result1
+}
+
+func (fake *FakeConfig) WeightedAppCountCallCount() int {
+	fake.weightedAppCountMutex.RLock()
+	defer fake.weightedAppCountMutex.RUnlock()
+	return len(fake.weightedAppCountArgsForCall)
+}
+
+func (fake *FakeConfig) WeightedAppCountReturns(result1 int) {
+	fake.WeightedAppCountStub = nil
+	fake.weightedAppCountReturns = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakeConfig) WeightedAppCountReturnsOnCall(i int, result1 int) {
+	fake.WeightedAppCountStub = nil
+	if fake.weightedAppCountReturnsOnCall == nil {
+		fake.weightedAppCountReturnsOnCall = make(map[int]struct {
+			result1 int
+		})
+	}
+	fake.weightedAppCountReturnsOnCall[i] = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakeConfig) Seed() int64 {
+	fake.seedMutex.Lock()
+	ret, specificReturn := fake.seedReturnsOnCall[len(fake.seedArgsForCall)]
+	fake.seedArgsForCall = append(fake.seedArgsForCall, struct{}{})
+	fake.recordInvocation("Seed", []interface{}{})
+	fake.seedMutex.Unlock()
+	if fake.SeedStub != nil {
+		return fake.SeedStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.seedReturns.result1
+}
+
+func (fake *FakeConfig) SeedCallCount() int {
+	fake.seedMutex.RLock()
+	defer fake.seedMutex.RUnlock()
+	return len(fake.seedArgsForCall)
+}
+
+func (fake *FakeConfig) SeedReturns(result1 int64) {
+	fake.SeedStub = nil
+	fake.seedReturns = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *FakeConfig) SeedReturnsOnCall(i int, result1 int64) {
+	fake.SeedStub = nil
+	if fake.seedReturnsOnCall == nil {
+		fake.seedReturnsOnCall = make(map[int]struct {
+			result1 int64
+		})
+	}
+	fake.seedReturnsOnCall[i] = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *FakeConfig) Shuffle() bool {
+	fake.shuffleMutex.Lock()
+	ret, specificReturn := fake.shuffleReturnsOnCall[len(fake.shuffleArgsForCall)]
+	fake.shuffleArgsForCall = append(fake.shuffleArgsForCall, struct{}{})
+	fake.recordInvocation("Shuffle", []interface{}{})
+	fake.shuffleMutex.Unlock()
+	if fake.ShuffleStub != nil {
+		return fake.ShuffleStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.shuffleReturns.result1
+}
+
+func (fake *FakeConfig) ShuffleCallCount() int {
+	fake.shuffleMutex.RLock()
+	defer fake.shuffleMutex.RUnlock()
+	return len(fake.shuffleArgsForCall)
+}
+
+func (fake *FakeConfig) ShuffleReturns(result1 bool) {
+	fake.ShuffleStub = nil
+	fake.shuffleReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeConfig) ShuffleReturnsOnCall(i int, result1 bool) {
+	fake.ShuffleStub = nil
+	if fake.shuffleReturnsOnCall == nil {
+		fake.shuffleReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.shuffleReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeConfig) FailurePolicy() string {
+	fake.failurePolicyMutex.Lock()
+	ret, specificReturn := fake.failurePolicyReturnsOnCall[len(fake.failurePolicyArgsForCall)]
+	fake.failurePolicyArgsForCall = append(fake.failurePolicyArgsForCall, struct{}{})
+	fake.recordInvocation("FailurePolicy", []interface{}{})
+	fake.failurePolicyMutex.Unlock()
+	if fake.FailurePolicyStub != nil {
+		return fake.FailurePolicyStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.failurePolicyReturns.result1
+}
+
+func (fake *FakeConfig) FailurePolicyCallCount() int {
+	fake.failurePolicyMutex.RLock()
+	defer fake.failurePolicyMutex.RUnlock()
+	return len(fake.failurePolicyArgsForCall)
+}
+
+func (fake *FakeConfig) FailurePolicyReturns(result1 string) {
+	fake.FailurePolicyStub = nil
+	fake.failurePolicyReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeConfig) FailurePolicyReturnsOnCall(i int, result1 string) {
+	fake.FailurePolicyStub = nil
+	if fake.failurePolicyReturnsOnCall == nil {
+		fake.failurePolicyReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.failurePolicyReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeConfig) Preflight() string {
+	fake.preflightMutex.Lock()
+	ret, specificReturn := fake.preflightReturnsOnCall[len(fake.preflightArgsForCall)]
+	fake.preflightArgsForCall = append(fake.preflightArgsForCall, struct{}{})
+	fake.recordInvocation("Preflight", []interface{}{})
+	fake.preflightMutex.Unlock()
+	if fake.PreflightStub != nil {
+		return fake.PreflightStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.preflightReturns.result1
+}
+
+func (fake *FakeConfig) PreflightCallCount() int {
+	fake.preflightMutex.RLock()
+	defer fake.preflightMutex.RUnlock()
+	return len(fake.preflightArgsForCall)
+}
+
+func (fake *FakeConfig) PreflightReturns(result1 string) {
+	fake.PreflightStub = nil
+	fake.preflightReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeConfig) PreflightReturnsOnCall(i int, result1 string) {
+	fake.PreflightStub = nil
+	if fake.preflightReturnsOnCall == nil {
+		fake.preflightReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.preflightReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeConfig) Orgs() int {
+	fake.orgsMutex.Lock()
+	ret, specificReturn := fake.orgsReturnsOnCall[len(fake.orgsArgsForCall)]
+	fake.orgsArgsForCall = append(fake.orgsArgsForCall, struct{}{})
+	fake.recordInvocation("Orgs", []interface{}{})
+	fake.orgsMutex.Unlock()
+	if fake.OrgsStub != nil {
+		return fake.OrgsStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.orgsReturns.result1
+}
+
+func (fake *FakeConfig) OrgsCallCount() int {
+	fake.orgsMutex.RLock()
+	defer fake.orgsMutex.RUnlock()
+	return len(fake.orgsArgsForCall)
+}
+
+func (fake *FakeConfig) OrgsReturns(result1 int) {
+	fake.OrgsStub = nil
+	fake.orgsReturns = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakeConfig) OrgsReturnsOnCall(i int, result1 int) {
+	fake.OrgsStub = nil
+	if fake.orgsReturnsOnCall == nil {
+		fake.orgsReturnsOnCall = make(map[int]struct {
+			result1 int
+		})
+	}
+	fake.orgsReturnsOnCall[i] = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakeConfig) SpacesPerOrg() int {
+	fake.spacesPerOrgMutex.Lock()
+	ret, specificReturn := fake.spacesPerOrgReturnsOnCall[len(fake.spacesPerOrgArgsForCall)]
+	fake.spacesPerOrgArgsForCall = append(fake.spacesPerOrgArgsForCall, struct{}{})
+	fake.recordInvocation("SpacesPerOrg", []interface{}{})
+	fake.spacesPerOrgMutex.Unlock()
+	if fake.SpacesPerOrgStub != nil {
+		return fake.SpacesPerOrgStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.spacesPerOrgReturns.result1
+}
+
+func (fake *FakeConfig) SpacesPerOrgCallCount() int {
+	fake.spacesPerOrgMutex.RLock()
+	defer fake.spacesPerOrgMutex.RUnlock()
+	return len(fake.spacesPerOrgArgsForCall)
+}
+
+func (fake *FakeConfig) SpacesPerOrgReturns(result1 int) {
+	fake.SpacesPerOrgStub = nil
+	fake.spacesPerOrgReturns = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakeConfig) SpacesPerOrgReturnsOnCall(i int, result1 int) {
+	fake.SpacesPerOrgStub = nil
+	if fake.spacesPerOrgReturnsOnCall == nil {
+		fake.spacesPerOrgReturnsOnCall = make(map[int]struct {
+			result1 int
+		})
+	}
+	fake.spacesPerOrgReturnsOnCall[i] = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakeConfig) PushMode() string {
+	fake.pushModeMutex.Lock()
+	ret, specificReturn := fake.pushModeReturnsOnCall[len(fake.pushModeArgsForCall)]
+	fake.pushModeArgsForCall = append(fake.pushModeArgsForCall, struct{}{})
+	fake.recordInvocation("PushMode", []interface{}{})
+	fake.pushModeMutex.Unlock()
+	if fake.PushModeStub != nil {
+		return fake.PushModeStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.pushModeReturns.result1
+}
+
+func (fake *FakeConfig) PushModeCallCount() int {
+	fake.pushModeMutex.RLock()
+	defer fake.pushModeMutex.RUnlock()
+	return len(fake.pushModeArgsForCall)
+}
+
+func (fake *FakeConfig) PushModeReturns(result1 string) {
+	fake.PushModeStub = nil
+	fake.pushModeReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeConfig) PushModeReturnsOnCall(i int, result1 string) {
+	fake.PushModeStub = nil
+	if fake.pushModeReturnsOnCall == nil {
+		fake.pushModeReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.pushModeReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeConfig) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -677,8 +1118,26 @@ func (fake *FakeConfig) Invocations() map[string][][]interface{} {
 	defer fake.totalAppCountMutex.RUnlock()
 	fake.maxAllowedFailuresMutex.RLock()
 	defer fake.maxAllowedFailuresMutex.RUnlock()
+	fake.toleranceMutex.RLock()
+	defer fake.toleranceMutex.RUnlock()
 	fake.appTypesMutex.RLock()
 	defer fake.appTypesMutex.RUnlock()
+	fake.weightedAppCountMutex.RLock()
+	defer fake.weightedAppCountMutex.RUnlock()
+	fake.seedMutex.RLock()
+	defer fake.seedMutex.RUnlock()
+	fake.shuffleMutex.RLock()
+	defer fake.shuffleMutex.RUnlock()
+	fake.failurePolicyMutex.RLock()
+	defer fake.failurePolicyMutex.RUnlock()
+	fake.preflightMutex.RLock()
+	defer fake.preflightMutex.RUnlock()
+	fake.orgsMutex.RLock()
+	defer fake.orgsMutex.RUnlock()
+	fake.spacesPerOrgMutex.RLock()
+	defer fake.spacesPerOrgMutex.RUnlock()
+	fake.pushModeMutex.RLock()
+	defer fake.pushModeMutex.RUnlock()
 	return fake.invocations
 }
 
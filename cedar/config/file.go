@@ -0,0 +1,422 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A version 1 config file is a JSON array of AppDefinitions that each point
+// at a manifest. A version 2 file is an object describing the whole run:
+//
+//	{
+//	  "version": 2,
+//	  "settings": {"numBatches": 2, "maxInFlight": 10, "timeout": "60s"},
+//	  "defaults": {"buildpack": "binary_buildpack", "command": "./stress-app"},
+//	  "apps": [
+//	    {"appNamePrefix": "light", "appCount": 9, "memory": "32M", "env": {"LOGS_PER_SECOND": "0"}},
+//	    {"appNamePrefix": "heavy", "appCount": 1, "manifestPath": "assets/manifests/manifest-heavy.yml"},
+//	    {"appNamePrefix": "docker", "appCount": 3, "dockerImage": "registry.local:5000/stress-app"}
+//	  ]
+//	}
+//
+// Settings fill in any command line flags that were not given. Apps either
+// point at a manifest or describe their manifest inline, in which case the
+// defaults apply to every field they leave out. Either way the manifest is a
+// template rendered for each app; see TemplateVariables.
+const (
+	Version1 = 1
+	Version2 = 2
+)
+
+// AppProfile holds the per-app-type settings of a version 2 config file.
+// Timeout and Payload override the global settings, DockerImage pushes the
+// apps from a docker image instead of a payload, Variables are drawn for
+// every app, and the other fields make up the manifest of apps without a
+// manifestPath.
+type AppProfile struct {
+	Timeout     Duration            `json:"timeout,omitempty"`
+	Payload     string              `json:"payload,omitempty"`
+	DockerImage string              `json:"dockerImage,omitempty"`
+	Variables   map[string]Variable `json:"variables,omitempty"`
+
+	Instances       Value            `json:"instances,omitempty"`
+	Memory          Value            `json:"memory,omitempty"`
+	DiskQuota       Value            `json:"diskQuota,omitempty"`
+	Buildpack       Value            `json:"buildpack,omitempty"`
+	Command         Value            `json:"command,omitempty"`
+	HealthCheckType Value            `json:"healthCheckType,omitempty"`
+	Env             map[string]Value `json:"env,omitempty"`
+}
+
+// Settings holds the global settings of a version 2 config file. Unset
+// settings are nil.
+type Settings struct {
+	NumBatches            *int      `json:"numBatches,omitempty"`
+	MaxInFlight           *int      `json:"maxInFlight,omitempty"`
+	MaxPollingErrors      *int      `json:"maxPollingErrors,omitempty"`
+	Tolerance             *float64  `json:"tolerance,omitempty"`
+	Timeout               *Duration `json:"timeout,omitempty"`
+	Payload               *string   `json:"payload,omitempty"`
+	Prefix                *string   `json:"prefix,omitempty"`
+	Domain                *string   `json:"domain,omitempty"`
+	UseTLS                *bool     `json:"useTLS,omitempty"`
+	SkipVerifyCertificate *bool     `json:"skipVerifyCertificate,omitempty"`
+	OutputFile            *string   `json:"outputFile,omitempty"`
+	WeightedAppCount      *int      `json:"weightedAppCount,omitempty"`
+	Seed                  *int64    `json:"seed,omitempty"`
+	Shuffle               *bool     `json:"shuffle,omitempty"`
+	FailurePolicy         *string   `json:"failurePolicy,omitempty"`
+	Preflight             *string   `json:"preflight,omitempty"`
+	PushMode              *string   `json:"pushMode,omitempty"`
+	Orgs                  *int      `json:"orgs,omitempty"`
+	SpacesPerOrg          *int      `json:"spacesPerOrg,omitempty"`
+}
+
+// Flags returns the settings that are set, keyed by the name of the cedar
+// command line flag they correspond to.
+func (s Settings) Flags() map[string]string {
+	flags := map[string]string{}
+	if s.NumBatches != nil {
+		flags["n"] = strconv.Itoa(*s.NumBatches)
+	}
+	if s.MaxInFlight != nil {
+		flags["k"] = strconv.Itoa(*s.MaxInFlight)
+	}
+	if s.MaxPollingErrors != nil {
+		flags["max-polling-errors"] = strconv.Itoa(*s.MaxPollingErrors)
+	}
+	if s.Tolerance != nil {
+		flags["tolerance"] = strconv.FormatFloat(*s.Tolerance, 'g', -1, 64)
+	}
+	if s.Timeout != nil {
+		flags["timeout"] = s.Timeout.String()
+	}
+	if s.Payload != nil {
+		flags["payload"] = *s.Payload
+	}
+	if s.Prefix != nil {
+		flags["prefix"] = *s.Prefix
+	}
+	if s.Domain != nil {
+		flags["domain"] = *s.Domain
+	}
+	if s.UseTLS != nil {
+		flags["use-tls"] = strconv.FormatBool(*s.UseTLS)
+	}
+	if s.SkipVerifyCertificate != nil {
+		flags["skip-verify-certificate"] = strconv.FormatBool(*s.SkipVerifyCertificate)
+	}
+	if s.OutputFile != nil {
+		flags["output"] = *s.OutputFile
+	}
+	if s.WeightedAppCount != nil {
+		flags["total-apps"] = strconv.Itoa(*s.WeightedAppCount)
+	}
+	if s.Seed != nil {
+		flags["seed"] = strconv.FormatInt(*s.Seed, 10)
+	}
+	if s.Shuffle != nil {
+		flags["shuffle"] = strconv.FormatBool(*s.Shuffle)
+	}
+	if s.FailurePolicy != nil {
+		flags["failure-policy"] = *s.FailurePolicy
+	}
+	if s.Preflight != nil {
+		flags["preflight"] = *s.Preflight
+	}
+	if s.PushMode != nil {
+		flags["push-mode"] = *s.PushMode
+	}
+	if s.Orgs != nil {
+		flags["orgs"] = strconv.Itoa(*s.Orgs)
+	}
+	if s.SpacesPerOrg != nil {
+		flags["spaces-per-org"] = strconv.Itoa(*s.SpacesPerOrg)
+	}
+	return flags
+}
+
+type File struct {
+	Version  int             `json:"version"`
+	Settings Settings        `json:"settings"`
+	Defaults AppProfile      `json:"defaults"`
+	Apps     []AppDefinition `json:"apps"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in JSON.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations must be strings such as \"30s\": %s", data)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
+
+// ValidationError lists every problem found in a config file.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid config file:\n  " + strings.Join(e, "\n  ")
+}
+
+// LoadFile reads and validates a config file of either version. The apps of
+// a version 2 file are returned with the defaults applied.
+func LoadFile(path string) (File, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	return ParseFile(content)
+}
+
+func ParseFile(content []byte) (File, error) {
+	var file File
+
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("[")) {
+		file.Version = Version1
+		if err := json.Unmarshal(content, &file.Apps); err != nil {
+			return File{}, err
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return File{}, err
+		}
+	}
+
+	if err := file.validate(); err != nil {
+		return File{}, err
+	}
+
+	for i := range file.Apps {
+		file.Apps[i].AppProfile = file.Apps[i].withDefaults(file.Defaults)
+	}
+	return file, nil
+}
+
+var quotaPattern = regexp.MustCompile(`^(?i)[1-9][0-9]*(M|MB|G|GB)$`)
+
+func (f File) validate() error {
+	var errs ValidationError
+
+	switch f.Version {
+	case Version1, Version2:
+	case 0:
+		errs = append(errs, "version is required")
+	default:
+		errs = append(errs, fmt.Sprintf("unsupported version %d", f.Version))
+	}
+
+	s := f.Settings
+	if s.NumBatches != nil && *s.NumBatches < 1 {
+		errs = append(errs, "settings.numBatches must be at least 1")
+	}
+	if s.MaxInFlight != nil && *s.MaxInFlight < 1 {
+		errs = append(errs, "settings.maxInFlight must be at least 1")
+	}
+	if s.MaxPollingErrors != nil && *s.MaxPollingErrors < 0 {
+		errs = append(errs, "settings.maxPollingErrors must not be negative")
+	}
+	if s.Tolerance != nil && (*s.Tolerance < 0 || *s.Tolerance > 1) {
+		errs = append(errs, "settings.tolerance must be between 0 and 1")
+	}
+	if s.Timeout != nil && s.Timeout.Duration <= 0 {
+		errs = append(errs, "settings.timeout must be positive")
+	}
+	if s.WeightedAppCount != nil && *s.WeightedAppCount < 0 {
+		errs = append(errs, "settings.weightedAppCount must not be negative")
+	}
+	if s.FailurePolicy != nil && *s.FailurePolicy != AbortPolicy && *s.FailurePolicy != ContinueWithoutStartPolicy {
+		errs = append(errs, fmt.Sprintf("settings.failurePolicy must be %q or %q", AbortPolicy, ContinueWithoutStartPolicy))
+	}
+	if s.Orgs != nil && *s.Orgs < 0 {
+		errs = append(errs, "settings.orgs must not be negative")
+	}
+	if s.SpacesPerOrg != nil && *s.SpacesPerOrg < 1 {
+		errs = append(errs, "settings.spacesPerOrg must be at least 1")
+	}
+	if s.PushMode != nil && *s.PushMode != PushModeStage && *s.PushMode != PushModeCopyDroplet {
+		errs = append(errs, fmt.Sprintf("settings.pushMode must be %q or %q", PushModeStage, PushModeCopyDroplet))
+	}
+	if s.Preflight != nil && *s.Preflight != PreflightOff && *s.Preflight != PreflightRefuse && *s.Preflight != PreflightScaleDown {
+		errs = append(errs, fmt.Sprintf("settings.preflight must be %q, %q or %q", PreflightOff, PreflightRefuse, PreflightScaleDown))
+	}
+
+	errs = append(errs, f.Defaults.validate("defaults")...)
+	if f.Defaults.DockerImage != "" {
+		errs = append(errs, "defaults.dockerImage is not supported, set it on the apps that use it")
+	}
+
+	if len(f.Apps) == 0 {
+		errs = append(errs, "at least one app is required")
+	}
+	prefixes := map[string]bool{}
+	for i, app := range f.Apps {
+		field := fmt.Sprintf("apps[%d]", i)
+		if app.AppNamePrefix == "" {
+			errs = append(errs, field+".appNamePrefix is required")
+		} else if prefixes[app.AppNamePrefix] {
+			errs = append(errs, fmt.Sprintf("%s.appNamePrefix %q is used more than once", field, app.AppNamePrefix))
+		}
+		prefixes[app.AppNamePrefix] = true
+
+		if app.AppCount < 0 {
+			errs = append(errs, field+".appCount must not be negative")
+		}
+		if app.Weight < 0 {
+			errs = append(errs, field+".weight must not be negative")
+		}
+		if app.Tolerance != nil && (*app.Tolerance < 0 || *app.Tolerance > 1) {
+			errs = append(errs, field+".tolerance must be between 0 and 1")
+		}
+
+		if app.DockerImage != "" && app.Payload != "" {
+			errs = append(errs, field+" cannot set both payload and dockerImage")
+		}
+		if app.DockerImage != "" && app.Buildpack != "" {
+			errs = append(errs, field+" cannot set both buildpack and dockerImage")
+		}
+
+		switch {
+		case f.Version == Version1 && app.ManifestPath == "":
+			errs = append(errs, field+".manifestPath is required")
+		case app.ManifestPath != "" && app.hasManifestFields():
+			errs = append(errs, field+" cannot set both manifestPath and inline manifest fields")
+		}
+		errs = append(errs, app.AppProfile.validate(field)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p AppProfile) validate(field string) []string {
+	var errs []string
+	if p.Timeout.Duration < 0 {
+		errs = append(errs, field+".timeout must not be negative")
+	}
+
+	values := map[string]Value{
+		"instances":       p.Instances,
+		"memory":          p.Memory,
+		"diskQuota":       p.DiskQuota,
+		"buildpack":       p.Buildpack,
+		"command":         p.Command,
+		"healthCheckType": p.HealthCheckType,
+	}
+	for name, value := range p.Env {
+		values["env."+name] = value
+	}
+	for name, value := range values {
+		if _, err := value.template(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.%s: %s", field, name, err))
+		}
+	}
+
+	// Templated values can only be checked once they are rendered.
+	if !p.Instances.isTemplate() && p.Instances != "" {
+		if instances, err := strconv.Atoi(string(p.Instances)); err != nil || instances < 0 {
+			errs = append(errs, fmt.Sprintf("%s.instances %q must be a non-negative number", field, p.Instances))
+		}
+	}
+	if !p.Memory.isTemplate() && p.Memory != "" && !quotaPattern.MatchString(string(p.Memory)) {
+		errs = append(errs, fmt.Sprintf("%s.memory %q must be a size such as 256M or 1G", field, p.Memory))
+	}
+	if !p.DiskQuota.isTemplate() && p.DiskQuota != "" && !quotaPattern.MatchString(string(p.DiskQuota)) {
+		errs = append(errs, fmt.Sprintf("%s.diskQuota %q must be a size such as 256M or 1G", field, p.DiskQuota))
+	}
+
+	for name, variable := range p.Variables {
+		if err := variable.validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.variables.%s: %s", field, name, err))
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+func (d AppDefinition) hasManifestFields() bool {
+	p := d.AppProfile
+	return p.Instances != "" || p.Memory != "" || p.DiskQuota != "" || p.Buildpack != "" ||
+		p.Command != "" || p.HealthCheckType != "" || len(p.Env) > 0
+}
+
+// withDefaults fills in the fields the app leaves out. Apps with a manifest
+// only take the timeout, payload and variables defaults, and docker apps take
+// neither the payload nor the buildpack.
+func (d AppDefinition) withDefaults(defaults AppProfile) AppProfile {
+	p := d.AppProfile
+	if p.Timeout.Duration == 0 {
+		p.Timeout = defaults.Timeout
+	}
+	if p.Payload == "" && p.DockerImage == "" {
+		p.Payload = defaults.Payload
+	}
+	if len(defaults.Variables) > 0 {
+		variables := map[string]Variable{}
+		for name, variable := range defaults.Variables {
+			variables[name] = variable
+		}
+		for name, variable := range p.Variables {
+			variables[name] = variable
+		}
+		p.Variables = variables
+	}
+	if d.ManifestPath != "" {
+		return p
+	}
+
+	if p.Instances == "" {
+		p.Instances = defaults.Instances
+	}
+	if p.Memory == "" {
+		p.Memory = defaults.Memory
+	}
+	if p.DiskQuota == "" {
+		p.DiskQuota = defaults.DiskQuota
+	}
+	if p.Buildpack == "" && p.DockerImage == "" {
+		p.Buildpack = defaults.Buildpack
+	}
+	if p.Command == "" {
+		p.Command = defaults.Command
+	}
+	if p.HealthCheckType == "" {
+		p.HealthCheckType = defaults.HealthCheckType
+	}
+	if len(defaults.Env) > 0 {
+		env := map[string]Value{}
+		for name, value := range defaults.Env {
+			env[name] = value
+		}
+		for name, value := range p.Env {
+			env[name] = value
+		}
+		p.Env = env
+	}
+	return p
+}
@@ -0,0 +1,162 @@
+package config_test
+
+import (
+	"time"
+
+	. "code.cloudfoundry.org/diego-stress-tests/cedar/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("File", func() {
+	var (
+		content string
+		file    File
+		err     error
+	)
+
+	JustBeforeEach(func() {
+		file, err = ParseFile([]byte(content))
+	})
+
+	Context("when parsing a version 1 file", func() {
+		BeforeEach(func() {
+			content = configContent
+		})
+
+		It("reads the app definitions", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Version).To(Equal(Version1))
+			Expect(file.Apps).To(HaveLen(2))
+			Expect(file.Apps[0].ManifestPath).To(Equal("manifest-light.yml"))
+		})
+	})
+
+	Context("when parsing a version 2 file", func() {
+		BeforeEach(func() {
+			content = `{
+				"version": 2,
+				"settings": {"numBatches": 2, "maxInFlight": 10, "tolerance": 0.25, "timeout": "1m", "useTLS": true, "failurePolicy": "continue-without-start", "preflight": "scale-down", "pushMode": "copy-droplet", "orgs": 2, "spacesPerOrg": 3},
+				"defaults": {
+					"payload": "assets/temp-app",
+					"buildpack": "binary_buildpack",
+					"command": "./stress-app",
+					"memory": "32M",
+					"env": {"LOGS_PER_SECOND": "0", "REQUESTS_PER_SECOND": "0.03"}
+				},
+				"apps": [
+					{"appNamePrefix": "light", "appCount": 9, "instances": 2, "env": {"LOGS_PER_SECOND": "1"}},
+					{"appNamePrefix": "heavy", "appCount": 1, "timeout": "2m", "manifestPath": "manifest-heavy.yml"},
+					{"appNamePrefix": "docker", "appCount": 2, "dockerImage": "registry.local:5000/stress-app"}
+				]
+			}`
+		})
+
+		It("reads the settings", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Version).To(Equal(Version2))
+			Expect(file.Settings.Flags()).To(Equal(map[string]string{
+				"n":              "2",
+				"k":              "10",
+				"tolerance":      "0.25",
+				"timeout":        "1m0s",
+				"use-tls":        "true",
+				"failure-policy": "continue-without-start",
+				"preflight":      "scale-down",
+				"push-mode":      "copy-droplet",
+				"orgs":           "2",
+				"spaces-per-org": "3",
+			}))
+		})
+
+		It("applies the defaults to inline apps", func() {
+			Expect(file.Apps[0].AppProfile).To(Equal(AppProfile{
+				Payload:   "assets/temp-app",
+				Instances: "2",
+				Memory:    "32M",
+				Buildpack: "binary_buildpack",
+				Command:   "./stress-app",
+				Env:       map[string]Value{"LOGS_PER_SECOND": "1", "REQUESTS_PER_SECOND": "0.03"},
+			}))
+		})
+
+		It("only applies the timeout and payload defaults to apps with a manifest", func() {
+			Expect(file.Apps[1].AppProfile).To(Equal(AppProfile{
+				Payload: "assets/temp-app",
+				Timeout: Duration{Duration: 2 * time.Minute},
+			}))
+		})
+
+		It("applies neither the payload nor the buildpack defaults to docker apps", func() {
+			Expect(file.Apps[2].Lifecycle()).To(Equal(DockerLifecycle))
+			Expect(file.Apps[2].AppProfile).To(Equal(AppProfile{
+				DockerImage: "registry.local:5000/stress-app",
+				Memory:      "32M",
+				Command:     "./stress-app",
+				Env:         map[string]Value{"LOGS_PER_SECOND": "0", "REQUESTS_PER_SECOND": "0.03"},
+			}))
+			Expect(file.Apps[0].Lifecycle()).To(Equal(BuildpackLifecycle))
+		})
+
+		It("renders the inline manifest", func() {
+			manifest, err := file.Apps[0].RenderManifest(TemplateVariables{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest).To(MatchJSON(`{
+				"applications": [{
+					"instances": 2,
+					"memory": "32M",
+					"buildpack": "binary_buildpack",
+					"command": "./stress-app",
+					"env": {"LOGS_PER_SECOND": "1", "REQUESTS_PER_SECOND": "0.03"}
+				}]
+			}`))
+		})
+	})
+
+	Context("when the file is invalid", func() {
+		BeforeEach(func() {
+			content = `{
+				"version": 2,
+				"settings": {"tolerance": 2},
+				"apps": [
+					{"appNamePrefix": "light", "appCount": 1, "memory": "lots"},
+					{"appNamePrefix": "light", "appCount": 1, "manifestPath": "manifest.yml", "instances": 2},
+					{"appNamePrefix": "docker", "appCount": 1, "dockerImage": "stress-app", "payload": "assets/temp-app", "buildpack": "binary_buildpack"}
+				]
+			}`
+		})
+
+		It("returns every problem", func() {
+			Expect(err).To(BeAssignableToTypeOf(ValidationError{}))
+			Expect(err.(ValidationError)).To(ConsistOf(
+				"settings.tolerance must be between 0 and 1",
+				`apps[0].memory "lots" must be a size such as 256M or 1G`,
+				`apps[1].appNamePrefix "light" is used more than once`,
+				"apps[1] cannot set both manifestPath and inline manifest fields",
+				"apps[2] cannot set both payload and dockerImage",
+				"apps[2] cannot set both buildpack and dockerImage",
+			))
+		})
+	})
+
+	Context("when the version is not supported", func() {
+		BeforeEach(func() {
+			content = `{"version": 3, "apps": [{"appNamePrefix": "light", "appCount": 1}]}`
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("unsupported version 3")))
+		})
+	})
+
+	Context("when the file has unknown fields", func() {
+		BeforeEach(func() {
+			content = `{"version": 2, "apps": [{"appNamePrefix": "light", "appCount": 1, "memroy": "32M"}]}`
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("memroy")))
+		})
+	})
+})
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Cloud Foundry's defaults for apps whose manifest does not set them.
+const (
+	DefaultInstances = 1
+	DefaultMemoryMB  = 1024
+	DefaultDiskMB    = 1024
+)
+
+// Resources are what an app asks of Cloud Foundry. Memory and disk are per
+// instance.
+type Resources struct {
+	Instances int   `json:"instances"`
+	MemoryMB  int64 `json:"memory_mb"`
+	DiskMB    int64 `json:"disk_mb"`
+}
+
+func (r Resources) TotalMemoryMB() int64 {
+	return int64(r.Instances) * r.MemoryMB
+}
+
+func (r Resources) TotalDiskMB() int64 {
+	return int64(r.Instances) * r.DiskMB
+}
+
+type manifestResources struct {
+	Instances *int   `yaml:"instances"`
+	Memory    string `yaml:"memory"`
+	DiskQuota string `yaml:"disk_quota"`
+}
+
+// ManifestResources reads the resources of the first app in a rendered
+// manifest. Settings at the top level of the manifest apply unless the app
+// overrides them, and Cloud Foundry's defaults fill in the rest.
+func ManifestResources(manifest []byte) (Resources, error) {
+	var parsed struct {
+		manifestResources `yaml:",inline"`
+		Applications      []manifestResources `yaml:"applications"`
+	}
+	if err := yaml.Unmarshal(manifest, &parsed); err != nil {
+		return Resources{}, err
+	}
+
+	settings := parsed.manifestResources
+	if len(parsed.Applications) > 0 {
+		app := parsed.Applications[0]
+		if app.Instances != nil {
+			settings.Instances = app.Instances
+		}
+		if app.Memory != "" {
+			settings.Memory = app.Memory
+		}
+		if app.DiskQuota != "" {
+			settings.DiskQuota = app.DiskQuota
+		}
+	}
+
+	resources := Resources{Instances: DefaultInstances, MemoryMB: DefaultMemoryMB, DiskMB: DefaultDiskMB}
+	if settings.Instances != nil {
+		resources.Instances = *settings.Instances
+	}
+	var err error
+	if settings.Memory != "" {
+		if resources.MemoryMB, err = SizeInMB(settings.Memory); err != nil {
+			return Resources{}, fmt.Errorf("memory: %s", err)
+		}
+	}
+	if settings.DiskQuota != "" {
+		if resources.DiskMB, err = SizeInMB(settings.DiskQuota); err != nil {
+			return Resources{}, fmt.Errorf("disk_quota: %s", err)
+		}
+	}
+	return resources, nil
+}
+
+// SizeInMB converts a size such as 256M or 1G to megabytes.
+func SizeInMB(size string) (int64, error) {
+	if !quotaPattern.MatchString(size) {
+		return 0, fmt.Errorf("%q must be a size such as 256M or 1G", size)
+	}
+
+	upper := strings.ToUpper(size)
+	multiplier := int64(1)
+	if strings.HasPrefix(strings.TrimLeft(upper, "0123456789"), "G") {
+		multiplier = 1024
+	}
+	value, err := strconv.ParseInt(strings.TrimRight(upper, "MGB"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return value * multiplier, nil
+}
+
+type manifestBuildpacks struct {
+	Buildpack  string   `yaml:"buildpack"`
+	Buildpacks []string `yaml:"buildpacks"`
+}
+
+func (b manifestBuildpacks) set() bool {
+	return b.Buildpack != "" || len(b.Buildpacks) > 0
+}
+
+// manifestSetsBuildpack reports whether a rendered manifest sets a buildpack
+// at the top level or for any of its apps.
+func manifestSetsBuildpack(manifest []byte) (bool, error) {
+	var parsed struct {
+		manifestBuildpacks `yaml:",inline"`
+		Applications       []manifestBuildpacks `yaml:"applications"`
+	}
+	if err := yaml.Unmarshal(manifest, &parsed); err != nil {
+		return false, err
+	}
+
+	if parsed.manifestBuildpacks.set() {
+		return true, nil
+	}
+	for _, app := range parsed.Applications {
+		if app.set() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NamedManifest sets the name of the first app in a rendered manifest, for
+// commands such as cf apply-manifest that take the app name from the
+// manifest rather than the command line.
+func NamedManifest(manifest []byte, name string) ([]byte, error) {
+	var parsed yaml.MapSlice
+	if err := yaml.Unmarshal(manifest, &parsed); err != nil {
+		return nil, err
+	}
+
+	named := false
+	for i, item := range parsed {
+		if item.Key != "applications" {
+			continue
+		}
+		apps, ok := item.Value.([]interface{})
+		if !ok || len(apps) == 0 {
+			return nil, errors.New("applications must list at least one app")
+		}
+		app, ok := apps[0].(yaml.MapSlice)
+		if !ok {
+			return nil, errors.New("applications must list at least one app")
+		}
+		apps[0] = setName(app, name)
+		parsed[i].Value = apps
+		named = true
+	}
+	if !named {
+		parsed = append(parsed, yaml.MapItem{
+			Key:   "applications",
+			Value: []interface{}{yaml.MapSlice{{Key: "name", Value: name}}},
+		})
+	}
+	return yaml.Marshal(parsed)
+}
+
+func setName(app yaml.MapSlice, name string) yaml.MapSlice {
+	for i, item := range app {
+		if item.Key == "name" {
+			app[i].Value = name
+			return app
+		}
+	}
+	return append(yaml.MapSlice{{Key: "name", Value: name}}, app...)
+}
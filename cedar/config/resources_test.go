@@ -0,0 +1,72 @@
+package config_test
+
+import (
+	. "code.cloudfoundry.org/diego-stress-tests/cedar/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resources", func() {
+	Describe("ManifestResources", func() {
+		It("reads the first app of a manifest", func() {
+			resources, err := ManifestResources([]byte(`---
+applications:
+- instances: 2
+  memory: 128M
+  disk_quota: 1G
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(Equal(Resources{Instances: 2, MemoryMB: 128, DiskMB: 1024}))
+			Expect(resources.TotalMemoryMB()).To(Equal(int64(256)))
+			Expect(resources.TotalDiskMB()).To(Equal(int64(2048)))
+		})
+
+		It("reads rendered inline manifests", func() {
+			manifest, err := AppDefinition{AppProfile: AppProfile{Instances: "3", Memory: "32M"}}.RenderManifest(TemplateVariables{})
+			Expect(err).NotTo(HaveOccurred())
+
+			resources, err := ManifestResources(manifest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(Equal(Resources{Instances: 3, MemoryMB: 32, DiskMB: DefaultDiskMB}))
+		})
+
+		It("applies top level settings and defaults", func() {
+			resources, err := ManifestResources([]byte("memory: 2G\napplications:\n- command: ./stress-app\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(Equal(Resources{Instances: DefaultInstances, MemoryMB: 2048, DiskMB: DefaultDiskMB}))
+		})
+
+		It("rejects invalid sizes", func() {
+			_, err := ManifestResources([]byte("applications:\n- memory: lots\n"))
+			Expect(err).To(MatchError(`memory: "lots" must be a size such as 256M or 1G`))
+		})
+	})
+
+	Describe("NamedManifest", func() {
+		It("names the first app", func() {
+			manifest, err := NamedManifest([]byte("---\napplications:\n- instances: 1\n  memory: 128M\n"), "cedarapp-0-light-0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(manifest)).To(Equal("applications:\n- name: cedarapp-0-light-0\n  instances: 1\n  memory: 128M\n"))
+		})
+
+		It("names rendered inline manifests", func() {
+			rendered, err := AppDefinition{AppProfile: AppProfile{Memory: "32M"}}.RenderManifest(TemplateVariables{})
+			Expect(err).NotTo(HaveOccurred())
+
+			manifest, err := NamedManifest(rendered, "cedarapp-0-tiny-0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(manifest)).To(ContainSubstring("- name: cedarapp-0-tiny-0\n"))
+
+			resources, err := ManifestResources(manifest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources.MemoryMB).To(Equal(int64(32)))
+		})
+
+		It("replaces an existing name", func() {
+			manifest, err := NamedManifest([]byte("applications:\n- name: other\n"), "cedarapp-0-light-0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(manifest)).To(Equal("applications:\n- name: cedarapp-0-light-0\n"))
+		})
+	})
+})
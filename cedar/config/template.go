@@ -0,0 +1,246 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	UniformDistribution = "uniform"
+	NormalDistribution  = "normal"
+	ChoiceDistribution  = "choice"
+)
+
+// TemplateVariables are available to manifest templates, so a single app type
+// can produce a heterogeneous fleet:
+//
+//	memory: {{.Vars.memory}}M
+//	env:
+//	  APP_NAME: {{.AppName}}
+//	  SHARD: {{.Index}}
+//	  SPACE: {{.Org}}/{{.Space}}
+//
+// Org and Space are empty when the apps are pushed to the space cf targets.
+// Vars holds the app type's variables as drawn for this app. Referring to a
+// variable that is not defined is an error.
+type TemplateVariables struct {
+	AppName       string
+	AppNamePrefix string
+	Batch         int
+	Index         int
+	Org           string
+	Space         string
+	Vars          map[string]string
+}
+
+// Value is an inline manifest value. It may be written in JSON as a string,
+// number or boolean, and may contain template actions.
+type Value string
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch raw := raw.(type) {
+	case string:
+		*v = Value(raw)
+	case float64, bool:
+		*v = Value(bytes.TrimSpace(data))
+	default:
+		return fmt.Errorf("manifest values must be strings, numbers or booleans: %s", data)
+	}
+	return nil
+}
+
+func (v Value) isTemplate() bool {
+	return strings.Contains(string(v), "{{")
+}
+
+func (v Value) template() (*template.Template, error) {
+	return template.New("").Option("missingkey=error").Parse(string(v))
+}
+
+func (v Value) render(vars TemplateVariables) (string, error) {
+	if !v.isTemplate() {
+		return string(v), nil
+	}
+
+	tmpl, err := v.template()
+	if err != nil {
+		return "", err
+	}
+	rendered := &bytes.Buffer{}
+	if err := tmpl.Execute(rendered, vars); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+// Variable is a random draw made for every app. Uniform draws are between Min
+// and Max; normal draws have the given Mean and StdDev and are clamped to Min
+// and Max when they are set; choice draws pick one of Values. Integer rounds
+// uniform and normal draws to whole numbers.
+type Variable struct {
+	Distribution string   `json:"distribution"`
+	Min          *float64 `json:"min,omitempty"`
+	Max          *float64 `json:"max,omitempty"`
+	Mean         float64  `json:"mean,omitempty"`
+	StdDev       float64  `json:"stddev,omitempty"`
+	Values       []Value  `json:"values,omitempty"`
+	Integer      bool     `json:"integer,omitempty"`
+}
+
+func (v Variable) validate() error {
+	switch v.Distribution {
+	case UniformDistribution:
+		if v.Min == nil || v.Max == nil {
+			return errors.New("uniform distributions need a min and a max")
+		}
+		if *v.Min > *v.Max {
+			return errors.New("min must not be greater than max")
+		}
+	case NormalDistribution:
+		if v.StdDev < 0 {
+			return errors.New("stddev must not be negative")
+		}
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			return errors.New("min must not be greater than max")
+		}
+	case ChoiceDistribution:
+		if len(v.Values) == 0 {
+			return errors.New("choice distributions need at least one value")
+		}
+	default:
+		return fmt.Errorf("unknown distribution %q", v.Distribution)
+	}
+	return nil
+}
+
+func (v Variable) Draw(random *rand.Rand) string {
+	var x float64
+	switch v.Distribution {
+	case ChoiceDistribution:
+		return string(v.Values[random.Intn(len(v.Values))])
+	case UniformDistribution:
+		if v.Integer {
+			min, max := int(math.Ceil(*v.Min)), int(math.Floor(*v.Max))
+			if max < min {
+				max = min
+			}
+			return strconv.Itoa(min + random.Intn(max-min+1))
+		}
+		x = *v.Min + random.Float64()*(*v.Max-*v.Min)
+	case NormalDistribution:
+		x = v.Mean + random.NormFloat64()*v.StdDev
+		if v.Min != nil && x < *v.Min {
+			x = *v.Min
+		}
+		if v.Max != nil && x > *v.Max {
+			x = *v.Max
+		}
+	}
+
+	if v.Integer {
+		return strconv.Itoa(int(math.Round(x)))
+	}
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+// DrawVariables draws the app type's variables in name order, so the same
+// random source always produces the same values.
+func (d AppDefinition) DrawVariables(random *rand.Rand) map[string]string {
+	names := make([]string, 0, len(d.Variables))
+	for name := range d.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make(map[string]string, len(names))
+	for _, name := range names {
+		vars[name] = d.Variables[name].Draw(random)
+	}
+	return vars
+}
+
+type manifest struct {
+	Applications []manifestApplication `json:"applications"`
+}
+
+type manifestApplication struct {
+	Instances       *int              `json:"instances,omitempty"`
+	Memory          string            `json:"memory,omitempty"`
+	DiskQuota       string            `json:"disk_quota,omitempty"`
+	Buildpack       string            `json:"buildpack,omitempty"`
+	Command         string            `json:"command,omitempty"`
+	HealthCheckType string            `json:"health-check-type,omitempty"`
+	Env             map[string]string `json:"env,omitempty"`
+}
+
+// RenderManifest renders the app's manifest file, or its inline manifest, for
+// one app. Inline manifests are rendered as JSON, which is valid YAML, so the
+// result can be passed to cf push as is.
+func (d AppDefinition) RenderManifest(vars TemplateVariables) ([]byte, error) {
+	if d.ManifestPath != "" {
+		content, err := ioutil.ReadFile(d.ManifestPath)
+		if err != nil {
+			return nil, err
+		}
+		rendered, err := Value(content).render(vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", d.ManifestPath, err)
+		}
+		return []byte(rendered), nil
+	}
+
+	p := d.AppProfile
+	var app manifestApplication
+	var err error
+	render := func(field string, value Value) string {
+		if err != nil {
+			return ""
+		}
+		var rendered string
+		rendered, err = value.render(vars)
+		if err != nil {
+			err = fmt.Errorf("%s: %s", field, err)
+		}
+		return rendered
+	}
+
+	instances := render("instances", p.Instances)
+	app.Memory = render("memory", p.Memory)
+	app.DiskQuota = render("diskQuota", p.DiskQuota)
+	app.Buildpack = render("buildpack", p.Buildpack)
+	app.Command = render("command", p.Command)
+	app.HealthCheckType = render("healthCheckType", p.HealthCheckType)
+	if len(p.Env) > 0 {
+		app.Env = make(map[string]string, len(p.Env))
+		for name, value := range p.Env {
+			app.Env[name] = render("env."+name, value)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if instances != "" {
+		count, err := strconv.Atoi(instances)
+		if err != nil {
+			return nil, fmt.Errorf("instances must be a number: %q", instances)
+		}
+		app.Instances = &count
+	}
+
+	return json.MarshalIndent(manifest{Applications: []manifestApplication{app}}, "", "  ")
+}
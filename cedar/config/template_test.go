@@ -0,0 +1,169 @@
+package config_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "code.cloudfoundry.org/diego-stress-tests/cedar/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Templates", func() {
+	Describe("Value", func() {
+		It("accepts strings, numbers and booleans", func() {
+			var values map[string]Value
+			err := json.Unmarshal([]byte(`{"a": "32M", "b": 0.03, "c": true}`), &values)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values).To(Equal(map[string]Value{"a": "32M", "b": "0.03", "c": "true"}))
+		})
+
+		It("rejects other types", func() {
+			var value Value
+			err := json.Unmarshal([]byte(`{"a": 1}`), &value)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Variable", func() {
+		var random *rand.Rand
+
+		BeforeEach(func() {
+			random = rand.New(rand.NewSource(1))
+		})
+
+		It("draws integers from a uniform distribution", func() {
+			min, max := 1.0, 3.0
+			variable := Variable{Distribution: UniformDistribution, Min: &min, Max: &max, Integer: true}
+			drawn := map[string]bool{}
+			for i := 0; i < 100; i++ {
+				drawn[variable.Draw(random)] = true
+			}
+			Expect(drawn).To(Equal(map[string]bool{"1": true, "2": true, "3": true}))
+		})
+
+		It("clamps normal draws to the min and max", func() {
+			min, max := 0.0, 0.1
+			variable := Variable{Distribution: NormalDistribution, Mean: 0.05, StdDev: 1, Min: &min, Max: &max}
+			for i := 0; i < 100; i++ {
+				x, err := strconv.ParseFloat(variable.Draw(random), 64)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(x).To(BeNumerically(">=", min))
+				Expect(x).To(BeNumerically("<=", max))
+			}
+		})
+
+		It("picks one of the values of a choice", func() {
+			variable := Variable{Distribution: ChoiceDistribution, Values: []Value{"32", "64"}}
+			Expect([]string{"32", "64"}).To(ContainElement(variable.Draw(random)))
+		})
+
+		It("draws the same values from the same seed", func() {
+			min, max := 0.0, 1.0
+			definition := AppDefinition{AppProfile: AppProfile{Variables: map[string]Variable{
+				"a": {Distribution: UniformDistribution, Min: &min, Max: &max},
+				"b": {Distribution: NormalDistribution, Mean: 1, StdDev: 1},
+			}}}
+			Expect(definition.DrawVariables(rand.New(rand.NewSource(7)))).To(
+				Equal(definition.DrawVariables(rand.New(rand.NewSource(7)))))
+		})
+	})
+
+	Describe("ParseFile", func() {
+		It("reports invalid variables and templates", func() {
+			_, err := ParseFile([]byte(`{
+				"version": 2,
+				"apps": [{
+					"appNamePrefix": "light",
+					"appCount": 1,
+					"memory": "{{.Vars.memory",
+					"variables": {"memory": {"distribution": "uniform", "min": 64}}
+				}]
+			}`))
+			Expect(err).To(BeAssignableToTypeOf(ValidationError{}))
+			Expect(err.(ValidationError)).To(ConsistOf(
+				ContainSubstring("apps[0].memory: template: :1: unclosed action"),
+				"apps[0].variables.memory: uniform distributions need a min and a max",
+			))
+		})
+	})
+
+	Describe("RenderManifest", func() {
+		var vars TemplateVariables
+
+		BeforeEach(func() {
+			vars = TemplateVariables{
+				AppName:       "cedarapp-1-light-2",
+				AppNamePrefix: "light",
+				Batch:         1,
+				Index:         2,
+				Vars:          map[string]string{"memory": "64", "instances": "3"},
+			}
+		})
+
+		It("renders inline manifests", func() {
+			definition := AppDefinition{AppProfile: AppProfile{
+				Instances: "{{.Vars.instances}}",
+				Memory:    "{{.Vars.memory}}M",
+				Env:       map[string]Value{"SHARD": "{{.Batch}}-{{.Index}}", "NAME": "{{.AppName}}"},
+			}}
+			manifest, err := definition.RenderManifest(vars)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest).To(MatchJSON(`{
+				"applications": [{
+					"instances": 3,
+					"memory": "64M",
+					"env": {"SHARD": "1-2", "NAME": "cedarapp-1-light-2"}
+				}]
+			}`))
+		})
+
+		It("fails when a variable is not defined", func() {
+			definition := AppDefinition{AppProfile: AppProfile{Memory: "{{.Vars.disk}}M"}}
+			_, err := definition.RenderManifest(vars)
+			Expect(err).To(MatchError(ContainSubstring("memory")))
+		})
+
+		It("keeps instances that are explicitly zero", func() {
+			definition := AppDefinition{AppProfile: AppProfile{Instances: "0"}}
+			manifest, err := definition.RenderManifest(vars)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest).To(MatchJSON(`{"applications": [{"instances": 0}]}`))
+		})
+
+		It("fails when instances do not render to a number", func() {
+			definition := AppDefinition{AppProfile: AppProfile{Instances: "{{.AppNamePrefix}}"}}
+			_, err := definition.RenderManifest(vars)
+			Expect(err).To(MatchError(`instances must be a number: "light"`))
+		})
+
+		Context("when the app has a manifest file", func() {
+			var dir string
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "manifests")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(dir)
+			})
+
+			It("renders the file", func() {
+				path := filepath.Join(dir, "manifest.yml")
+				err := ioutil.WriteFile(path, []byte("memory: {{.Vars.memory}}M\n"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				manifest, err := AppDefinition{ManifestPath: path}.RenderManifest(vars)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(manifest)).To(Equal("memory: 64M\n"))
+			})
+		})
+	})
+})
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"golang.org/x/net/context"
@@ -21,11 +25,21 @@ var (
 	maxInFlight           = flag.Int("k", 1, "max number of cf operations in flight")
 	maxPollingErrors      = flag.Int("max-polling-errors", 1, "max number of curl failures")
 	tolerance             = flag.Float64("tolerance", 1.0, "fractional failure tolerance")
-	configFile            = flag.String("config", "config.json", "path to cedar config file")
+	configFile            = flag.String("config", "config.json", "path to cedar config file; the settings of a version 2 file replace the defaults of these flags")
 	outputFile            = flag.String("output", "output.json", "path to cedar metric results file")
 	appPayload            = flag.String("payload", "assets/temp-app", "directory containing the stress-app payload to push")
 	prefix                = flag.String("prefix", "cedarapp", "the naming prefix for cedar generated apps")
 	timeout               = flag.Duration("timeout", 30*time.Second, "time allowed for a push or start operation, golang duration")
+	totalApps             = flag.Int("total-apps", 0, "number of apps to generate across all batches, picking app types by their weight instead of their appCount")
+	seed                  = flag.Int64("seed", 0, "seed for random choices made while generating apps; 0 picks a new seed")
+	shuffle               = flag.Bool("shuffle", false, "push apps in random order; always on with -total-apps")
+	preflight             = flag.String("preflight", config.PreflightOff, "check the org and space quotas before pushing: off, refuse to run when the apps do not fit, or scale-down to drop apps until they fit")
+	pushMode              = flag.String("push-mode", config.PushModeStage, "how apps get their droplets: stage to upload and stage every app, or copy-droplet to stage one app of each type and copy its droplet to the others (needs cf CLI v7)")
+	dryRun                = flag.Bool("dry-run", false, "plan the run and print the plan as JSON without pushing anything; exits non-zero if the plan has problems")
+	orgs                  = flag.Int("orgs", 0, "number of orgs to distribute the batches across, created as needed; 0 pushes every app to the space cf targets")
+	spacesPerOrg          = flag.Int("spaces-per-org", 1, "number of spaces in each org to distribute the batches across")
+	teardown              = flag.Bool("teardown", false, "delete the apps listed in the output file of a previous run, and the orgs and spaces it created, instead of pushing")
+	failurePolicy         = flag.String("failure-policy", config.AbortPolicy, "what to do once more apps fail than the tolerance allows: abort, or continue-without-start to stop pushing but still start the pushed apps")
 )
 
 func main() {
@@ -37,6 +51,12 @@ func main() {
 	logger.Info("started")
 	defer logger.Info("exited")
 
+	if err := applyConfigFileSettings(logger, *configFile); err != nil {
+		logger.Error("failed-to-load-config", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	ctx, cancel := context.WithCancel(
 		context.WithValue(
 			context.Background(),
@@ -47,37 +67,145 @@ func main() {
 	cfClient := cli.NewCfClient(ctx, *maxInFlight)
 	defer cfClient.Cleanup(ctx)
 
-	config, err := config.NewConfig(
-		logger,
-		cfClient,
-		*numBatches,
-		*maxInFlight,
-		*maxPollingErrors,
-		*tolerance,
-		*appPayload,
-		*prefix,
-		*domain,
-		*configFile,
-		*outputFile,
-		*timeout,
-		*useTLS,
-		*skipVerifyCertificate,
-	)
+	config, err := config.NewConfig(logger, cfClient, config.Options{
+		NumBatches:            *numBatches,
+		MaxInFlight:           *maxInFlight,
+		MaxPollingErrors:      *maxPollingErrors,
+		WeightedAppCount:      *totalApps,
+		Orgs:                  *orgs,
+		SpacesPerOrg:          *spacesPerOrg,
+		Tolerance:             *tolerance,
+		AppPayload:            *appPayload,
+		Prefix:                *prefix,
+		Domain:                *domain,
+		ConfigFile:            *configFile,
+		OutputFile:            *outputFile,
+		FailurePolicy:         *failurePolicy,
+		Preflight:             *preflight,
+		PushMode:              *pushMode,
+		Timeout:               *timeout,
+		Seed:                  *seed,
+		UseTLS:                *useTLS,
+		SkipVerifyCertificate: *skipVerifyCertificate,
+		Shuffle:               *shuffle,
+	})
 
 	if err != nil {
 		logger.Error("failed-to-initialize", err)
-		panic("failed-to-initialize")
+		fmt.Fprintln(os.Stderr, err)
+		cfClient.Cleanup(ctx)
+		os.Exit(1)
+	}
+
+	if *teardown {
+		report, err := seeder.LoadReport(config.OutputFile())
+		if err == nil {
+			err = seeder.Teardown(logger, ctx, config, report, cfClient)
+		}
+		if err != nil {
+			logger.Error("failed-teardown", err)
+			fmt.Fprintln(os.Stderr, err)
+			cfClient.Cleanup(ctx)
+			os.Exit(1)
+		}
+		return
 	}
 
 	apps := generateApps(logger, config)
+	if *dryRun {
+		if !printPlan(logger, config, apps, cfClient) {
+			cfClient.Cleanup(ctx)
+			os.Exit(1)
+		}
+		return
+	}
+
+	apps, preflightReport, err := seeder.Preflight(logger, config, apps, cfClient)
+	if err != nil {
+		logger.Error("failed-preflight", err)
+		fmt.Fprintln(os.Stderr, err)
+		cfClient.Cleanup(ctx)
+		os.Exit(1)
+	}
+
+	// The orgs and spaces are only set up once the preflight check accepted
+	// the run. The spaces set up before a failure are still reported so that
+	// a teardown deletes them.
+	var spaces []seeder.SpaceSetup
+	if !preflightReport.Refused() {
+		spaces, err = seeder.SetupSpaces(logger, cfClient, seeder.Targets(config))
+		if err != nil {
+			deployer := seeder.NewDeployer(config, []seeder.CfApp{}, cfClient)
+			deployer.Preflight = preflightReport
+			deployer.Spaces = spaces
+			deployer.Abort("setting up spaces: " + err.Error())
+			deployer.GenerateReport(ctx, cancel)
+			logger.Error("failed-setting-up-spaces", err)
+			fmt.Fprintln(os.Stderr, err)
+			cfClient.Cleanup(ctx)
+			os.Exit(1)
+		}
+	}
+
 	deployer := seeder.NewDeployer(config, apps, cfClient)
-	deployer.PushApps(logger, ctx, cancel)
-	deployer.StartApps(ctx, cancel)
+	deployer.Preflight = preflightReport
+	deployer.Spaces = spaces
+	if preflightReport.Refused() {
+		reason := "preflight: " + strings.Join(preflightReport.Problems, "; ")
+		fmt.Fprintln(os.Stderr, reason)
+		deployer.Abort(reason)
+	} else {
+		deployer.PushApps(logger, ctx, cancel)
+		deployer.StartApps(ctx, cancel)
+	}
 	if succeeded := deployer.GenerateReport(ctx, cancel); !succeeded {
 		panic("seeding failed")
 	}
 }
 
+// applyConfigFileSettings sets the flags that were not given on the command
+// line from the settings in the config file.
+func applyConfigFileSettings(logger lager.Logger, configFile string) error {
+	file, err := config.LoadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for name, value := range file.Settings.Flags() {
+		if explicit[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+		logger.Info("applied-config-file-setting", lager.Data{"flag": name, "value": value})
+	}
+	return nil
+}
+
+// printPlan prints the plan of the run and reports whether it can go ahead.
+func printPlan(logger lager.Logger, config config.Config, apps []seeder.CfApp, cfClient cli.CFClient) bool {
+	plan, err := seeder.NewPlan(logger, config, apps, cfClient)
+	if err != nil {
+		logger.Error("failed-to-plan", err)
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(plan); err != nil {
+		logger.Error("failed-to-print-plan", err)
+		return false
+	}
+	return len(plan.Problems) == 0
+}
+
 func generateApps(logger lager.Logger, config config.Config) []seeder.CfApp {
 	appsGenerator := seeder.NewAppGenerator(config)
 	return appsGenerator.Apps(logger)
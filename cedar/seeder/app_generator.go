@@ -2,7 +2,9 @@ package seeder
 
 import (
 	"fmt"
+	"math/rand"
 
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
 	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
 	"code.cloudfoundry.org/lager"
 )
@@ -21,18 +23,39 @@ func NewAppGenerator(config config.Config) AppGenerator {
 	}
 }
 
+// Apps generates the apps of every batch, drawing their variables and, when
+// shuffling, their push order from the config's seed.
 func (a appGenerator) Apps(logger lager.Logger) []CfApp {
-	logger = logger.Session("generating-apps")
+	logger = logger.Session("generating-apps", lager.Data{"seed": a.config.Seed()})
 	logger.Info("started")
 	defer logger.Info("complete")
 
+	random := rand.New(rand.NewSource(a.config.Seed()))
+	appTypes := a.config.AppTypes()
+	counts := a.appCounts(appTypes)
+
+	targets := Targets(a.config)
+
 	apps := []CfApp{}
 	for i := 0; i < a.config.NumBatches(); i++ {
-		for _, appDef := range a.config.AppTypes() {
-			for j := 0; j < appDef.AppCount; j++ {
+		var target cli.Target
+		if len(targets) > 0 {
+			target = targets[i%len(targets)]
+		}
+		for t, appDef := range appTypes {
+			for j := 0; j < counts[i][t]; j++ {
 				name := a.appName(appDef.AppNamePrefix, i, j)
-				logger.Info("generate-app", lager.Data{"appName": name})
-				seedApp, err := NewCfApp(name, a.config.Domain(), a.config.UseTLS(), a.config.MaxPollingErrors(), appDef.ManifestPath)
+				variables := config.TemplateVariables{
+					AppName:       name,
+					AppNamePrefix: appDef.AppNamePrefix,
+					Batch:         i,
+					Index:         j,
+					Org:           target.Org,
+					Space:         target.Space,
+					Vars:          appDef.DrawVariables(random),
+				}
+				logger.Info("generate-app", lager.Data{"appName": name, "vars": variables.Vars})
+				seedApp, err := NewCfApp(name, a.config.Domain(), a.config.UseTLS(), a.config.MaxPollingErrors(), appDef, variables)
 				if err != nil {
 					logger.Error("failed-generating-app", err)
 					continue
@@ -41,9 +64,96 @@ func (a appGenerator) Apps(logger lager.Logger) []CfApp {
 			}
 		}
 	}
+
+	if a.config.Shuffle() {
+		for i := len(apps) - 1; i > 0; i-- {
+			j := random.Intn(i + 1)
+			apps[i], apps[j] = apps[j], apps[i]
+		}
+	}
 	return apps
 }
 
+// Targets returns the spaces the batches are distributed across, named after
+// the config's prefix, or none when every app goes to the space cf targets.
+func Targets(config config.Config) []cli.Target {
+	targets := []cli.Target{}
+	for i := 0; i < config.Orgs(); i++ {
+		for j := 0; j < config.SpacesPerOrg(); j++ {
+			targets = append(targets, cli.Target{
+				Org:   fmt.Sprintf("%s-org-%d", config.Prefix(), i),
+				Space: fmt.Sprintf("%s-space-%d", config.Prefix(), j),
+			})
+		}
+	}
+	return targets
+}
+
+// appCounts returns how many apps of each type every batch gets.
+func (a appGenerator) appCounts(appTypes []config.AppDefinition) [][]int {
+	numBatches := a.config.NumBatches()
+	counts := make([][]int, numBatches)
+	for i := range counts {
+		counts[i] = make([]int, len(appTypes))
+	}
+
+	total := a.config.WeightedAppCount()
+	if total <= 0 {
+		for i := range counts {
+			for t, appDef := range appTypes {
+				counts[i][t] = appDef.AppCount
+			}
+		}
+		return counts
+	}
+
+	// Spread each type's share of the total over the batches, handing the
+	// leftover apps to the next batches in turn so batch sizes stay even.
+	next := 0
+	for t, count := range apportion(total, appTypes) {
+		for i := range counts {
+			counts[i][t] = count / numBatches
+		}
+		for k := 0; k < count%numBatches; k++ {
+			counts[next][t]++
+			next = (next + 1) % numBatches
+		}
+	}
+	return counts
+}
+
+// apportion splits total between the app types in proportion to their
+// weights, giving the apps left over after rounding down to the types with
+// the largest remainders.
+func apportion(total int, appTypes []config.AppDefinition) []int {
+	var totalWeight float64
+	for _, appDef := range appTypes {
+		totalWeight += appDef.Weight
+	}
+
+	counts := make([]int, len(appTypes))
+	remainders := make([]float64, len(appTypes))
+	assigned := 0
+	for t, appDef := range appTypes {
+		share := float64(total) * appDef.Weight / totalWeight
+		counts[t] = int(share)
+		remainders[t] = share - float64(counts[t])
+		assigned += counts[t]
+	}
+
+	for ; assigned < total; assigned++ {
+		largest := 0
+		for t := range remainders {
+			if remainders[t] > remainders[largest] {
+				largest = t
+			}
+		}
+		counts[largest]++
+		remainders[largest] = -1
+	}
+	return counts
+}
+
 func (a appGenerator) appName(appName string, batchSeq, appSeq int) string {
 	return fmt.Sprintf("%s-%d-%s-%d", a.config.Prefix(), batchSeq, appName, appSeq)
 }
@@ -1,6 +1,9 @@
 package seeder_test
 
 import (
+	"strings"
+
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
 	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
 	"code.cloudfoundry.org/diego-stress-tests/cedar/config/fakes"
 	"code.cloudfoundry.org/diego-stress-tests/cedar/seeder"
@@ -59,4 +62,89 @@ var _ = Describe("AppGenerator", func() {
 			}
 		})
 	})
+
+	Context("when the batches are distributed across orgs and spaces", func() {
+		BeforeEach(func() {
+			cfg.NumBatchesReturns(5)
+			cfg.OrgsReturns(2)
+			cfg.SpacesPerOrgReturns(2)
+		})
+
+		It("names the spaces after the prefix", func() {
+			Expect(seeder.Targets(cfg)).To(Equal([]cli.Target{
+				{Org: "cedarapp-org-0", Space: "cedarapp-space-0"},
+				{Org: "cedarapp-org-0", Space: "cedarapp-space-1"},
+				{Org: "cedarapp-org-1", Space: "cedarapp-space-0"},
+				{Org: "cedarapp-org-1", Space: "cedarapp-space-1"},
+			}))
+		})
+
+		It("pushes each batch to the next space in turn", func() {
+			for _, app := range cfApps {
+				switch {
+				case strings.HasPrefix(app.AppName(), "cedarapp-1-"):
+					Expect(app.Target()).To(Equal(cli.Target{Org: "cedarapp-org-0", Space: "cedarapp-space-1"}))
+				case strings.HasPrefix(app.AppName(), "cedarapp-4-"):
+					Expect(app.Target()).To(Equal(cli.Target{Org: "cedarapp-org-0", Space: "cedarapp-space-0"}))
+				}
+			}
+		})
+	})
+
+	Context("when no orgs are configured", func() {
+		It("pushes every app to the space cf targets", func() {
+			Expect(seeder.Targets(cfg)).To(BeEmpty())
+			for _, app := range cfApps {
+				Expect(app.Target()).To(Equal(cli.Target{}))
+			}
+		})
+	})
+
+	Context("when a weighted app count is provided", func() {
+		BeforeEach(func() {
+			cfg.NumBatchesReturns(3)
+			cfg.WeightedAppCountReturns(20)
+			cfg.ShuffleReturns(true)
+			cfg.SeedReturns(42)
+			cfg.AppTypesReturns([]config.AppDefinition{
+				{AppNamePrefix: "light", Weight: 0.7},
+				{AppNamePrefix: "medium", Weight: 0.2},
+				{AppNamePrefix: "heavy", Weight: 0.05},
+				{AppNamePrefix: "crashing", Weight: 0.05},
+			})
+		})
+
+		It("picks the app types in proportion to their weights", func() {
+			counts := map[string]int{}
+			for _, app := range cfApps {
+				counts[app.AppDefinition().AppNamePrefix]++
+			}
+			Expect(counts).To(Equal(map[string]int{"light": 14, "medium": 4, "heavy": 1, "crashing": 1}))
+		})
+
+		It("spreads the apps evenly over the batches", func() {
+			batches := map[string]int{}
+			for _, app := range cfApps {
+				batches[strings.SplitN(app.AppName(), "-", 3)[1]]++
+			}
+			Expect(batches).To(Equal(map[string]int{"0": 7, "1": 7, "2": 6}))
+		})
+
+		It("generates the same shuffled order from the same seed", func() {
+			again := seeder.NewAppGenerator(cfg).Apps(fakeLogger)
+			Expect(appNames(again)).To(Equal(appNames(cfApps)))
+		})
+
+		It("shuffles the push order", func() {
+			Expect(appNames(cfApps)[:3]).NotTo(Equal([]string{"cedarapp-0-light-0", "cedarapp-0-light-1", "cedarapp-0-light-2"}))
+		})
+	})
 })
+
+func appNames(apps []seeder.CfApp) []string {
+	names := []string{}
+	for _, app := range apps {
+		names = append(names, app.AppName())
+	}
+	return names
+}
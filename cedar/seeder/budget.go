@@ -0,0 +1,122 @@
+package seeder
+
+import (
+	"fmt"
+	"sync"
+)
+
+const Curl = "curl"
+
+// FailureCounts counts failed apps by the stage they failed in. An app that
+// started but could not be curled counts as a curl failure only.
+type FailureCounts struct {
+	Push  int `json:"push"`
+	Start int `json:"start"`
+	Curl  int `json:"curl"`
+}
+
+func (c FailureCounts) Total() int {
+	return c.Push + c.Start + c.Curl
+}
+
+// FailureBudget counts failures against the global tolerance, or against the
+// tolerance of an app type that has its own. Exhausting the global tolerance
+// aborts the run, while exhausting a type's tolerance only stops that type.
+type FailureBudget struct {
+	mutex sync.Mutex
+
+	maxFailures     int
+	typeMaxFailures map[string]int
+
+	failures       FailureCounts
+	globalFailures int
+	typeFailures   map[string]int
+	stoppedTypes   map[string]string
+	abortReason    string
+}
+
+// NewFailureBudget allows maxFailures failures across the app types that are
+// not in typeMaxFailures, and typeMaxFailures[appType] failures for each of
+// the others.
+func NewFailureBudget(maxFailures int, typeMaxFailures map[string]int) *FailureBudget {
+	return &FailureBudget{
+		maxFailures:     maxFailures,
+		typeMaxFailures: typeMaxFailures,
+		typeFailures:    map[string]int{},
+		stoppedTypes:    map[string]string{},
+	}
+}
+
+// Record counts a failure of the given stage and reports whether it exhausted
+// the global budget. Only the failure that first exhausts the global budget
+// returns true; it also becomes the abort reason unless the run was already
+// aborted. A failure that exhausts the budget of an app type with its own
+// tolerance stops that type instead.
+func (b *FailureBudget) Record(stage, appType, appName string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch stage {
+	case Push:
+		b.failures.Push++
+	case Start:
+		b.failures.Start++
+	case Curl:
+		b.failures.Curl++
+	}
+
+	if maxFailures, ok := b.typeMaxFailures[appType]; ok {
+		b.typeFailures[appType]++
+		if b.typeFailures[appType] == maxFailures+1 {
+			b.stoppedTypes[appType] = fmt.Sprintf("%s of %s exceeded the failure tolerance of %d for app type %s", stage, appName, maxFailures, appType)
+		}
+		return false
+	}
+
+	b.globalFailures++
+	exceeded := b.globalFailures == b.maxFailures+1
+	if exceeded && b.abortReason == "" {
+		b.abortReason = fmt.Sprintf("%s of %s exceeded the failure tolerance of %d", stage, appName, b.maxFailures)
+	}
+	return exceeded
+}
+
+// StopReason is empty unless the app type exhausted its own tolerance, in
+// which case its remaining apps are not pushed or started.
+func (b *FailureBudget) StopReason(appType string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.stoppedTypes[appType]
+}
+
+// ResetGlobalFailures starts the global budget over without clearing the
+// failure counts or the abort reason, so that a later stage can be stopped by
+// its own failures.
+func (b *FailureBudget) ResetGlobalFailures() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.globalFailures = 0
+}
+
+// Abort records why the run was aborted, unless it already was.
+func (b *FailureBudget) Abort(reason string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.abortReason == "" {
+		b.abortReason = reason
+	}
+}
+
+// AbortReason is empty unless the run was aborted.
+func (b *FailureBudget) AbortReason() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.abortReason
+}
+
+func (b *FailureBudget) Failures() FailureCounts {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.failures
+}
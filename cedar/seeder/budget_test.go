@@ -0,0 +1,66 @@
+package seeder_test
+
+import (
+	"code.cloudfoundry.org/diego-stress-tests/cedar/seeder"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FailureBudget", func() {
+	var budget *seeder.FailureBudget
+
+	BeforeEach(func() {
+		budget = seeder.NewFailureBudget(1, map[string]int{"crashing": 0})
+	})
+
+	It("counts failures by stage", func() {
+		budget.Record(seeder.Push, "light", "app-1")
+		budget.Record(seeder.Curl, "crashing", "app-2")
+		budget.Record(seeder.Start, "light", "app-3")
+		budget.Record(seeder.Curl, "light", "app-4")
+
+		Expect(budget.Failures()).To(Equal(seeder.FailureCounts{Push: 1, Start: 1, Curl: 2}))
+		Expect(budget.Failures().Total()).To(Equal(4))
+	})
+
+	It("is exhausted only once the failures exceed the tolerance", func() {
+		Expect(budget.Record(seeder.Push, "light", "app-1")).To(BeFalse())
+		Expect(budget.AbortReason()).To(BeEmpty())
+
+		Expect(budget.Record(seeder.Start, "light", "app-2")).To(BeTrue())
+		Expect(budget.AbortReason()).To(Equal("start of app-2 exceeded the failure tolerance of 1"))
+
+		Expect(budget.Record(seeder.Start, "light", "app-3")).To(BeFalse())
+	})
+
+	It("stops an app type that exhausts its own tolerance without aborting the run", func() {
+		Expect(budget.StopReason("crashing")).To(BeEmpty())
+
+		Expect(budget.Record(seeder.Curl, "crashing", "app-1")).To(BeFalse())
+		Expect(budget.StopReason("crashing")).To(Equal("curl of app-1 exceeded the failure tolerance of 0 for app type crashing"))
+		Expect(budget.AbortReason()).To(BeEmpty())
+
+		Expect(budget.Record(seeder.Push, "light", "app-2")).To(BeFalse())
+		Expect(budget.StopReason("light")).To(BeEmpty())
+	})
+
+	It("starts the global budget over while keeping the counts and abort reason", func() {
+		budget.Record(seeder.Push, "light", "app-1")
+		Expect(budget.Record(seeder.Push, "light", "app-2")).To(BeTrue())
+
+		budget.ResetGlobalFailures()
+		Expect(budget.Record(seeder.Start, "light", "app-1")).To(BeFalse())
+		Expect(budget.Record(seeder.Start, "light", "app-3")).To(BeTrue())
+
+		Expect(budget.AbortReason()).To(Equal("push of app-2 exceeded the failure tolerance of 1"))
+		Expect(budget.Failures()).To(Equal(seeder.FailureCounts{Push: 2, Start: 2}))
+	})
+
+	It("keeps the first abort reason", func() {
+		budget.Abort("the run was cancelled")
+		budget.Record(seeder.Push, "crashing", "app-1")
+
+		Expect(budget.AbortReason()).To(Equal("the run was cancelled"))
+	})
+})
@@ -2,11 +2,14 @@ package seeder
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -14,12 +17,17 @@ import (
 
 	"code.cloudfoundry.org/cflager"
 	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
 
 	"code.cloudfoundry.org/lager"
 )
 
 const (
 	AppRoutePattern = "%s://%s.%s"
+
+	// dropletPollInterval is how often builds and droplet copies are polled
+	// until they finish.
+	dropletPollInterval = time.Second
 )
 
 //go:generate counterfeiter -o fakes/fake_cfapp.go . CfApp
@@ -29,6 +37,11 @@ type CfApp interface {
 	Push(logger lager.Logger, ctx context.Context, client cli.CFClient, payload string, timeout time.Duration) error
 	Start(logger lager.Logger, ctx context.Context, client cli.CFClient, skipVerifyCertificate bool, timeout time.Duration) error
 	Guid(logger lager.Logger, ctx context.Context, client cli.CFClient, timeout time.Duration) (string, error)
+	AppDefinition() config.AppDefinition
+	Manifest() ([]byte, error)
+	Target() cli.Target
+	Stage(logger lager.Logger, ctx context.Context, client cli.CFClient, timeout time.Duration) (string, error)
+	PushDroplet(logger lager.Logger, ctx context.Context, client cli.CFClient, droplet string, timeout time.Duration) error
 }
 
 type CfApplication struct {
@@ -39,10 +52,11 @@ type CfApplication struct {
 	domain         string
 	useTLS         bool
 	maxFailedCurls int
-	manifestPath   string
+	definition     config.AppDefinition
+	variables      config.TemplateVariables
 }
 
-func NewCfApp(appName string, domain string, useTLS bool, maxFailedCurls int, manifestPath string) (CfApp, error) {
+func NewCfApp(appName string, domain string, useTLS bool, maxFailedCurls int, definition config.AppDefinition, variables config.TemplateVariables) (CfApp, error) {
 	protocol := "http"
 	if useTLS {
 		protocol = "https"
@@ -60,7 +74,8 @@ func NewCfApp(appName string, domain string, useTLS bool, maxFailedCurls int, ma
 		domain:         domain,
 		useTLS:         useTLS,
 		maxFailedCurls: maxFailedCurls,
-		manifestPath:   manifestPath,
+		definition:     definition,
+		variables:      variables,
 	}, nil
 }
 
@@ -72,11 +87,34 @@ func (a *CfApplication) AppURL() string {
 	return a.appRoute.String()
 }
 
+func (a *CfApplication) AppDefinition() config.AppDefinition {
+	return a.definition
+}
+
+// Target is the org and space the app is pushed to, or the zero Target when
+// it goes to the space cf targets.
+func (a *CfApplication) Target() cli.Target {
+	return cli.Target{Org: a.variables.Org, Space: a.variables.Space}
+}
+
 func (a *CfApplication) Push(logger lager.Logger, ctx context.Context, cli cli.CFClient, assetDir string, timeout time.Duration) error {
 	logger = logger.Session("push", lager.Data{"app": a.appName})
 	logger.Info("started")
 
-	_, err := cli.Cf(logger, ctx, timeout, "push", a.appName, "-p", assetDir, "-f", a.manifestPath, "--no-start")
+	manifestPath, err := a.writeManifest()
+	if err != nil {
+		logger.Error("failed-to-write-manifest", err)
+		return err
+	}
+	defer os.Remove(manifestPath)
+
+	// Docker apps are pushed from their image, so they have no payload.
+	source := []string{"-p", assetDir}
+	if image := a.definition.DockerImage; image != "" {
+		source = []string{"--docker-image", image}
+	}
+	args := append(append([]string{"push", a.appName}, source...), "-f", manifestPath, "--no-start")
+	_, err = cli.Cf(logger, ctx, timeout, args...)
 	if err != nil {
 		logger.Error("failed-to-push", err)
 		return err
@@ -93,6 +131,241 @@ func (a *CfApplication) Push(logger lager.Logger, ctx context.Context, cli cli.C
 	return nil
 }
 
+// Stage stages the package of an app pushed with --no-start and makes the
+// droplet the app's current one, so that its droplet can be copied to other
+// apps before any app is started. It returns the droplet's guid.
+func (a *CfApplication) Stage(logger lager.Logger, ctx context.Context, cli cli.CFClient, timeout time.Duration) (string, error) {
+	logger = logger.Session("stage", lager.Data{"app": a.appName})
+	logger.Info("started")
+
+	guid, err := a.Guid(logger, ctx, cli, timeout)
+	if err != nil {
+		return "", err
+	}
+
+	var packages struct {
+		Resources []struct {
+			Guid  string `json:"guid"`
+			State string `json:"state"`
+		} `json:"resources"`
+	}
+	err = a.poll(ctx, timeout, func() (bool, error) {
+		if err := cfCurl(logger, ctx, cli, timeout, &packages, "/v3/apps/"+guid+"/packages"); err != nil {
+			return false, err
+		}
+		if len(packages.Resources) == 0 {
+			return false, errors.New("app has no package")
+		}
+		return packages.Resources[0].State == "READY", nil
+	})
+	if err != nil {
+		logger.Error("failed-to-upload-package", err)
+		return "", err
+	}
+
+	var build struct {
+		Guid    string `json:"guid"`
+		State   string `json:"state"`
+		Error   string `json:"error"`
+		Droplet *struct {
+			Guid string `json:"guid"`
+		} `json:"droplet"`
+	}
+	body := fmt.Sprintf(`{"package": {"guid": %q}}`, packages.Resources[0].Guid)
+	if err := cfCurl(logger, ctx, cli, timeout, &build, "/v3/builds", "-X", "POST", "-d", body); err != nil {
+		logger.Error("failed-to-create-build", err)
+		return "", err
+	}
+	err = a.poll(ctx, timeout, func() (bool, error) {
+		if err := cfCurl(logger, ctx, cli, timeout, &build, "/v3/builds/"+build.Guid); err != nil {
+			return false, err
+		}
+		switch build.State {
+		case "STAGED":
+			return build.Droplet != nil, nil
+		case "FAILED":
+			return false, fmt.Errorf("staging failed: %s", build.Error)
+		}
+		return false, nil
+	})
+	if err != nil {
+		logger.Error("failed-to-stage", err)
+		return "", err
+	}
+
+	if err := setCurrentDroplet(logger, ctx, cli, timeout, guid, build.Droplet.Guid); err != nil {
+		logger.Error("failed-to-set-droplet", err)
+		return "", err
+	}
+	logger.Info("completed", lager.Data{"droplet": build.Droplet.Guid})
+	return build.Droplet.Guid, nil
+}
+
+// PushDroplet creates the app from its manifest without uploading a payload,
+// and gives it a copy of an already staged droplet so that starting it does
+// not stage it again.
+func (a *CfApplication) PushDroplet(logger lager.Logger, ctx context.Context, cli cli.CFClient, droplet string, timeout time.Duration) error {
+	logger = logger.Session("push-droplet", lager.Data{"app": a.appName, "droplet": droplet})
+	logger.Info("started")
+
+	manifest, err := a.Manifest()
+	if err == nil {
+		manifest, err = config.NamedManifest(manifest, a.appName)
+	}
+	if err != nil {
+		logger.Error("failed-to-write-manifest", err)
+		return err
+	}
+	manifestPath, err := writeTempFile(a.manifestDir(), a.appName+"-manifest-", manifest)
+	if err != nil {
+		logger.Error("failed-to-write-manifest", err)
+		return err
+	}
+	defer os.Remove(manifestPath)
+
+	if _, err := cli.Cf(logger, ctx, timeout, "apply-manifest", "-f", manifestPath); err != nil {
+		logger.Error("failed-to-apply-manifest", err)
+		return err
+	}
+	if _, err := cli.Cf(logger, ctx, timeout, "map-route", a.appName, a.domain, "--hostname", a.appName); err != nil {
+		logger.Error("failed-to-map-route", err)
+		return err
+	}
+
+	guid, err := a.Guid(logger, ctx, cli, timeout)
+	if err != nil {
+		return err
+	}
+
+	var copied struct {
+		Guid  string `json:"guid"`
+		State string `json:"state"`
+		Error string `json:"error"`
+	}
+	body := fmt.Sprintf(`{"relationships": {"app": {"data": {"guid": %q}}}}`, guid)
+	if err := cfCurl(logger, ctx, cli, timeout, &copied, "/v3/droplets?source_guid="+droplet, "-X", "POST", "-d", body); err != nil {
+		logger.Error("failed-to-copy-droplet", err)
+		return err
+	}
+	err = a.poll(ctx, timeout, func() (bool, error) {
+		switch copied.State {
+		case "STAGED":
+			return true, nil
+		case "FAILED", "EXPIRED":
+			return false, fmt.Errorf("droplet copy %s: %s", strings.ToLower(copied.State), copied.Error)
+		}
+		return false, cfCurl(logger, ctx, cli, timeout, &copied, "/v3/droplets/"+copied.Guid)
+	})
+	if err != nil {
+		logger.Error("failed-to-copy-droplet", err)
+		return err
+	}
+
+	if err := setCurrentDroplet(logger, ctx, cli, timeout, guid, copied.Guid); err != nil {
+		logger.Error("failed-to-set-droplet", err)
+		return err
+	}
+
+	endpointToHit := a.AppURL()
+	if _, err := cli.Cf(logger, ctx, timeout, "set-env", a.appName, "ENDPOINT_TO_HIT", endpointToHit); err != nil {
+		logger.Error("failed-to-set-env", err)
+		return err
+	}
+	logger.Info("completed")
+	return nil
+}
+
+// poll calls check until it reports that it is done, it fails, or the
+// timeout passes.
+func (a *CfApplication) poll(ctx context.Context, timeout time.Duration, check func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dropletPollInterval):
+		}
+	}
+}
+
+func setCurrentDroplet(logger lager.Logger, ctx context.Context, cli cli.CFClient, timeout time.Duration, appGuid, dropletGuid string) error {
+	body := fmt.Sprintf(`{"data": {"guid": %q}}`, dropletGuid)
+	return cfCurl(logger, ctx, cli, timeout, nil, "/v3/apps/"+appGuid+"/relationships/current_droplet", "-X", "PATCH", "-d", body)
+}
+
+// cfCurl runs cf curl and decodes the response, failing on CC errors, which
+// cf curl does not exit non-zero for.
+func cfCurl(logger lager.Logger, ctx context.Context, cli cli.CFClient, timeout time.Duration, response interface{}, path string, args ...string) error {
+	out, err := cli.Cf(logger, ctx, timeout, append([]string{"curl", path}, args...)...)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(out))) == 0 {
+		return nil
+	}
+
+	var ccErrors struct {
+		Errors []struct {
+			Detail string `json:"detail"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(out, &ccErrors); err != nil {
+		return err
+	}
+	if len(ccErrors.Errors) > 0 {
+		return errors.New(ccErrors.Errors[0].Detail)
+	}
+	if response == nil {
+		return nil
+	}
+	return json.Unmarshal(out, response)
+}
+
+// Manifest renders the manifest the app is pushed with.
+func (a *CfApplication) Manifest() ([]byte, error) {
+	return a.definition.RenderManifest(a.variables)
+}
+
+// writeManifest renders the app's manifest to a temporary file.
+func (a *CfApplication) writeManifest() (string, error) {
+	manifest, err := a.Manifest()
+	if err != nil {
+		return "", err
+	}
+	return writeTempFile(a.manifestDir(), a.appName+"-manifest-", manifest)
+}
+
+// manifestDir is where the rendered manifest is written. cf resolves relative
+// paths in a manifest from the manifest's own directory, so a rendered
+// manifest file sits next to the file it was rendered from; inline manifests
+// have no paths and go to the temporary directory.
+func (a *CfApplication) manifestDir() string {
+	if a.definition.ManifestPath == "" {
+		return ""
+	}
+	return filepath.Dir(a.definition.ManifestPath)
+}
+
+func writeTempFile(dir, prefix string, contents []byte) (string, error) {
+	f, err := ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(contents); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
 func (a *CfApplication) Start(logger lager.Logger, ctx context.Context, cli cli.CFClient, skipVerifyCertificate bool, timeout time.Duration) error {
 	logger = logger.Session("start", lager.Data{"app": a.appName})
 	logger.Info("started")
@@ -105,13 +378,23 @@ func (a *CfApplication) Start(logger lager.Logger, ctx context.Context, cli cli.
 	response, err := a.curl(ctx, skipVerifyCertificate)
 	if err != nil {
 		logger.Error("failed-curling-app", err)
-		return err
+		return CurlError{err}
 	}
 	logger.Info("completed")
 	logger.Debug("successful-response-starting", lager.Data{"response": response})
 	return nil
 }
 
+// CurlError is returned by Start when the app started but could not be
+// curled.
+type CurlError struct {
+	Err error
+}
+
+func (e CurlError) Error() string {
+	return e.Err.Error()
+}
+
 func (a *CfApplication) Guid(logger lager.Logger, ctx context.Context, cli cli.CFClient, timeout time.Duration) (string, error) {
 	logger = logger.Session("guid", lager.Data{"app": a.appName})
 	logger.Info("started")
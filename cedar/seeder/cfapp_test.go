@@ -2,12 +2,18 @@ package seeder_test
 
 import (
 	"errors"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/diego-stress-tests/cedar/cli/fakes"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
 	. "code.cloudfoundry.org/diego-stress-tests/cedar/seeder"
+	"code.cloudfoundry.org/lager"
 	"golang.org/x/net/context"
 
 	. "github.com/onsi/ginkgo"
@@ -26,8 +32,15 @@ var _ = Describe("Cfapp", func() {
 	var ctx context.Context
 	var err error
 	var server *ghttp.Server
+	var manifestDir, manifestFile string
 
 	BeforeEach(func() {
+		manifestDir, err = ioutil.TempDir("", "manifests")
+		Expect(err).NotTo(HaveOccurred())
+		manifestFile = filepath.Join(manifestDir, "test-manifest.yml")
+		err = ioutil.WriteFile(manifestFile, []byte("---\napplications:\n- env:\n    APP_NAME: {{.AppName}}\n"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
 		ctx, _ = context.WithCancel(
 			context.WithValue(context.Background(),
 				"logger",
@@ -38,7 +51,7 @@ var _ = Describe("Cfapp", func() {
 
 		fakeClient = fakes.FakeCFClient{}
 
-		cfApp, err = NewCfApp("test-app", "random-123-domain.com", false, 1, "test-manifest.yml")
+		cfApp, err = NewCfApp("test-app", "random-123-domain.com", false, 1, config.AppDefinition{ManifestPath: manifestFile}, config.TemplateVariables{AppName: "test-app"})
 
 		(cfApp.(*CfApplication)).SetUrl(server.URL())
 
@@ -48,6 +61,7 @@ var _ = Describe("Cfapp", func() {
 
 	AfterEach(func() {
 		server.Close()
+		os.RemoveAll(manifestDir)
 	})
 
 	Context("When an app is pushed", func() {
@@ -61,6 +75,116 @@ var _ = Describe("Cfapp", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(fakeLogger).To(gbytes.Say("push.completed"))
 		})
+
+		It("pushes with the app type's manifest rendered for the app", func() {
+			var manifest []byte
+			fakeClient.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+				if args[0] == "push" {
+					Expect(args).To(Equal([]string{"push", "test-app", "-p", "random-dir", "-f", args[5], "--no-start"}))
+					manifest, err = ioutil.ReadFile(args[5])
+					Expect(err).NotTo(HaveOccurred())
+				}
+				return []byte{}, nil
+			}
+
+			err = cfApp.Push(fakeLogger, ctx, &fakeClient, "random-dir", timeout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(manifest)).To(Equal("---\napplications:\n- env:\n    APP_NAME: test-app\n"))
+		})
+
+		It("writes the rendered manifest next to the app type's manifest so relative paths still resolve", func() {
+			var manifestPath string
+			fakeClient.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+				if args[0] == "push" {
+					manifestPath = args[5]
+				}
+				return []byte{}, nil
+			}
+
+			err = cfApp.Push(fakeLogger, ctx, &fakeClient, "random-dir", timeout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filepath.Dir(manifestPath)).To(Equal(manifestDir))
+			_, err = os.Stat(manifestPath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("fails when the manifest does not render", func() {
+			err = ioutil.WriteFile(manifestFile, []byte("memory: {{.Vars.memory}}M\n"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = cfApp.Push(fakeLogger, ctx, &fakeClient, "random-dir", timeout)
+			Expect(err).To(HaveOccurred())
+			Expect(fakeLogger).To(gbytes.Say("push.failed-to-write-manifest"))
+			Expect(fakeClient.CfCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("When a docker app is pushed", func() {
+		BeforeEach(func() {
+			definition := config.AppDefinition{ManifestPath: manifestFile, AppProfile: config.AppProfile{DockerImage: "registry.local:5000/stress-app"}}
+			cfApp, err = NewCfApp("test-app", "random-123-domain.com", false, 1, definition, config.TemplateVariables{AppName: "test-app"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("pushes the image instead of a payload", func() {
+			err = cfApp.Push(fakeLogger, ctx, &fakeClient, "random-dir", timeout)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, args := fakeClient.CfArgsForCall(0)
+			Expect(args).To(Equal([]string{"push", "test-app", "--docker-image", "registry.local:5000/stress-app", "-f", args[5], "--no-start"}))
+		})
+	})
+
+	Context("When an app with an inline manifest is pushed", func() {
+		var manifestPath, manifest string
+
+		BeforeEach(func() {
+			cfApp, err = NewCfApp("test-app", "random-123-domain.com", false, 1, config.AppDefinition{
+				AppNamePrefix: "inline",
+				AppCount:      1,
+				AppProfile: config.AppProfile{
+					Instances: "2",
+					Memory:    "{{.Vars.memory}}M",
+					Buildpack: "binary_buildpack",
+					Command:   "./stress-app",
+					Env:       map[string]config.Value{"LOGS_PER_SECOND": "1"},
+				},
+			}, config.TemplateVariables{AppName: "test-app", Vars: map[string]string{"memory": "64"}})
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeClient.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+				if args[0] == "push" {
+					manifestPath = args[5]
+					content, err := ioutil.ReadFile(manifestPath)
+					Expect(err).NotTo(HaveOccurred())
+					manifest = string(content)
+				}
+				return []byte{}, nil
+			}
+		})
+
+		It("pushes with a manifest generated from the app type", func() {
+			err = cfApp.Push(fakeLogger, ctx, &fakeClient, "random-dir", timeout)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifest).To(MatchJSON(`{
+				"applications": [{
+					"instances": 2,
+					"memory": "64M",
+					"buildpack": "binary_buildpack",
+					"command": "./stress-app",
+					"env": {"LOGS_PER_SECOND": "1"}
+				}]
+			}`))
+		})
+
+		It("removes the generated manifest", func() {
+			err = cfApp.Push(fakeLogger, ctx, &fakeClient, "random-dir", timeout)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = os.Stat(manifestPath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
 	})
 
 	Context("When an app is started", func() {
@@ -108,11 +232,11 @@ var _ = Describe("Cfapp", func() {
 
 		It("should not retry when it's not requested", func() {
 			maxFailedCurls := 0
-			cfApp, _ = NewCfApp("test-app", "random-123-domain.com", false, maxFailedCurls, "test-manifest.yml")
+			cfApp, _ = NewCfApp("test-app", "random-123-domain.com", false, maxFailedCurls, config.AppDefinition{ManifestPath: manifestFile}, config.TemplateVariables{AppName: "test-app"})
 			(cfApp.(*CfApplication)).SetUrl(server.URL())
 			err = cfApp.Start(fakeLogger, ctx, &fakeClient, false, timeout)
 
-			Expect(err).To(HaveOccurred())
+			Expect(err).To(BeAssignableToTypeOf(CurlError{}))
 
 			Expect(fakeLogger).NotTo(gbytes.Say("curl.retrying-curl"))
 			Expect(fakeLogger).To(gbytes.Say("curl.failed-to-curl"))
@@ -122,7 +246,7 @@ var _ = Describe("Cfapp", func() {
 
 		It("should retry curl when it's requested", func() {
 			maxFailedCurls := 2
-			cfApp, _ = NewCfApp("test-app", "random-123-domain.com", false, maxFailedCurls, "test-manifest.yml")
+			cfApp, _ = NewCfApp("test-app", "random-123-domain.com", false, maxFailedCurls, config.AppDefinition{ManifestPath: manifestFile}, config.TemplateVariables{AppName: "test-app"})
 			(cfApp.(*CfApplication)).SetUrl(server.URL())
 			err = cfApp.Start(fakeLogger, ctx, &fakeClient, false, timeout)
 
@@ -136,6 +260,87 @@ var _ = Describe("Cfapp", func() {
 		})
 	})
 
+	Context("When an app is staged", func() {
+		var responses map[string]string
+
+		BeforeEach(func() {
+			responses = map[string]string{
+				"app --guid test-app":                                              "app-guid\n",
+				"curl /v3/apps/app-guid/packages":                                  `{"resources": [{"guid": "package-guid", "state": "READY"}]}`,
+				`curl /v3/builds -X POST -d {"package": {"guid": "package-guid"}}`: `{"guid": "build-guid", "state": "STAGING"}`,
+				"curl /v3/builds/build-guid":                                       `{"guid": "build-guid", "state": "STAGED", "droplet": {"guid": "droplet-guid"}}`,
+				`curl /v3/apps/app-guid/relationships/current_droplet -X PATCH -d {"data": {"guid": "droplet-guid"}}`: `{"data": {"guid": "droplet-guid"}}`,
+			}
+			fakeClient.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+				response, ok := responses[strings.Join(args, " ")]
+				if !ok {
+					return nil, errors.New("unexpected command: " + strings.Join(args, " "))
+				}
+				return []byte(response), nil
+			}
+		})
+
+		It("builds its package and makes the droplet current", func() {
+			droplet, err := cfApp.Stage(fakeLogger, ctx, &fakeClient, timeout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(droplet).To(Equal("droplet-guid"))
+			Expect(fakeClient.CfCallCount()).To(Equal(5))
+		})
+
+		It("returns an error when staging fails", func() {
+			responses["curl /v3/builds/build-guid"] = `{"guid": "build-guid", "state": "FAILED", "error": "NoAppDetectedError"}`
+			_, err := cfApp.Stage(fakeLogger, ctx, &fakeClient, timeout)
+			Expect(err).To(MatchError("staging failed: NoAppDetectedError"))
+			Expect(fakeLogger).To(gbytes.Say("stage.failed-to-stage"))
+		})
+	})
+
+	Context("When an app is pushed with a droplet", func() {
+		var (
+			manifest  []byte
+			responses map[string]string
+		)
+
+		BeforeEach(func() {
+			responses = map[string]string{
+				"map-route test-app random-123-domain.com --hostname test-app": "OK",
+				"app --guid test-app": "app-guid\n",
+				`curl /v3/droplets?source_guid=droplet-guid -X POST -d {"relationships": {"app": {"data": {"guid": "app-guid"}}}}`: `{"guid": "copy-guid", "state": "STAGED"}`,
+				`curl /v3/apps/app-guid/relationships/current_droplet -X PATCH -d {"data": {"guid": "copy-guid"}}`:                 `{"data": {"guid": "copy-guid"}}`,
+				"set-env test-app ENDPOINT_TO_HIT " + cfApp.AppURL():                                                               "OK",
+			}
+			fakeClient.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+				if args[0] == "apply-manifest" {
+					manifest, err = ioutil.ReadFile(args[2])
+					Expect(err).NotTo(HaveOccurred())
+					return []byte("OK"), nil
+				}
+				response, ok := responses[strings.Join(args, " ")]
+				if !ok {
+					return nil, errors.New("unexpected command: " + strings.Join(args, " "))
+				}
+				return []byte(response), nil
+			}
+		})
+
+		It("applies its manifest and copies the droplet without uploading the payload", func() {
+			err := cfApp.PushDroplet(fakeLogger, ctx, &fakeClient, "droplet-guid", timeout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(manifest)).To(Equal("applications:\n- name: test-app\n  env:\n    APP_NAME: test-app\n"))
+			for i := 0; i < fakeClient.CfCallCount(); i++ {
+				_, _, _, args := fakeClient.CfArgsForCall(i)
+				Expect(args[0]).NotTo(Equal("push"))
+			}
+			Expect(fakeLogger).To(gbytes.Say("push-droplet.completed"))
+		})
+
+		It("returns the error of a failed droplet copy", func() {
+			responses[`curl /v3/droplets?source_guid=droplet-guid -X POST -d {"relationships": {"app": {"data": {"guid": "app-guid"}}}}`] = `{"errors": [{"detail": "Droplet not found"}]}`
+			err := cfApp.PushDroplet(fakeLogger, ctx, &fakeClient, "droplet-guid", timeout)
+			Expect(err).To(MatchError("Droplet not found"))
+		})
+	})
+
 	Context("When an app guid is requested", func() {
 		BeforeEach(func() {
 			fakeClient.CfReturns([]byte("fake-guid"), nil)
@@ -152,7 +357,7 @@ var _ = Describe("Cfapp", func() {
 
 	Context("When TLS is not required", func() {
 		BeforeEach(func() {
-			cfApp, err = NewCfApp("test-app", "random-123-domain.com", false, 1, "test-manifest.yml")
+			cfApp, err = NewCfApp("test-app", "random-123-domain.com", false, 1, config.AppDefinition{ManifestPath: manifestFile}, config.TemplateVariables{AppName: "test-app"})
 		})
 
 		It("should use http in app url", func() {
@@ -163,7 +368,7 @@ var _ = Describe("Cfapp", func() {
 
 	Context("When TLS is required", func() {
 		BeforeEach(func() {
-			cfApp, err = NewCfApp("test-app", "random-123-domain.com", true, 1, "test-manifest.yml")
+			cfApp, err = NewCfApp("test-app", "random-123-domain.com", true, 1, config.AppDefinition{ManifestPath: manifestFile}, config.TemplateVariables{AppName: "test-app"})
 		})
 
 		It("should use https in app url", func() {
@@ -2,6 +2,7 @@ package seeder
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 	"sync"
 	"time"
@@ -23,8 +24,13 @@ type State struct {
 
 type AppStateMetrics struct {
 	AppName    *string `json:"app_name"`
+	AppType    string  `json:"app_type"`
 	AppGuid    *string `json:"app_guid"`
 	AppURL     string  `json:"app_url"`
+	Org        string  `json:"org,omitempty"`
+	Space      string  `json:"space,omitempty"`
+	Lifecycle  string  `json:"lifecycle"`
+	PushMode   string  `json:"push_mode"`
 	PushState  *State  `json:"push"`
 	StartState *State  `json:"start"`
 }
@@ -35,25 +41,100 @@ const (
 )
 
 type Deployer struct {
-	errChan chan error
-	config  config.Config
+	budget *FailureBudget
+	config config.Config
 
 	AppsToPush  []CfApp
 	AppsToStart []CfApp
 	AppStates   map[string]*AppStateMetrics
+	Preflight   *PreflightReport
+	Spaces      []SpaceSetup
 
 	client cli.CFClient
+
+	// droplets are the droplets staged for each app type in the
+	// copy-droplet push mode.
+	droplets      map[string]string
+	dropletsMutex *sync.Mutex
 }
 
 func NewDeployer(config config.Config, apps []CfApp, cli cli.CFClient) Deployer {
-	p := Deployer{
-		errChan:    make(chan error, config.MaxAllowedFailures()),
-		AppStates:  make(map[string]*AppStateMetrics),
-		config:     config,
-		AppsToPush: apps,
-		client:     cli,
+	appCounts := map[string]int{}
+	tolerances := map[string]float64{}
+	for _, app := range apps {
+		appDef := app.AppDefinition()
+		appCounts[appDef.AppNamePrefix]++
+		if appDef.Tolerance != nil {
+			tolerances[appDef.AppNamePrefix] = *appDef.Tolerance
+		}
+	}
+	typeMaxFailures := map[string]int{}
+	for appType, tolerance := range tolerances {
+		typeMaxFailures[appType] = int(math.Floor(tolerance * float64(appCounts[appType])))
+	}
+
+	// The global tolerance only covers the apps of types without their own,
+	// so it gets their share of the run's failures. This also scales it down
+	// with a run scaled down by the preflight check.
+	globalApps := 0
+	for _, app := range apps {
+		if _, ok := tolerances[app.AppDefinition().AppNamePrefix]; !ok {
+			globalApps++
+		}
+	}
+	maxAllowedFailures := config.MaxAllowedFailures()
+	if globalApps < config.TotalAppCount() {
+		maxAllowedFailures = int(math.Floor(config.Tolerance() * float64(globalApps)))
+	}
+
+	return Deployer{
+		budget:        NewFailureBudget(maxAllowedFailures, typeMaxFailures),
+		AppStates:     make(map[string]*AppStateMetrics),
+		config:        config,
+		AppsToPush:    apps,
+		client:        cli,
+		droplets:      map[string]string{},
+		dropletsMutex: &sync.Mutex{},
+	}
+}
+
+// skipStopped reports whether the app belongs to an app type that exhausted
+// its own failure tolerance, logging that it is skipped.
+func (p *Deployer) skipStopped(logger lager.Logger, app CfApp) bool {
+	if p.budget.StopReason(app.AppDefinition().AppNamePrefix) == "" {
+		return false
+	}
+	logger.Info("skipped-app-of-stopped-type", lager.Data{"app-name": app.AppName(), "app-type": app.AppDefinition().AppNamePrefix})
+	return true
+}
+
+// Abort fails the run without pushing anything.
+func (p *Deployer) Abort(reason string) {
+	p.budget.Abort(reason)
+}
+
+// recordFailure counts a failure against the failure budget. Once the global
+// budget is exhausted, stop is called to stop the current stage.
+func (p *Deployer) recordFailure(logger lager.Logger, stage string, app CfApp, stop context.CancelFunc, exceededMessage string) {
+	appType := app.AppDefinition().AppNamePrefix
+	stopped := p.budget.StopReason(appType) != ""
+	exceeded := p.budget.Record(stage, appType, app.AppName())
+	if reason := p.budget.StopReason(appType); !stopped && reason != "" {
+		logger.Error("stopped-app-type", nil, lager.Data{
+			"app-type":    appType,
+			"stage":       stage,
+			"stop-reason": reason,
+		})
+	}
+	if exceeded {
+		logger.Error(exceededMessage, nil, lager.Data{
+			"app-type":     appType,
+			"stage":        stage,
+			"abort-reason": p.budget.AbortReason(),
+			"policy":       p.config.FailurePolicy(),
+		})
+		stop()
 	}
-	return p
 }
 
 func (p *Deployer) PushApps(logger lager.Logger, ctx context.Context, cancel context.CancelFunc) {
@@ -61,19 +142,51 @@ func (p *Deployer) PushApps(logger lager.Logger, ctx context.Context, cancel con
 	logger.Info("started")
 	defer logger.Info("complete")
 
+	// With the continue-without-start policy an exhausted budget only stops
+	// pushing, and the apps that were pushed are still started.
+	pushCtx, stopPushing := ctx, cancel
+	if p.config.FailurePolicy() == config.ContinueWithoutStartPolicy {
+		pushCtx, stopPushing = context.WithCancel(ctx)
+		defer stopPushing()
+	}
+
 	stateMutex := &sync.Mutex{}
 	wg := &sync.WaitGroup{}
 	rateLimiter := make(chan struct{}, p.config.MaxInFlight())
 
 	app := p.AppsToPush[0]
-	err := p.pushApp(logger, ctx, app, stateMutex)
+	err := p.pushApp(logger, pushCtx, app, stateMutex)
 	if err != nil {
 		logger.Error("failed-to-push-initial-app", err)
+		p.budget.Abort("push of the initial app " + app.AppName() + " failed")
+		p.budget.Record(Push, app.AppDefinition().AppNamePrefix, app.AppName())
 		cancel()
 		return
 	}
 
-	for _, app := range p.AppsToPush[1:] {
+	apps := p.AppsToPush[1:]
+	if p.config.PushMode() == config.PushModeCopyDroplet {
+		var sources []CfApp
+		sources, apps = dropletSources(app, apps)
+		for _, source := range sources {
+			select {
+			case <-pushCtx.Done():
+				logger.Info("push-cancelled", lager.Data{"app-name": source.AppName()})
+				return
+			default:
+			}
+			if p.skipStopped(logger, source) {
+				continue
+			}
+
+			if err := p.pushApp(logger, pushCtx, source, stateMutex); err != nil {
+				logger.Error("failed-staging-droplet", err)
+				p.recordFailure(logger, Push, source, stopPushing, "exceeded-failure-tolerance")
+			}
+		}
+	}
+
+	for _, app := range apps {
 		app := app
 		wg.Add(1)
 		go func() {
@@ -84,21 +197,19 @@ func (p *Deployer) PushApps(logger lager.Logger, ctx context.Context, cancel con
 			}()
 
 			select {
-			case <-ctx.Done():
+			case <-pushCtx.Done():
 				logger.Info("push-cancelled", lager.Data{"app-name": app.AppName()})
 				return
 			default:
 			}
+			if p.skipStopped(logger, app) {
+				return
+			}
 
-			err := p.pushApp(logger, ctx, app, stateMutex)
+			err := p.pushApp(logger, pushCtx, app, stateMutex)
 			if err != nil {
 				logger.Error("failed-pushing-app", err)
-				select {
-				case p.errChan <- err:
-				default:
-					logger.Error("exceeded-failure-tolerance", nil)
-					cancel()
-				}
+				p.recordFailure(logger, Push, app, stopPushing, "exceeded-failure-tolerance")
 			}
 		}()
 	}
@@ -109,12 +220,13 @@ func (p *Deployer) PushApps(logger lager.Logger, ctx context.Context, cancel con
 
 func (p *Deployer) pushApp(logger lager.Logger, ctx context.Context, app CfApp, stateMutex *sync.Mutex) error {
 	startTime := time.Now()
-	pushErr := app.Push(logger, ctx, p.client, p.config.AppPayload(), p.config.Timeout())
+	client := p.clientFor(app)
+	pushMode, pushErr := p.push(logger, ctx, app, client)
 	endTime := time.Now()
 	succeeded := pushErr == nil
 
 	name := app.AppName()
-	guid, err := app.Guid(logger, ctx, p.client, p.config.Timeout())
+	guid, err := app.Guid(logger, ctx, client, p.timeout(app))
 	if err != nil {
 		logger.Error("failed-getting-app-guid", err)
 	}
@@ -126,10 +238,16 @@ func (p *Deployer) pushApp(logger lager.Logger, ctx context.Context, app CfApp,
 		p.AppsToStart = append(p.AppsToStart, app)
 	}
 
+	target := app.Target()
 	p.AppStates[name] = &AppStateMetrics{
 		AppName:    &name,
+		AppType:    app.AppDefinition().AppNamePrefix,
 		AppGuid:    &guid,
 		AppURL:     app.AppURL(),
+		Org:        target.Org,
+		Space:      target.Space,
+		Lifecycle:  app.AppDefinition().Lifecycle(),
+		PushMode:   pushMode,
 		PushState:  &State{},
 		StartState: &State{},
 	}
@@ -138,6 +256,83 @@ func (p *Deployer) pushApp(logger lager.Logger, ctx context.Context, app CfApp,
 	return pushErr
 }
 
+// push pushes the app, copying the droplet of its app type when one has been
+// staged, and returns the push mode it used. In the copy-droplet push mode
+// an app that has no droplet to copy is staged, and its droplet is kept for
+// the other apps of its type. Docker apps are always pushed from their image.
+func (p *Deployer) push(logger lager.Logger, ctx context.Context, app CfApp, client cli.CFClient) (string, error) {
+	appType := app.AppDefinition().AppNamePrefix
+	if p.config.PushMode() != config.PushModeCopyDroplet || app.AppDefinition().Lifecycle() == config.DockerLifecycle {
+		return config.PushModeStage, app.Push(logger, ctx, client, p.payload(app), p.timeout(app))
+	}
+
+	p.dropletsMutex.Lock()
+	droplet, ok := p.droplets[appType]
+	p.dropletsMutex.Unlock()
+	if ok {
+		return config.PushModeCopyDroplet, app.PushDroplet(logger, ctx, client, droplet, p.timeout(app))
+	}
+
+	if err := app.Push(logger, ctx, client, p.payload(app), p.timeout(app)); err != nil {
+		return config.PushModeStage, err
+	}
+	droplet, err := app.Stage(logger, ctx, client, p.timeout(app))
+	if err != nil {
+		return config.PushModeStage, err
+	}
+
+	p.dropletsMutex.Lock()
+	if _, ok := p.droplets[appType]; !ok {
+		p.droplets[appType] = droplet
+	}
+	p.dropletsMutex.Unlock()
+	return config.PushModeStage, nil
+}
+
+// dropletSources picks the first app of every buildpack type that the
+// initial app does not share a type with, so that their droplets are staged
+// before the other apps copy them.
+func dropletSources(initial CfApp, apps []CfApp) ([]CfApp, []CfApp) {
+	staged := map[string]bool{initial.AppDefinition().AppNamePrefix: true}
+	sources := []CfApp{}
+	rest := []CfApp{}
+	for _, app := range apps {
+		appType := app.AppDefinition().AppNamePrefix
+		if staged[appType] || app.AppDefinition().Lifecycle() == config.DockerLifecycle {
+			rest = append(rest, app)
+			continue
+		}
+		staged[appType] = true
+		sources = append(sources, app)
+	}
+	return sources, rest
+}
+
+// clientFor returns a client that runs cf commands in the app's org and
+// space, or the client as is when the app goes to the space cf targets.
+func (p *Deployer) clientFor(app CfApp) cli.CFClient {
+	if target := app.Target(); target != (cli.Target{}) {
+		return p.client.WithTarget(target)
+	}
+	return p.client
+}
+
+// payload and timeout return the app type's own settings, falling back to
+// the global ones.
+func (p *Deployer) payload(app CfApp) string {
+	if payload := app.AppDefinition().Payload; payload != "" {
+		return payload
+	}
+	return p.config.AppPayload()
+}
+
+func (p *Deployer) timeout(app CfApp) time.Duration {
+	if timeout := app.AppDefinition().Timeout.Duration; timeout > 0 {
+		return timeout
+	}
+	return p.config.Timeout()
+}
+
 func (p *Deployer) StartApps(ctx context.Context, cancel context.CancelFunc) {
 	logger, ok := ctx.Value("logger").(lager.Logger)
 	if !ok {
@@ -147,6 +342,7 @@ func (p *Deployer) StartApps(ctx context.Context, cancel context.CancelFunc) {
 	logger.Info("started")
 	defer logger.Info("completed")
 
+	stopStarting := p.stopStarting(cancel)
 	wg := sync.WaitGroup{}
 	rateLimiter := make(chan struct{}, p.config.MaxInFlight())
 
@@ -162,6 +358,10 @@ func (p *Deployer) StartApps(ctx context.Context, cancel context.CancelFunc) {
 				wg.Done()
 			}()
 
+			if p.skipStopped(logger, appToStart) {
+				return
+			}
+
 			var err error
 			var startTime, endTime time.Time
 			select {
@@ -170,20 +370,19 @@ func (p *Deployer) StartApps(ctx context.Context, cancel context.CancelFunc) {
 				return
 			default:
 				startTime = time.Now()
-				err = appToStart.Start(logger, ctx, p.client, p.config.SkipVerifyCertificate(), p.config.Timeout())
+				err = appToStart.Start(logger, ctx, p.clientFor(appToStart), p.config.SkipVerifyCertificate(), p.timeout(appToStart))
 				endTime = time.Now()
 			}
 
 			if err == nil {
 				logger.Info("started-app", lager.Data{"AppName": appToStart.AppName()})
 			} else {
-				logger.Error("failed-starting-app", err, lager.Data{"total-incurred-failures": len(p.errChan) + 1})
-				select {
-				case p.errChan <- err:
-				default:
-					logger.Error("failure-tolerance-reached", nil)
-					cancel()
+				stage := Start
+				if _, ok := err.(CurlError); ok {
+					stage = Curl
 				}
+				logger.Error("failed-starting-app", err, lager.Data{"stage": stage})
+				p.recordFailure(logger, stage, appToStart, stopStarting, "failure-tolerance-reached")
 			}
 			succeeded := err == nil
 			p.updateReport(Start, appToStart.AppName(), succeeded, startTime, endTime)
@@ -193,9 +392,80 @@ func (p *Deployer) StartApps(ctx context.Context, cancel context.CancelFunc) {
 	wg.Wait()
 }
 
+// stopStarting returns what stops starting apps once the failure budget is
+// exhausted. With the continue-without-start policy a budget that was already
+// exhausted while pushing is started over, so that the pushed apps are still
+// started and their own failures can stop the run.
+func (p *Deployer) stopStarting(cancel context.CancelFunc) context.CancelFunc {
+	if p.config.FailurePolicy() == config.ContinueWithoutStartPolicy && p.budget.AbortReason() != "" {
+		p.budget.ResetGlobalFailures()
+	}
+	return cancel
+}
+
+// CedarReport is written to the output file. AbortReason is empty unless the
+// run failed more than its tolerance allows, or was cancelled.
 type CedarReport struct {
-	Succeeded bool              `json:"succeeded"`
-	Apps      []AppStateMetrics `json:"apps"`
+	Succeeded   bool                       `json:"succeeded"`
+	AbortReason string                     `json:"abort_reason,omitempty"`
+	Failures    FailureCounts              `json:"failures"`
+	Preflight   *PreflightReport           `json:"preflight,omitempty"`
+	Spaces      []SpaceSetup               `json:"spaces,omitempty"`
+	PushMode    string                     `json:"push_mode"`
+	Droplets    map[string]string          `json:"droplets,omitempty"`
+	Apps        []AppStateMetrics          `json:"apps"`
+	AppTypes    map[string]*AppTypeSummary `json:"app_types"`
+}
+
+// AppTypeSummary counts how the apps of one type fared. Apps that were never
+// pushed because the run was cancelled or the type was stopped count towards
+// Apps only. StopReason is empty unless the type exhausted its own tolerance.
+type AppTypeSummary struct {
+	Lifecycle   string  `json:"lifecycle"`
+	Apps        int     `json:"apps"`
+	Pushed      int     `json:"pushed"`
+	Started     int     `json:"started"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"success_rate"`
+	StopReason  string  `json:"stop_reason,omitempty"`
+}
+
+func (p *Deployer) summarizeAppTypes() map[string]*AppTypeSummary {
+	summaries := map[string]*AppTypeSummary{}
+	summary := func(appType string) *AppTypeSummary {
+		if _, ok := summaries[appType]; !ok {
+			summaries[appType] = &AppTypeSummary{}
+		}
+		return summaries[appType]
+	}
+
+	for _, app := range p.AppsToPush {
+		s := summary(app.AppDefinition().AppNamePrefix)
+		s.Lifecycle = app.AppDefinition().Lifecycle()
+		s.Apps++
+	}
+	for _, state := range p.AppStates {
+		s := summary(state.AppType)
+		switch {
+		case state.PushState.Succeeded && state.StartState.Succeeded:
+			s.Pushed++
+			s.Started++
+		case state.PushState.Succeeded:
+			s.Pushed++
+			if state.StartState.StartTime != nil {
+				s.Failed++
+			}
+		default:
+			s.Failed++
+		}
+	}
+	for appType, s := range summaries {
+		if s.Apps > 0 {
+			s.SuccessRate = float64(s.Started) / float64(s.Apps)
+		}
+		s.StopReason = p.budget.StopReason(appType)
+	}
+	return summaries
 }
 
 func (p *Deployer) GenerateReport(ctx context.Context, cancel context.CancelFunc) bool {
@@ -207,16 +477,24 @@ func (p *Deployer) GenerateReport(ctx context.Context, cancel context.CancelFunc
 	logger.Info("started")
 	defer logger.Info("completed")
 
-	succeeded := true
 	select {
 	case <-ctx.Done():
-		succeeded = false
+		p.budget.Abort("the run was cancelled")
 	default:
 	}
+	abortReason := p.budget.AbortReason()
+	succeeded := abortReason == ""
 
 	report := CedarReport{
-		succeeded,
-		[]AppStateMetrics{},
+		Succeeded:   succeeded,
+		AbortReason: abortReason,
+		Failures:    p.budget.Failures(),
+		Preflight:   p.Preflight,
+		Spaces:      p.Spaces,
+		PushMode:    p.config.PushMode(),
+		Droplets:    p.droplets,
+		Apps:        []AppStateMetrics{},
+		AppTypes:    p.summarizeAppTypes(),
 	}
 
 	metricsFile, err := os.OpenFile(p.config.OutputFile(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
@@ -2,6 +2,7 @@ package seeder_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -192,6 +193,61 @@ var _ = Describe("Deployer", func() {
 				})
 			})
 
+			Context("when the failure policy is continue-without-start", func() {
+				var failStarts bool
+
+				BeforeEach(func() {
+					cfg.FailurePolicyReturns(config.ContinueWithoutStartPolicy)
+					failedPushes = 9
+					failStarts = false
+				})
+
+				JustBeforeEach(func() {
+					if failStarts {
+						for _, app := range deployer.AppsToStart {
+							app.(*FakeCfApp).StartReturns(fmt.Errorf("failed-to-start"))
+						}
+					}
+					deployer.StartApps(ctx, cancel)
+				})
+
+				Context("when the pushed apps fail to start more than the tolerance allows", func() {
+					BeforeEach(func() {
+						cfg.MaxAllowedFailuresReturns(0)
+						failStarts = true
+					})
+
+					It("counts the start failures against a budget of their own and cancels starting", func() {
+						Expect(deployer.AppsToStart).NotTo(BeEmpty())
+						Expect(fakeLogger).To(gbytes.Say("failure-tolerance-reached"))
+						Expect(ctx.Done()).To(BeClosed())
+					})
+				})
+
+				It("stops pushing once tolerance is reached", func() {
+					Expect(fakeLogger).To(gbytes.Say("exceeded-failure-tolerance"))
+					Expect(fakeLogger).To(gbytes.Say("push-cancelled"))
+					Expect(len(deployer.AppStates)).To(BeNumerically("<", totalApps))
+				})
+
+				It("still starts the apps that were pushed", func() {
+					Expect(ctx.Done()).NotTo(BeClosed())
+					Expect(deployer.AppsToStart).NotTo(BeEmpty())
+					for _, app := range deployer.AppsToStart {
+						Expect(app.(*FakeCfApp).StartCallCount()).To(Equal(1))
+					}
+				})
+
+				It("fails the run", func() {
+					dir, err := ioutil.TempDir("", "report")
+					Expect(err).NotTo(HaveOccurred())
+					defer os.RemoveAll(dir)
+					cfg.OutputFileReturns(filepath.Join(dir, "report.json"))
+
+					Expect(deployer.GenerateReport(ctx, cancel)).To(BeFalse())
+				})
+			})
+
 			Context("when number of failing apps is less than max failures allowed", func() {
 				BeforeEach(func() {
 					failedPushes = 1
@@ -223,6 +279,21 @@ var _ = Describe("Deployer", func() {
 			})
 		})
 
+		Context("when the run was scaled down", func() {
+			BeforeEach(func() {
+				cfg.ToleranceReturns(0.25)
+				cfg.TotalAppCountReturns(2 * totalApps)
+				appNames, apps = generateFakeApps(FakeCounts{total: totalApps, failingPush: 4, failingStart: 0})
+				deployer = seeder.NewDeployer(cfg, apps, fakeCli)
+				deployer.PushApps(fakeLogger, ctx, cancel)
+			})
+
+			It("scales the failure tolerance down with it", func() {
+				Expect(fakeLogger).To(gbytes.Say("exceeded-failure-tolerance"))
+				Expect(ctx.Done()).To(BeClosed())
+			})
+		})
+
 		Context("when the first app fails", func() {
 			JustBeforeEach(func() {
 				appNames, apps = generateFakeApps(FakeCounts{total: totalApps, failingPush: 1, failingStart: 0, firstAppFailed: true})
@@ -249,6 +320,394 @@ var _ = Describe("Deployer", func() {
 			})
 		})
 
+		Context("when an app type sets its own payload and timeout", func() {
+			var customApp, defaultApp *FakeCfApp
+
+			BeforeEach(func() {
+				customApp = &FakeCfApp{}
+				customApp.AppNameReturns("custom-app")
+				customApp.AppDefinitionReturns(config.AppDefinition{
+					AppNamePrefix: "custom",
+					AppProfile: config.AppProfile{
+						Payload: "assets/custom-folder",
+						Timeout: config.Duration{Duration: time.Minute},
+					},
+				})
+				defaultApp = &FakeCfApp{}
+				defaultApp.AppNameReturns("default-app")
+
+				deployer = seeder.NewDeployer(cfg, []seeder.CfApp{customApp, defaultApp}, fakeCli)
+				deployer.PushApps(fakeLogger, ctx, cancel)
+				deployer.StartApps(ctx, cancel)
+			})
+
+			It("pushes and starts the app type with its own settings", func() {
+				_, _, _, payload, timeout := customApp.PushArgsForCall(0)
+				Expect(payload).To(Equal("assets/custom-folder"))
+				Expect(timeout).To(Equal(time.Minute))
+
+				_, _, _, _, timeout = customApp.StartArgsForCall(0)
+				Expect(timeout).To(Equal(time.Minute))
+			})
+
+			It("falls back to the global settings for other app types", func() {
+				_, _, _, payload, timeout := defaultApp.PushArgsForCall(0)
+				Expect(payload).To(Equal("assets/fake-folder"))
+				Expect(timeout).To(Equal(30 * time.Second))
+			})
+		})
+
+		Context("when an app is pushed to its own org and space", func() {
+			var (
+				targetedApp, defaultApp *FakeCfApp
+				targetedCli             *FakeCFClient
+			)
+
+			BeforeEach(func() {
+				target := cli.Target{Org: "cedarapp-org-0", Space: "cedarapp-space-1"}
+				targetedApp = &FakeCfApp{}
+				targetedApp.AppNameReturns("targeted-app")
+				targetedApp.TargetReturns(target)
+				defaultApp = &FakeCfApp{}
+				defaultApp.AppNameReturns("default-app")
+
+				targetedCli = &FakeCFClient{}
+				fakeCli.(*FakeCFClient).WithTargetReturns(targetedCli)
+
+				deployer = seeder.NewDeployer(cfg, []seeder.CfApp{defaultApp, targetedApp}, fakeCli)
+				deployer.PushApps(fakeLogger, ctx, cancel)
+				deployer.StartApps(ctx, cancel)
+			})
+
+			It("runs its cf commands in that space", func() {
+				Expect(fakeCli.(*FakeCFClient).WithTargetArgsForCall(0)).To(Equal(cli.Target{Org: "cedarapp-org-0", Space: "cedarapp-space-1"}))
+
+				_, _, client, _, _ := targetedApp.PushArgsForCall(0)
+				Expect(client).To(BeIdenticalTo(targetedCli))
+				_, _, client, _ = targetedApp.GuidArgsForCall(0)
+				Expect(client).To(BeIdenticalTo(targetedCli))
+				_, _, client, _, _ = targetedApp.StartArgsForCall(0)
+				Expect(client).To(BeIdenticalTo(targetedCli))
+
+				_, _, client, _, _ = defaultApp.PushArgsForCall(0)
+				Expect(client).To(BeIdenticalTo(fakeCli))
+			})
+
+			It("records the space of the app", func() {
+				Expect(deployer.AppStates["targeted-app"].Org).To(Equal("cedarapp-org-0"))
+				Expect(deployer.AppStates["targeted-app"].Space).To(Equal("cedarapp-space-1"))
+				Expect(deployer.AppStates["default-app"].Space).To(BeEmpty())
+			})
+		})
+
+		Context("when pushing by copying droplets", func() {
+			var light, heavy []*FakeCfApp
+
+			newApp := func(name, appType string) *FakeCfApp {
+				app := &FakeCfApp{}
+				app.AppNameReturns(name)
+				app.AppDefinitionReturns(config.AppDefinition{AppNamePrefix: appType})
+				app.StageReturns(appType+"-droplet", nil)
+				return app
+			}
+
+			BeforeEach(func() {
+				cfg.PushModeReturns(config.PushModeCopyDroplet)
+				light = []*FakeCfApp{newApp("light-0", "light"), newApp("light-1", "light"), newApp("light-2", "light")}
+				heavy = []*FakeCfApp{newApp("heavy-0", "heavy"), newApp("heavy-1", "heavy")}
+			})
+
+			JustBeforeEach(func() {
+				pushed := []seeder.CfApp{light[0], light[1], heavy[0], light[2]}
+				for _, app := range heavy[1:] {
+					pushed = append(pushed, app)
+				}
+				deployer = seeder.NewDeployer(cfg, pushed, fakeCli)
+				deployer.PushApps(fakeLogger, ctx, cancel)
+			})
+
+			It("stages one app of each type", func() {
+				for _, app := range []*FakeCfApp{light[0], heavy[0]} {
+					Expect(app.PushCallCount()).To(Equal(1))
+					Expect(app.StageCallCount()).To(Equal(1))
+					Expect(app.PushDropletCallCount()).To(BeZero())
+					Expect(deployer.AppStates[app.AppName()].PushMode).To(Equal(config.PushModeStage))
+				}
+			})
+
+			It("copies the droplet of their type to the other apps", func() {
+				for _, app := range append(light[1:], heavy[1]) {
+					Expect(app.PushCallCount()).To(BeZero())
+					Expect(app.PushDropletCallCount()).To(Equal(1))
+					_, _, _, droplet, _ := app.PushDropletArgsForCall(0)
+					Expect(droplet).To(Equal(app.AppDefinition().AppNamePrefix + "-droplet"))
+					Expect(deployer.AppStates[app.AppName()].PushMode).To(Equal(config.PushModeCopyDroplet))
+				}
+				Expect(deployer.AppsToStart).To(HaveLen(5))
+			})
+
+			Context("when some apps are docker apps", func() {
+				var docker []*FakeCfApp
+
+				BeforeEach(func() {
+					docker = []*FakeCfApp{newApp("docker-0", "docker"), newApp("docker-1", "docker")}
+					for _, app := range docker {
+						app.AppDefinitionReturns(config.AppDefinition{AppNamePrefix: "docker", AppProfile: config.AppProfile{DockerImage: "stress-app"}})
+					}
+					heavy = append(heavy, docker...)
+				})
+
+				It("pushes them from their image", func() {
+					for _, app := range docker {
+						Expect(app.PushCallCount()).To(Equal(1))
+						Expect(app.StageCallCount()).To(BeZero())
+						Expect(app.PushDropletCallCount()).To(BeZero())
+						Expect(deployer.AppStates[app.AppName()].Lifecycle).To(Equal(config.DockerLifecycle))
+					}
+					Expect(deployer.AppStates["light-1"].Lifecycle).To(Equal(config.BuildpackLifecycle))
+				})
+			})
+
+			Context("when staging a type's droplet fails", func() {
+				BeforeEach(func() {
+					heavy[0].StageReturns("", errors.New("staging failed"))
+				})
+
+				It("stages the other apps of the type instead", func() {
+					Expect(heavy[1].PushDropletCallCount()).To(BeZero())
+					Expect(heavy[1].StageCallCount()).To(Equal(1))
+					Expect(deployer.AppStates["heavy-0"].PushState.Succeeded).To(BeFalse())
+					Expect(deployer.AppsToStart).To(HaveLen(4))
+				})
+			})
+		})
+
+		Context("when an app type has its own tolerance", func() {
+			var failingStarts int
+
+			BeforeEach(func() {
+				cfg.MaxAllowedFailuresReturns(0)
+				failingStarts = 2
+			})
+
+			JustBeforeEach(func() {
+				tolerance := 0.5
+				crashing := config.AppDefinition{AppNamePrefix: "crashing", Tolerance: &tolerance}
+				light := config.AppDefinition{AppNamePrefix: "light"}
+
+				apps = []seeder.CfApp{}
+				for i := 0; i < 4; i++ {
+					fakeApp := &FakeCfApp{}
+					fakeApp.AppNameReturns(fmt.Sprintf("fake-crashing-app-%d", i))
+					fakeApp.AppDefinitionReturns(crashing)
+					if i < failingStarts {
+						fakeApp.StartReturns(fmt.Errorf("failed-to-start"))
+					}
+					apps = append(apps, fakeApp)
+				}
+				for i := 0; i < 4; i++ {
+					fakeApp := &FakeCfApp{}
+					fakeApp.AppNameReturns(fmt.Sprintf("fake-light-app-%d", i))
+					fakeApp.AppDefinitionReturns(light)
+					apps = append(apps, fakeApp)
+				}
+
+				deployer = seeder.NewDeployer(cfg, apps, fakeCli)
+				deployer.PushApps(fakeLogger, ctx, cancel)
+				deployer.StartApps(ctx, cancel)
+			})
+
+			It("does not count the type's failures against the global tolerance", func() {
+				Expect(ctx.Done()).NotTo(BeClosed())
+			})
+
+			It("records the app type of every app", func() {
+				for name, state := range deployer.AppStates {
+					Expect(name).To(ContainSubstring(state.AppType))
+				}
+			})
+
+			It("summarizes the app types in the report", func() {
+				dir, err := ioutil.TempDir("", "report")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.RemoveAll(dir)
+				cfg.OutputFileReturns(filepath.Join(dir, "report.json"))
+
+				Expect(deployer.GenerateReport(ctx, cancel)).To(BeTrue())
+
+				content, err := ioutil.ReadFile(filepath.Join(dir, "report.json"))
+				Expect(err).NotTo(HaveOccurred())
+				var report seeder.CedarReport
+				Expect(json.Unmarshal(content, &report)).To(Succeed())
+				Expect(report.AppTypes).To(Equal(map[string]*seeder.AppTypeSummary{
+					"crashing": {Lifecycle: config.BuildpackLifecycle, Apps: 4, Pushed: 4, Started: 2, Failed: 2, SuccessRate: 0.5},
+					"light":    {Lifecycle: config.BuildpackLifecycle, Apps: 4, Pushed: 4, Started: 4, Failed: 0, SuccessRate: 1},
+				}))
+			})
+
+			Context("when the type's failures exceed its tolerance", func() {
+				BeforeEach(func() {
+					failingStarts = 4
+				})
+
+				It("stops starting the type's remaining apps without cancelling the run", func() {
+					Expect(ctx.Done()).NotTo(BeClosed())
+					Expect(fakeLogger).To(gbytes.Say("stopped-app-type.*crashing"))
+					Expect(fakeLogger).To(gbytes.Say("skipped-app-of-stopped-type"))
+
+					crashingStarts, lightStarts := 0, 0
+					for _, app := range apps {
+						if app.AppDefinition().AppNamePrefix == "crashing" {
+							crashingStarts += app.(*FakeCfApp).StartCallCount()
+						} else {
+							lightStarts += app.(*FakeCfApp).StartCallCount()
+						}
+					}
+					Expect(crashingStarts).To(Equal(3))
+					Expect(lightStarts).To(Equal(4))
+				})
+
+				It("reports why the type was stopped", func() {
+					dir, err := ioutil.TempDir("", "report")
+					Expect(err).NotTo(HaveOccurred())
+					defer os.RemoveAll(dir)
+					cfg.OutputFileReturns(filepath.Join(dir, "report.json"))
+
+					Expect(deployer.GenerateReport(ctx, cancel)).To(BeTrue())
+
+					content, err := ioutil.ReadFile(filepath.Join(dir, "report.json"))
+					Expect(err).NotTo(HaveOccurred())
+					var report seeder.CedarReport
+					Expect(json.Unmarshal(content, &report)).To(Succeed())
+					Expect(report.AbortReason).To(BeEmpty())
+					Expect(report.AppTypes["crashing"].StopReason).To(MatchRegexp("start of fake-crashing-app-\\d exceeded the failure tolerance of 2 for app type crashing"))
+					Expect(report.AppTypes["light"].StopReason).To(BeEmpty())
+				})
+			})
+
+			Context("when the type's push failures exceed its tolerance", func() {
+				BeforeEach(func() {
+					failingStarts = 0
+				})
+
+				JustBeforeEach(func() {
+					// Push again with every crashing app failing to push.
+					for _, app := range apps {
+						if app.AppDefinition().AppNamePrefix == "crashing" {
+							app.(*FakeCfApp).PushReturns(fmt.Errorf("failed-to-push"))
+						}
+					}
+					apps = append(apps[4:], apps[:4]...)
+					deployer = seeder.NewDeployer(cfg, apps, fakeCli)
+					deployer.PushApps(fakeLogger, ctx, cancel)
+					deployer.StartApps(ctx, cancel)
+				})
+
+				It("stops pushing the type's remaining apps and still starts the others", func() {
+					Expect(ctx.Done()).NotTo(BeClosed())
+					Expect(deployer.AppsToStart).To(HaveLen(4))
+					for _, app := range deployer.AppsToStart {
+						Expect(app.AppDefinition().AppNamePrefix).To(Equal("light"))
+					}
+				})
+			})
+		})
+
+		Context("when the global tolerance is shared with an app type that has its own", func() {
+			var failingLightStarts int
+
+			BeforeEach(func() {
+				cfg.ToleranceReturns(0.1)
+				cfg.MaxAllowedFailuresReturns(10)
+				cfg.TotalAppCountReturns(100)
+			})
+
+			JustBeforeEach(func() {
+				tolerance := 0.5
+				crashing := config.AppDefinition{AppNamePrefix: "crashing", Tolerance: &tolerance}
+				light := config.AppDefinition{AppNamePrefix: "light"}
+
+				apps = []seeder.CfApp{}
+				for i := 0; i < 10; i++ {
+					fakeApp := &FakeCfApp{}
+					fakeApp.AppNameReturns(fmt.Sprintf("fake-light-app-%d", i))
+					fakeApp.AppDefinitionReturns(light)
+					if i > 0 && i <= failingLightStarts {
+						fakeApp.StartReturns(fmt.Errorf("failed-to-start"))
+					}
+					apps = append(apps, fakeApp)
+				}
+				for i := 0; i < 90; i++ {
+					fakeApp := &FakeCfApp{}
+					fakeApp.AppNameReturns(fmt.Sprintf("fake-crashing-app-%d", i))
+					fakeApp.AppDefinitionReturns(crashing)
+					apps = append(apps, fakeApp)
+				}
+
+				deployer = seeder.NewDeployer(cfg, apps, fakeCli)
+				deployer.PushApps(fakeLogger, ctx, cancel)
+				deployer.StartApps(ctx, cancel)
+			})
+
+			Context("when the light apps fail within their share of the tolerance", func() {
+				BeforeEach(func() {
+					failingLightStarts = 1
+				})
+
+				It("does not cancel the run", func() {
+					Expect(ctx.Done()).NotTo(BeClosed())
+				})
+			})
+
+			Context("when the light apps fail more than their share of the tolerance", func() {
+				BeforeEach(func() {
+					failingLightStarts = 2
+				})
+
+				It("cancels the run", func() {
+					Expect(ctx.Done()).To(BeClosed())
+					Expect(fakeLogger).To(gbytes.Say("failure-tolerance-reached"))
+				})
+			})
+		})
+
+		Context("when the global tolerance covers a share of the apps that does not divide evenly", func() {
+			BeforeEach(func() {
+				cfg.ToleranceReturns(0.1)
+				cfg.MaxAllowedFailuresReturns(1)
+				cfg.TotalAppCountReturns(15)
+
+				tolerance := 0.5
+				crashing := config.AppDefinition{AppNamePrefix: "crashing", Tolerance: &tolerance}
+				light := config.AppDefinition{AppNamePrefix: "light"}
+
+				apps = []seeder.CfApp{}
+				for i := 0; i < 10; i++ {
+					fakeApp := &FakeCfApp{}
+					fakeApp.AppNameReturns(fmt.Sprintf("fake-light-app-%d", i))
+					fakeApp.AppDefinitionReturns(light)
+					if i == 1 {
+						fakeApp.StartReturns(fmt.Errorf("failed-to-start"))
+					}
+					apps = append(apps, fakeApp)
+				}
+				for i := 0; i < 5; i++ {
+					fakeApp := &FakeCfApp{}
+					fakeApp.AppNameReturns(fmt.Sprintf("fake-crashing-app-%d", i))
+					fakeApp.AppDefinitionReturns(crashing)
+					apps = append(apps, fakeApp)
+				}
+
+				deployer = seeder.NewDeployer(cfg, apps, fakeCli)
+				deployer.PushApps(fakeLogger, ctx, cancel)
+				deployer.StartApps(ctx, cancel)
+			})
+
+			It("floors the tolerance of the share only once", func() {
+				Expect(ctx.Done()).NotTo(BeClosed())
+			})
+		})
+
 		Context("when starting apps", func() {
 			Context("when all apps are pushed and started succesfully", func() {
 				BeforeEach(func() {
@@ -295,6 +754,17 @@ var _ = Describe("Deployer", func() {
 						}
 					})
 
+					Context("with the continue-without-start policy", func() {
+						BeforeEach(func() {
+							cfg.FailurePolicyReturns(config.ContinueWithoutStartPolicy)
+						})
+
+						It("still cancels starting once tolerance is reached", func() {
+							Expect(fakeLogger).To(gbytes.Say("failure-tolerance-reached"))
+							Expect(ctx.Done()).To(BeClosed())
+						})
+					})
+
 					It("records the app state correctly", func() {
 						var numFailed = 0
 						for _, r := range deployer.AppStates {
@@ -311,6 +781,33 @@ var _ = Describe("Deployer", func() {
 					})
 				})
 
+				Context("when apps start but cannot be curled", func() {
+					BeforeEach(func() {
+						failedStart = 2
+					})
+
+					JustBeforeEach(func() {
+						apps[1].(*FakeCfApp).StartReturns(seeder.CurlError{Err: fmt.Errorf("failed to curl app url")})
+						deployer = seeder.NewDeployer(cfg, apps, fakeCli)
+						deployer.PushApps(fakeLogger, ctx, cancel)
+						deployer.StartApps(ctx, cancel)
+					})
+
+					It("counts them as curl failures", func() {
+						dir, err := ioutil.TempDir("", "report")
+						Expect(err).NotTo(HaveOccurred())
+						defer os.RemoveAll(dir)
+						cfg.OutputFileReturns(filepath.Join(dir, "report.json"))
+						Expect(deployer.GenerateReport(ctx, cancel)).To(BeTrue())
+
+						content, err := ioutil.ReadFile(filepath.Join(dir, "report.json"))
+						Expect(err).NotTo(HaveOccurred())
+						var report seeder.CedarReport
+						Expect(json.Unmarshal(content, &report)).To(Succeed())
+						Expect(report.Failures).To(Equal(seeder.FailureCounts{Start: 1, Curl: 1}))
+					})
+				})
+
 				Context("when number of apps failing to start is less than max failures allowed", func() {
 					BeforeEach(func() {
 						failedStart = 1
@@ -349,10 +846,18 @@ var _ = Describe("Deployer", func() {
 				failedPushApps   int = 0
 			)
 
-			report := struct {
-				Succeeded bool                     `json:"succeeded"`
-				AppStates []seeder.AppStateMetrics `json:"apps"`
-			}{}
+			var report struct {
+				Succeeded   bool                     `json:"succeeded"`
+				AbortReason string                   `json:"abort_reason"`
+				Failures    seeder.FailureCounts     `json:"failures"`
+				PushMode    string                   `json:"push_mode"`
+				AppStates   []seeder.AppStateMetrics `json:"apps"`
+			}
+
+			BeforeEach(func() {
+				report.AbortReason = ""
+				cfg.PushModeReturns(config.PushModeStage)
+			})
 
 			JustBeforeEach(func() {
 				dir, err = ioutil.TempDir("", "example")
@@ -387,8 +892,12 @@ var _ = Describe("Deployer", func() {
 					err = jsonParser.Decode(&report)
 					Expect(err).NotTo(HaveOccurred())
 					Expect(report.Succeeded).To(BeTrue())
+					Expect(report.AbortReason).To(BeEmpty())
+					Expect(report.Failures).To(Equal(seeder.FailureCounts{Push: 3}))
+					Expect(report.PushMode).To(Equal(config.PushModeStage))
 					Expect(len(report.AppStates)).To(Equal(totalApps))
 					for _, appState := range report.AppStates {
+						Expect(appState.PushMode).To(Equal(config.PushModeStage))
 						Expect(appState.AppURL).NotTo(Equal(""))
 						_, err := url.Parse(appState.AppURL)
 						Expect(err).NotTo(HaveOccurred())
@@ -400,6 +909,20 @@ var _ = Describe("Deployer", func() {
 				})
 			})
 
+			Context("when more apps fail than the tolerance allows", func() {
+				BeforeEach(func() {
+					failedPushApps = 7
+				})
+
+				It("records why the run was aborted", func() {
+					err = jsonParser.Decode(&report)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(report.Succeeded).To(BeFalse())
+					Expect(report.AbortReason).To(MatchRegexp(`^push of fake-push-failing-app-\d exceeded the failure tolerance of 6$`))
+					Expect(report.Failures).To(Equal(seeder.FailureCounts{Push: toleranceNumApps + 1}))
+				})
+			})
+
 			Context("when cedar fails", func() {
 				BeforeEach(func() {
 					failedPushApps = 7
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
 	"code.cloudfoundry.org/diego-stress-tests/cedar/seeder"
 	"code.cloudfoundry.org/lager"
 	"golang.org/x/net/context"
@@ -76,6 +77,66 @@ type FakeCfApp struct {
 		result1 string
 		result2 error
 	}
+	AppDefinitionStub        func() config.AppDefinition
+	appDefinitionMutex       sync.RWMutex
+	appDefinitionArgsForCall []struct{}
+	appDefinitionReturns     struct {
+		result1 config.AppDefinition
+	}
+	appDefinitionReturnsOnCall map[int]struct {
+		result1 config.AppDefinition
+	}
+	ManifestStub        func() ([]byte, error)
+	manifestMutex       sync.RWMutex
+	manifestArgsForCall []struct{}
+	manifestReturns     struct {
+		result1 []byte
+		result2 error
+	}
+	manifestReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
+	TargetStub        func() cli.Target
+	targetMutex       sync.RWMutex
+	targetArgsForCall []struct{}
+	targetReturns     struct {
+		result1 cli.Target
+	}
+	targetReturnsOnCall map[int]struct {
+		result1 cli.Target
+	}
+	StageStub        func(logger lager.Logger, ctx context.Context, client cli.CFClient, timeout time.Duration) (string, error)
+	stageMutex       sync.RWMutex
+	stageArgsForCall []struct {
+		logger  lager.Logger
+		ctx     context.Context
+		client  cli.CFClient
+		timeout time.Duration
+	}
+	stageReturns struct {
+		result1 string
+		result2 error
+	}
+	stageReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	PushDropletStub        func(logger lager.Logger, ctx context.Context, client cli.CFClient, droplet string, timeout time.Duration) error
+	pushDropletMutex       sync.RWMutex
+	pushDropletArgsForCall []struct {
+		logger  lager.Logger
+		ctx     context.Context
+		client  cli.CFClient
+		droplet string
+		timeout time.Duration
+	}
+	pushDropletReturns struct {
+		result1 error
+	}
+	pushDropletReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -318,6 +379,239 @@ func (fake *FakeCfApp) GuidReturnsOnCall(i int, result1 string, result2 error) {
 	}{result1, result2}
 }
 
+func (fake *FakeCfApp) AppDefinition() config.AppDefinition {
+	fake.appDefinitionMutex.Lock()
+	ret, specificReturn := fake.appDefinitionReturnsOnCall[len(fake.appDefinitionArgsForCall)]
+	fake.appDefinitionArgsForCall = append(fake.appDefinitionArgsForCall, struct{}{})
+	fake.recordInvocation("AppDefinition", []interface{}{})
+	fake.appDefinitionMutex.Unlock()
+	if fake.AppDefinitionStub != nil {
+		return fake.AppDefinitionStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.appDefinitionReturns.result1
+}
+
+func (fake *FakeCfApp) AppDefinitionCallCount() int {
+	fake.appDefinitionMutex.RLock()
+	defer fake.appDefinitionMutex.RUnlock()
+	return len(fake.appDefinitionArgsForCall)
+}
+
+func (fake *FakeCfApp) AppDefinitionReturns(result1 config.AppDefinition) {
+	fake.AppDefinitionStub = nil
+	fake.appDefinitionReturns = struct {
+		result1 config.AppDefinition
+	}{result1}
+}
+
+func (fake *FakeCfApp) AppDefinitionReturnsOnCall(i int, result1 config.AppDefinition) {
+	fake.AppDefinitionStub = nil
+	if fake.appDefinitionReturnsOnCall == nil {
+		fake.appDefinitionReturnsOnCall = make(map[int]struct {
+			result1 config.AppDefinition
+		})
+	}
+	fake.appDefinitionReturnsOnCall[i] = struct {
+		result1 config.AppDefinition
+	}{result1}
+}
+
+func (fake *FakeCfApp) Manifest() ([]byte, error) {
+	fake.manifestMutex.Lock()
+	ret, specificReturn := fake.manifestReturnsOnCall[len(fake.manifestArgsForCall)]
+	fake.manifestArgsForCall = append(fake.manifestArgsForCall, struct{}{})
+	fake.recordInvocation("Manifest", []interface{}{})
+	fake.manifestMutex.Unlock()
+	if fake.ManifestStub != nil {
+		return fake.ManifestStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.manifestReturns.result1, fake.manifestReturns.result2
+}
+
+func (fake *FakeCfApp) ManifestCallCount() int {
+	fake.manifestMutex.RLock()
+	defer fake.manifestMutex.RUnlock()
+	return len(fake.manifestArgsForCall)
+}
+
+func (fake *FakeCfApp) ManifestReturns(result1 []byte, result2 error) {
+	fake.ManifestStub = nil
+	fake.manifestReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCfApp) ManifestReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.ManifestStub = nil
+	if fake.manifestReturnsOnCall == nil {
+		fake.manifestReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.manifestReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCfApp) Target() cli.Target {
+	fake.targetMutex.Lock()
+	ret, specificReturn := fake.targetReturnsOnCall[len(fake.targetArgsForCall)]
+	fake.targetArgsForCall = append(fake.targetArgsForCall, struct{}{})
+	fake.recordInvocation("Target", []interface{}{})
+	fake.targetMutex.Unlock()
+	if fake.TargetStub != nil {
+		return fake.TargetStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.targetReturns.result1
+}
+
+func (fake *FakeCfApp) TargetCallCount() int {
+	fake.targetMutex.RLock()
+	defer fake.targetMutex.RUnlock()
+	fake.stageMutex.RLock()
+	defer fake.stageMutex.RUnlock()
+	fake.pushDropletMutex.RLock()
+	defer fake.pushDropletMutex.RUnlock()
+	return len(fake.targetArgsForCall)
+}
+
+func (fake *FakeCfApp) TargetReturns(result1 cli.Target) {
+	fake.TargetStub = nil
+	fake.targetReturns = struct {
+		result1 cli.Target
+	}{result1}
+}
+
+func (fake *FakeCfApp) TargetReturnsOnCall(i int, result1 cli.Target) {
+	fake.TargetStub = nil
+	if fake.targetReturnsOnCall == nil {
+		fake.targetReturnsOnCall = make(map[int]struct {
+			result1 cli.Target
+		})
+	}
+	fake.targetReturnsOnCall[i] = struct {
+		result1 cli.Target
+	}{result1}
+}
+
+func (fake *FakeCfApp) Stage(logger lager.Logger, ctx context.Context, client cli.CFClient, timeout time.Duration) (string, error) {
+	fake.stageMutex.Lock()
+	ret, specificReturn := fake.stageReturnsOnCall[len(fake.stageArgsForCall)]
+	fake.stageArgsForCall = append(fake.stageArgsForCall, struct {
+		logger  lager.Logger
+		ctx     context.Context
+		client  cli.CFClient
+		timeout time.Duration
+	}{logger, ctx, client, timeout})
+	fake.recordInvocation("Stage", []interface{}{logger, ctx, client, timeout})
+	fake.stageMutex.Unlock()
+	if fake.StageStub != nil {
+		return fake.StageStub(logger, ctx, client, timeout)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.stageReturns.result1, fake.stageReturns.result2
+}
+
+func (fake *FakeCfApp) StageCallCount() int {
+	fake.stageMutex.RLock()
+	defer fake.stageMutex.RUnlock()
+	return len(fake.stageArgsForCall)
+}
+
+func (fake *FakeCfApp) StageArgsForCall(i int) (lager.Logger, context.Context, cli.CFClient, time.Duration) {
+	fake.stageMutex.RLock()
+	defer fake.stageMutex.RUnlock()
+	return fake.stageArgsForCall[i].logger, fake.stageArgsForCall[i].ctx, fake.stageArgsForCall[i].client, fake.stageArgsForCall[i].timeout
+}
+
+func (fake *FakeCfApp) StageReturns(result1 string, result2 error) {
+	fake.StageStub = nil
+	fake.stageReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCfApp) StageReturnsOnCall(i int, result1 string, result2 error) {
+	fake.StageStub = nil
+	if fake.stageReturnsOnCall == nil {
+		fake.stageReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.stageReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCfApp) PushDroplet(logger lager.Logger, ctx context.Context, client cli.CFClient, droplet string, timeout time.Duration) error {
+	fake.pushDropletMutex.Lock()
+	ret, specificReturn := fake.pushDropletReturnsOnCall[len(fake.pushDropletArgsForCall)]
+	fake.pushDropletArgsForCall = append(fake.pushDropletArgsForCall, struct {
+		logger  lager.Logger
+		ctx     context.Context
+		client  cli.CFClient
+		droplet string
+		timeout time.Duration
+	}{logger, ctx, client, droplet, timeout})
+	fake.recordInvocation("PushDroplet", []interface{}{logger, ctx, client, droplet, timeout})
+	fake.pushDropletMutex.Unlock()
+	if fake.PushDropletStub != nil {
+		return fake.PushDropletStub(logger, ctx, client, droplet, timeout)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.pushDropletReturns.result1
+}
+
+func (fake *FakeCfApp) PushDropletCallCount() int {
+	fake.pushDropletMutex.RLock()
+	defer fake.pushDropletMutex.RUnlock()
+	return len(fake.pushDropletArgsForCall)
+}
+
+func (fake *FakeCfApp) PushDropletArgsForCall(i int) (lager.Logger, context.Context, cli.CFClient, string, time.Duration) {
+	fake.pushDropletMutex.RLock()
+	defer fake.pushDropletMutex.RUnlock()
+	return fake.pushDropletArgsForCall[i].logger, fake.pushDropletArgsForCall[i].ctx, fake.pushDropletArgsForCall[i].client, fake.pushDropletArgsForCall[i].droplet, fake.pushDropletArgsForCall[i].timeout
+}
+
+func (fake *FakeCfApp) PushDropletReturns(result1 error) {
+	fake.PushDropletStub = nil
+	fake.pushDropletReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCfApp) PushDropletReturnsOnCall(i int, result1 error) {
+	fake.PushDropletStub = nil
+	if fake.pushDropletReturnsOnCall == nil {
+		fake.pushDropletReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.pushDropletReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeCfApp) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -331,6 +625,16 @@ func (fake *FakeCfApp) Invocations() map[string][][]interface{} {
 	defer fake.startMutex.RUnlock()
 	fake.guidMutex.RLock()
 	defer fake.guidMutex.RUnlock()
+	fake.appDefinitionMutex.RLock()
+	defer fake.appDefinitionMutex.RUnlock()
+	fake.manifestMutex.RLock()
+	defer fake.manifestMutex.RUnlock()
+	fake.targetMutex.RLock()
+	defer fake.targetMutex.RUnlock()
+	fake.stageMutex.RLock()
+	defer fake.stageMutex.RUnlock()
+	fake.pushDropletMutex.RLock()
+	defer fake.pushDropletMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -0,0 +1,171 @@
+package seeder
+
+import (
+	"fmt"
+	"sort"
+
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
+	"code.cloudfoundry.org/lager"
+)
+
+// Plan is what a run would push, as printed by a dry run. Problems lists
+// everything that would make the run fail before it starts, such as apps
+// that already exist or a run that does not fit in the org or space quota.
+type Plan struct {
+	Target             cli.Target                   `json:"target"`
+	Domain             string                       `json:"domain"`
+	Seed               int64                        `json:"seed"`
+	NumBatches         int                          `json:"num_batches"`
+	TotalApps          int                          `json:"total_apps"`
+	MaxAllowedFailures int                          `json:"max_allowed_failures"`
+	FailurePolicy      string                       `json:"failure_policy"`
+	PushMode           string                       `json:"push_mode"`
+	Resources          PlannedResources             `json:"resources"`
+	AppTypes           map[string]*PlannedResources `json:"app_types"`
+	Apps               []PlannedApp                 `json:"apps"`
+	Collisions         []string                     `json:"collisions"`
+	Quotas             []cli.Quota                  `json:"quotas"`
+	Problems           []string                     `json:"problems"`
+}
+
+// PlannedResources add up the resources of several apps.
+type PlannedResources struct {
+	Apps      int   `json:"apps"`
+	Instances int   `json:"instances"`
+	MemoryMB  int64 `json:"memory_mb"`
+	DiskMB    int64 `json:"disk_mb"`
+}
+
+func (r *PlannedResources) add(resources config.Resources) {
+	r.Apps++
+	r.Instances += resources.Instances
+	r.MemoryMB += resources.TotalMemoryMB()
+	r.DiskMB += resources.TotalDiskMB()
+}
+
+type PlannedApp struct {
+	Name      string `json:"name"`
+	AppType   string `json:"app_type"`
+	URL       string `json:"url"`
+	Org       string `json:"org"`
+	Space     string `json:"space"`
+	Lifecycle string `json:"lifecycle"`
+	config.Resources
+}
+
+// newPlannedApp plans the app in its own org and space, or in the current
+// target when it has none.
+func newPlannedApp(app CfApp, current cli.Target, resources config.Resources) PlannedApp {
+	target := app.Target()
+	if target == (cli.Target{}) {
+		target = current
+	}
+	return PlannedApp{
+		Name:      app.AppName(),
+		AppType:   app.AppDefinition().AppNamePrefix,
+		URL:       app.AppURL(),
+		Org:       target.Org,
+		Space:     target.Space,
+		Lifecycle: app.AppDefinition().Lifecycle(),
+		Resources: resources,
+	}
+}
+
+func (a PlannedApp) Target() cli.Target {
+	return cli.Target{Org: a.Org, Space: a.Space}
+}
+
+// NewPlan renders the manifest of every app and checks the apps against what
+// already exists in Cloud Foundry, without changing anything.
+func NewPlan(logger lager.Logger, config config.Config, apps []CfApp, cfClient cli.CFClient) (Plan, error) {
+	logger = logger.Session("planning", lager.Data{"apps": len(apps)})
+	logger.Info("started")
+	defer logger.Info("completed")
+
+	plan := Plan{
+		Domain:             config.Domain(),
+		Seed:               config.Seed(),
+		NumBatches:         config.NumBatches(),
+		TotalApps:          len(apps),
+		MaxAllowedFailures: config.MaxAllowedFailures(),
+		FailurePolicy:      config.FailurePolicy(),
+		PushMode:           config.PushMode(),
+		AppTypes:           map[string]*PlannedResources{},
+		Apps:               []PlannedApp{},
+		Collisions:         []string{},
+		Problems:           []string{},
+	}
+
+	var err error
+	plan.Target, err = currentTarget(logger, cfClient, apps)
+	if err != nil {
+		logger.Error("failed-getting-target", err)
+		return Plan{}, err
+	}
+
+	names := make([]string, 0, len(apps))
+	generated := map[string]bool{}
+	for _, app := range apps {
+		name := app.AppName()
+		appType := app.AppDefinition().AppNamePrefix
+		if generated[name] {
+			plan.Problems = append(plan.Problems, fmt.Sprintf("app name %s is generated more than once", name))
+			continue
+		}
+		generated[name] = true
+		names = append(names, name)
+
+		resources, err := appResources(app)
+		if err != nil {
+			plan.Problems = append(plan.Problems, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+
+		plan.Apps = append(plan.Apps, newPlannedApp(app, plan.Target, resources))
+		if _, ok := plan.AppTypes[appType]; !ok {
+			plan.AppTypes[appType] = &PlannedResources{}
+		}
+		plan.AppTypes[appType].add(resources)
+		plan.Resources.add(resources)
+	}
+
+	collisions, err := cli.FindApps(logger, cfClient, names)
+	if err != nil {
+		logger.Error("failed-finding-existing-apps", err)
+		return Plan{}, err
+	}
+	sort.Strings(collisions)
+	plan.Collisions = collisions
+	if len(collisions) > 0 {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("%d apps already exist", len(collisions)))
+	}
+
+	quotas, unchecked, err := targetQuotas(logger, cfClient, plan.Apps)
+	if err != nil {
+		logger.Error("failed-getting-quotas", err)
+		return Plan{}, err
+	}
+	plan.Quotas = quotas
+	plan.Problems = append(plan.Problems, unchecked...)
+	plan.Problems = append(plan.Problems, quotaProblems(plan.Quotas, plan.Apps)...)
+
+	problem, err := dockerProblem(logger, cfClient, plan.Apps)
+	if err != nil {
+		logger.Error("failed-checking-docker-support", err)
+		return Plan{}, err
+	}
+	if problem != "" {
+		plan.Problems = append(plan.Problems, problem)
+	}
+
+	return plan, nil
+}
+
+func appResources(app CfApp) (config.Resources, error) {
+	manifest, err := app.Manifest()
+	if err != nil {
+		return config.Resources{}, err
+	}
+	return config.ManifestResources(manifest)
+}
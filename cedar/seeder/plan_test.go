@@ -0,0 +1,188 @@
+package seeder_test
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	. "code.cloudfoundry.org/diego-stress-tests/cedar/cli/fakes"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config/fakes"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/seeder"
+	. "code.cloudfoundry.org/diego-stress-tests/cedar/seeder/fakes"
+	"code.cloudfoundry.org/lager"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plan", func() {
+	var (
+		cfg       *fakes.FakeConfig
+		fakeCli   *FakeCFClient
+		responses map[string]string
+		apps      []seeder.CfApp
+		plan      seeder.Plan
+		err       error
+	)
+
+	newApp := func(name, appType, manifest string) *FakeCfApp {
+		app := &FakeCfApp{}
+		app.AppNameReturns(name)
+		app.AppURLReturns("http://" + name + ".bosh-lite.com")
+		app.AppDefinitionReturns(config.AppDefinition{AppNamePrefix: appType})
+		app.ManifestReturns([]byte(manifest), nil)
+		return app
+	}
+
+	BeforeEach(func() {
+		cfg = &fakes.FakeConfig{}
+		cfg.DomainReturns("bosh-lite.com")
+		cfg.SeedReturns(42)
+		cfg.NumBatchesReturns(1)
+		cfg.MaxAllowedFailuresReturns(1)
+		cfg.FailurePolicyReturns(config.AbortPolicy)
+
+		apps = []seeder.CfApp{
+			newApp("cedarapp-0-light-0", "light", "applications:\n- instances: 2\n  memory: 128M\n  disk_quota: 100M\n"),
+			newApp("cedarapp-0-light-1", "light", "applications:\n- instances: 2\n  memory: 128M\n  disk_quota: 100M\n"),
+			newApp("cedarapp-0-heavy-0", "heavy", "applications:\n- memory: 1G\n"),
+		}
+
+		query := "curl /v2/apps?results-per-page=100&q=" + url.QueryEscape("name IN cedarapp-0-light-0,cedarapp-0-light-1,cedarapp-0-heavy-0")
+		responses = map[string]string{
+			query:                                    `{"resources": []}`,
+			"target":                                 "org:            stress\nspace:          cedar\n",
+			"curl /v2/organizations?q=name%3Astress": `{"resources": [{"metadata": {"guid": "org-guid"}, "entity": {"quota_definition_guid": "quota-guid"}}]}`,
+			"curl /v2/quota_definitions/quota-guid":  `{"entity": {"name": "default", "memory_limit": 4096, "instance_memory_limit": -1, "app_instance_limit": -1}}`,
+			"curl /v2/organizations/org-guid/memory_usage":                  `{"memory_usage_in_mb": 1024}`,
+			"curl /v2/spaces?q=name%3Acedar&q=organization_guid%3Aorg-guid": `{"resources": [{"metadata": {"guid": "space-guid"}, "entity": {}}]}`,
+		}
+		fakeCli = &FakeCFClient{}
+		fakeCli.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+			response, ok := responses[strings.Join(args, " ")]
+			if !ok {
+				return nil, fmt.Errorf("unexpected command: %v", args)
+			}
+			return []byte(response), nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		plan, err = seeder.NewPlan(fakeLogger, cfg, apps, fakeCli)
+	})
+
+	It("lists the apps and their resources", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.TotalApps).To(Equal(3))
+		Expect(plan.Apps[0]).To(Equal(seeder.PlannedApp{
+			Name:      "cedarapp-0-light-0",
+			AppType:   "light",
+			URL:       "http://cedarapp-0-light-0.bosh-lite.com",
+			Org:       "stress",
+			Space:     "cedar",
+			Lifecycle: config.BuildpackLifecycle,
+			Resources: config.Resources{Instances: 2, MemoryMB: 128, DiskMB: 100},
+		}))
+		Expect(plan.AppTypes).To(Equal(map[string]*seeder.PlannedResources{
+			"light": {Apps: 2, Instances: 4, MemoryMB: 512, DiskMB: 400},
+			"heavy": {Apps: 1, Instances: 1, MemoryMB: 1024, DiskMB: config.DefaultDiskMB},
+		}))
+		Expect(plan.Resources).To(Equal(seeder.PlannedResources{Apps: 3, Instances: 5, MemoryMB: 1536, DiskMB: 1424}))
+	})
+
+	It("records the target and quota", func() {
+		Expect(plan.Target.Org).To(Equal("stress"))
+		Expect(plan.Quotas).To(HaveLen(1))
+		Expect(plan.Quotas[0].MemoryUsageMB).To(Equal(int64(1024)))
+		Expect(plan.Problems).To(BeEmpty())
+	})
+
+	It("does not change anything", func() {
+		for i := 0; i < fakeCli.CfCallCount(); i++ {
+			_, _, _, args := fakeCli.CfArgsForCall(i)
+			Expect([]string{"curl", "target"}).To(ContainElement(args[0]))
+		}
+	})
+
+	Context("when apps already exist", func() {
+		BeforeEach(func() {
+			query := "curl /v2/apps?results-per-page=100&q=" + url.QueryEscape("name IN cedarapp-0-light-0,cedarapp-0-light-1,cedarapp-0-heavy-0")
+			responses[query] = `{"resources": [{"entity": {"name": "cedarapp-0-light-1"}}]}`
+		})
+
+		It("reports the collisions", func() {
+			Expect(plan.Collisions).To(Equal([]string{"cedarapp-0-light-1"}))
+			Expect(plan.Problems).To(ConsistOf("1 apps already exist"))
+		})
+	})
+
+	Context("when the apps do not fit in the org's quota", func() {
+		BeforeEach(func() {
+			responses["curl /v2/quota_definitions/quota-guid"] = `{"entity": {"name": "small", "memory_limit": 2048, "instance_memory_limit": 512, "app_instance_limit": 4}}`
+		})
+
+		It("reports the problems", func() {
+			Expect(plan.Problems).To(ConsistOf(
+				"the apps need 1536MB of memory but only 1024MB of the 2048MB allowed by org quota small of stress are free",
+				"the apps need 5 instances but org quota small of stress allows 4",
+				"cedarapp-0-heavy-0 needs 1024MB of memory per instance but org quota small of stress allows 512MB",
+			))
+		})
+	})
+
+	Context("when the apps go to spaces that do not exist yet", func() {
+		BeforeEach(func() {
+			for _, app := range apps {
+				app.(*FakeCfApp).TargetReturns(cli.Target{Org: "stress", Space: "cedarapp-space-0"})
+			}
+			responses["curl /v2/spaces?q=name%3Acedarapp-space-0&q=organization_guid%3Aorg-guid"] = `{"resources": []}`
+			delete(responses, "target")
+		})
+
+		It("checks the quota of the org", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plan.Target).To(Equal(cli.Target{}))
+			Expect(plan.Apps[0].Space).To(Equal("cedarapp-space-0"))
+			Expect(plan.Quotas).To(HaveLen(1))
+			Expect(plan.Quotas[0].String()).To(Equal("org quota default of stress"))
+		})
+	})
+
+	Context("when docker apps are planned but docker is disabled", func() {
+		BeforeEach(func() {
+			apps[2].(*FakeCfApp).AppDefinitionReturns(config.AppDefinition{AppNamePrefix: "heavy", AppProfile: config.AppProfile{DockerImage: "stress-app"}})
+			responses["curl /v2/config/feature_flags/diego_docker"] = `{"name": "diego_docker", "enabled": false}`
+		})
+
+		It("reports the problem", func() {
+			Expect(plan.Apps[2].Lifecycle).To(Equal(config.DockerLifecycle))
+			Expect(plan.Problems).To(ConsistOf("1 docker apps need the diego_docker feature flag, which is disabled"))
+		})
+	})
+
+	Context("when a manifest cannot be rendered", func() {
+		BeforeEach(func() {
+			apps[2].(*FakeCfApp).ManifestReturns(nil, errors.New("memory: map has no entry for key \"memory\""))
+		})
+
+		It("reports the app", func() {
+			Expect(plan.Problems).To(ConsistOf(`cedarapp-0-heavy-0: memory: map has no entry for key "memory"`))
+			Expect(plan.Apps).To(HaveLen(2))
+		})
+	})
+
+	Context("when cf cannot be queried", func() {
+		BeforeEach(func() {
+			delete(responses, "target")
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("unexpected command")))
+		})
+	})
+})
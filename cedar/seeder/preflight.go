@@ -0,0 +1,293 @@
+package seeder
+
+import (
+	"fmt"
+	"sort"
+
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
+	"code.cloudfoundry.org/lager"
+)
+
+// PreflightReport is what the preflight check found before pushing, and the
+// apps it dropped to scale the run down.
+type PreflightReport struct {
+	Policy      string           `json:"policy"`
+	Target      cli.Target       `json:"target"`
+	Quotas      []cli.Quota      `json:"quotas"`
+	Needed      PlannedResources `json:"needed"`
+	Scheduled   PlannedResources `json:"scheduled"`
+	Problems    []string         `json:"problems"`
+	DroppedApps []string         `json:"dropped_apps,omitempty"`
+}
+
+// Refused reports whether the run must not go ahead, either because the
+// policy refuses runs that do not fit or because nothing fits at all.
+func (r *PreflightReport) Refused() bool {
+	if r.Policy == config.PreflightOff {
+		return false
+	}
+	return (r.Policy == config.PreflightRefuse && len(r.Problems) > 0) || r.Scheduled.Apps == 0
+}
+
+// Preflight checks that the apps fit in the quotas of the targeted org and
+// space before any of them is pushed, and returns the apps to push. With the
+// scale-down policy it drops apps, keeping the mix of app types, until the
+// rest fit.
+func Preflight(logger lager.Logger, cfg config.Config, apps []CfApp, cfClient cli.CFClient) ([]CfApp, *PreflightReport, error) {
+	report := &PreflightReport{Policy: cfg.Preflight(), Problems: []string{}}
+	if report.Policy == config.PreflightOff {
+		return apps, report, nil
+	}
+
+	logger = logger.Session("preflight", lager.Data{"policy": report.Policy})
+	logger.Info("started")
+	defer logger.Info("completed")
+
+	current, err := currentTarget(logger, cfClient, apps)
+	if err != nil {
+		logger.Error("failed-getting-target", err)
+		return nil, nil, err
+	}
+	report.Target = current
+
+	sized := []CfApp{}
+	planned := []PlannedApp{}
+	for _, app := range apps {
+		resources, err := appResources(app)
+		if err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: %s", app.AppName(), err))
+			report.DroppedApps = append(report.DroppedApps, app.AppName())
+			continue
+		}
+		sized = append(sized, app)
+		planned = append(planned, newPlannedApp(app, current, resources))
+		report.Needed.add(resources)
+	}
+
+	quotas, unchecked, err := targetQuotas(logger, cfClient, planned)
+	if err != nil {
+		logger.Error("failed-getting-quotas", err)
+		return nil, nil, err
+	}
+	report.Quotas = quotas
+	report.Problems = append(report.Problems, unchecked...)
+	report.Problems = append(report.Problems, quotaProblems(report.Quotas, planned)...)
+
+	problem, err := dockerProblem(logger, cfClient, planned)
+	if err != nil {
+		logger.Error("failed-checking-docker-support", err)
+		return nil, nil, err
+	}
+	if problem != "" {
+		report.Problems = append(report.Problems, problem)
+	}
+	logger.Info("checked-quotas", lager.Data{"needed": report.Needed, "quotas": report.Quotas, "problems": report.Problems})
+
+	if report.Policy == config.PreflightRefuse {
+		report.Scheduled = report.Needed
+		if len(report.Problems) > 0 {
+			logger.Error("refused", nil, lager.Data{"problems": report.Problems})
+			return nil, report, nil
+		}
+		return apps, report, nil
+	}
+
+	// Apps that cannot run at all are dropped before scaling down the rest.
+	kept := []CfApp{}
+	keptPlanned := []PlannedApp{}
+	for i, app := range planned {
+		if fitsInstanceMemory(report.Quotas, app) && (problem == "" || app.Lifecycle != config.DockerLifecycle) {
+			kept = append(kept, sized[i])
+			keptPlanned = append(keptPlanned, app)
+		} else {
+			report.DroppedApps = append(report.DroppedApps, app.Name)
+		}
+	}
+
+	keep := scaleToFit(report.Quotas, keptPlanned)
+	scheduled := []CfApp{}
+	for i, app := range kept {
+		if keep[i] {
+			scheduled = append(scheduled, app)
+			report.Scheduled.add(keptPlanned[i].Resources)
+		} else {
+			report.DroppedApps = append(report.DroppedApps, app.AppName())
+		}
+	}
+	sort.Strings(report.DroppedApps)
+
+	if len(report.DroppedApps) > 0 {
+		logger.Info("scaled-down", lager.Data{"dropped": len(report.DroppedApps), "scheduled": report.Scheduled})
+	}
+	if report.Scheduled.Apps == 0 {
+		logger.Error("refused", nil, lager.Data{"problems": report.Problems})
+		return nil, report, nil
+	}
+	return scheduled, report, nil
+}
+
+// scaleToFit picks the apps that fit in the quotas. It keeps the same share
+// of every app type, taking each type's apps in push order, and searches for
+// the largest share that fits.
+func scaleToFit(quotas []cli.Quota, apps []PlannedApp) []bool {
+	typeCounts := map[string]int{}
+	for _, app := range apps {
+		typeCounts[app.AppType]++
+	}
+
+	pick := func(share int) ([]bool, []PlannedApp) {
+		keep := make([]bool, len(apps))
+		kept := []PlannedApp{}
+		picked := map[string]int{}
+		for i, app := range apps {
+			if picked[app.AppType] < typeCounts[app.AppType]*share/len(apps) {
+				picked[app.AppType]++
+				keep[i] = true
+				kept = append(kept, app)
+			}
+		}
+		return keep, kept
+	}
+
+	low, high := 0, len(apps)
+	for low < high {
+		share := (low + high + 1) / 2
+		if _, kept := pick(share); len(quotaProblems(quotas, kept)) == 0 {
+			low = share
+		} else {
+			high = share - 1
+		}
+	}
+	keep, _ := pick(low)
+	return keep
+}
+
+func fitsInstanceMemory(quotas []cli.Quota, app PlannedApp) bool {
+	for _, quota := range quotas {
+		if quota.Applies(app.Target()) && quota.InstanceMemoryLimitMB >= 0 && app.MemoryMB > quota.InstanceMemoryLimitMB {
+			return false
+		}
+	}
+	return true
+}
+
+// dockerProblem checks that docker apps can run, as Cloud Foundry refuses to
+// push them unless the diego_docker feature flag is enabled.
+func dockerProblem(logger lager.Logger, cfClient cli.CFClient, apps []PlannedApp) (string, error) {
+	dockerApps := 0
+	for _, app := range apps {
+		if app.Lifecycle == config.DockerLifecycle {
+			dockerApps++
+		}
+	}
+	if dockerApps == 0 {
+		return "", nil
+	}
+
+	enabled, err := cli.FeatureFlagEnabled(logger, cfClient, "diego_docker")
+	if err != nil || enabled {
+		return "", err
+	}
+	return fmt.Sprintf("%d docker apps need the diego_docker feature flag, which is disabled", dockerApps), nil
+}
+
+// currentTarget returns the org and space cf targets when some of the apps
+// are pushed there, and the zero Target otherwise.
+func currentTarget(logger lager.Logger, cfClient cli.CFClient, apps []CfApp) (cli.Target, error) {
+	for _, app := range apps {
+		if app.Target() == (cli.Target{}) {
+			return cli.GetTarget(logger, cfClient)
+		}
+	}
+	return cli.Target{}, nil
+}
+
+// targetQuotas returns the quotas of every org and space the apps are pushed
+// to. Orgs and spaces that do not exist yet are created by cedar after the
+// check with the default quotas, so new orgs are checked against the default
+// org quota and new spaces against their org's quota only. It also returns a
+// problem for every org whose quota could not be checked.
+func targetQuotas(logger lager.Logger, cfClient cli.CFClient, apps []PlannedApp) ([]cli.Quota, []string, error) {
+	quotas := []cli.Quota{}
+	unchecked := []string{}
+	uncheckedOrgs := map[string]bool{}
+	checked := map[cli.Target]bool{}
+	seen := map[cli.Quota]bool{}
+	for _, app := range apps {
+		target := app.Target()
+		if checked[target] {
+			continue
+		}
+		checked[target] = true
+
+		found, err := cli.GetQuotas(logger, cfClient, target)
+		if err == cli.ErrSpaceNotFound {
+			found, err = cli.GetQuotas(logger, cfClient, cli.Target{Org: target.Org})
+		}
+		if err == cli.ErrOrgNotFound {
+			var quota cli.Quota
+			quota, err = cli.GetDefaultOrgQuota(logger, cfClient, target.Org)
+			found = []cli.Quota{quota}
+		}
+		if err == cli.ErrQuotaNotFound {
+			if !uncheckedOrgs[target.Org] {
+				uncheckedOrgs[target.Org] = true
+				unchecked = append(unchecked, fmt.Sprintf("org %s does not exist yet and there is no default quota to check it against", target.Org))
+			}
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, quota := range found {
+			if !seen[quota] {
+				seen[quota] = true
+				quotas = append(quotas, quota)
+			}
+		}
+	}
+	return quotas, unchecked, nil
+}
+
+// quotaProblems checks each quota against the apps it applies to. Quotas do
+// not limit disk, so disk is only estimated. The app instance limits are
+// checked against the new instances only, as the CC API does not report the
+// instances in use.
+func quotaProblems(quotas []cli.Quota, apps []PlannedApp) []string {
+	problems := []string{}
+	for _, quota := range quotas {
+		var total PlannedResources
+		limited := []PlannedApp{}
+		for _, app := range apps {
+			if quota.Applies(app.Target()) {
+				total.add(app.Resources)
+				limited = append(limited, app)
+			}
+		}
+
+		if quota.MemoryLimitMB >= 0 {
+			available := quota.MemoryLimitMB - quota.MemoryUsageMB
+			if total.MemoryMB > available {
+				problems = append(problems, fmt.Sprintf("the apps need %dMB of memory but only %dMB of the %dMB allowed by %s are free",
+					total.MemoryMB, available, quota.MemoryLimitMB, quota))
+			}
+		}
+
+		if quota.AppInstanceLimit >= 0 && total.Instances > quota.AppInstanceLimit {
+			problems = append(problems, fmt.Sprintf("the apps need %d instances but %s allows %d",
+				total.Instances, quota, quota.AppInstanceLimit))
+		}
+
+		if quota.InstanceMemoryLimitMB >= 0 {
+			for _, app := range limited {
+				if app.MemoryMB > quota.InstanceMemoryLimitMB {
+					problems = append(problems, fmt.Sprintf("%s needs %dMB of memory per instance but %s allows %dMB",
+						app.Name, app.MemoryMB, quota, quota.InstanceMemoryLimitMB))
+				}
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,262 @@
+package seeder_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	. "code.cloudfoundry.org/diego-stress-tests/cedar/cli/fakes"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config/fakes"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/seeder"
+	. "code.cloudfoundry.org/diego-stress-tests/cedar/seeder/fakes"
+	"code.cloudfoundry.org/lager"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Preflight", func() {
+	var (
+		cfg       *fakes.FakeConfig
+		fakeCli   *FakeCFClient
+		responses map[string]string
+		apps      []seeder.CfApp
+		scheduled []seeder.CfApp
+		report    *seeder.PreflightReport
+		err       error
+	)
+
+	newApp := func(name, appType, memory string) *FakeCfApp {
+		app := &FakeCfApp{}
+		app.AppNameReturns(name)
+		app.AppDefinitionReturns(config.AppDefinition{AppNamePrefix: appType})
+		app.ManifestReturns([]byte("applications:\n- memory: "+memory+"\n"), nil)
+		return app
+	}
+
+	BeforeEach(func() {
+		cfg = &fakes.FakeConfig{}
+		cfg.PreflightReturns(config.PreflightRefuse)
+
+		apps = []seeder.CfApp{}
+		for i := 0; i < 6; i++ {
+			apps = append(apps, newApp(fmt.Sprintf("light-%d", i), "light", "128M"))
+		}
+		for i := 0; i < 2; i++ {
+			apps = append(apps, newApp(fmt.Sprintf("heavy-%d", i), "heavy", "512M"))
+		}
+
+		responses = map[string]string{
+			"target":                                                        "org:            stress\nspace:          cedar\n",
+			"curl /v2/organizations?q=name%3Astress":                        `{"resources": [{"metadata": {"guid": "org-guid"}, "entity": {"quota_definition_guid": "quota-guid"}}]}`,
+			"curl /v2/quota_definitions/quota-guid":                         `{"entity": {"name": "default", "memory_limit": -1, "instance_memory_limit": -1, "app_instance_limit": -1}}`,
+			"curl /v2/organizations/org-guid/memory_usage":                  `{"memory_usage_in_mb": 0}`,
+			"curl /v2/spaces?q=name%3Acedar&q=organization_guid%3Aorg-guid": `{"resources": [{"metadata": {"guid": "space-guid"}, "entity": {"space_quota_definition_guid": "space-quota-guid"}}]}`,
+			"curl /v2/space_quota_definitions/space-quota-guid":             `{"entity": {"name": "small", "memory_limit": 2048, "instance_memory_limit": -1, "app_instance_limit": -1}}`,
+			"curl /v2/spaces/space-guid/summary":                            `{"apps": [{"memory": 256, "instances": 1, "state": "STARTED"}]}`,
+		}
+		fakeCli = &FakeCFClient{}
+		fakeCli.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+			response, ok := responses[strings.Join(args, " ")]
+			if !ok {
+				return nil, fmt.Errorf("unexpected command: %v", args)
+			}
+			return []byte(response), nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		scheduled, report, err = seeder.Preflight(fakeLogger, cfg, apps, fakeCli)
+	})
+
+	Context("when the apps fit", func() {
+		It("schedules all of them", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scheduled).To(Equal(apps))
+			Expect(report.Refused()).To(BeFalse())
+			Expect(report.Needed).To(Equal(seeder.PlannedResources{Apps: 8, Instances: 8, MemoryMB: 1792, DiskMB: 8 * config.DefaultDiskMB}))
+		})
+	})
+
+	Context("when the apps do not fit", func() {
+		BeforeEach(func() {
+			responses["curl /v2/spaces/space-guid/summary"] = `{"apps": [{"memory": 1024, "instances": 1, "state": "STARTED"}]}`
+		})
+
+		It("refuses the run", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scheduled).To(BeEmpty())
+			Expect(report.Refused()).To(BeTrue())
+			Expect(report.Problems).To(ConsistOf(
+				"the apps need 1792MB of memory but only 1024MB of the 2048MB allowed by space quota small of stress/cedar are free",
+			))
+		})
+
+		Context("when scaling down", func() {
+			BeforeEach(func() {
+				cfg.PreflightReturns(config.PreflightScaleDown)
+			})
+
+			It("keeps the mix of app types that fits", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Refused()).To(BeFalse())
+				Expect(report.Scheduled).To(Equal(seeder.PlannedResources{Apps: 5, Instances: 5, MemoryMB: 1024, DiskMB: 5 * config.DefaultDiskMB}))
+				Expect(report.DroppedApps).To(Equal([]string{"heavy-1", "light-4", "light-5"}))
+
+				names := []string{}
+				for _, app := range scheduled {
+					names = append(names, app.AppName())
+				}
+				Expect(names).To(Equal([]string{"light-0", "light-1", "light-2", "light-3", "heavy-0"}))
+			})
+		})
+	})
+
+	Context("when an app is larger than the instance memory limit", func() {
+		BeforeEach(func() {
+			cfg.PreflightReturns(config.PreflightScaleDown)
+			responses["curl /v2/space_quota_definitions/space-quota-guid"] = `{"entity": {"name": "small", "memory_limit": -1, "instance_memory_limit": 256, "app_instance_limit": -1}}`
+		})
+
+		It("drops the app when scaling down", func() {
+			Expect(report.DroppedApps).To(Equal([]string{"heavy-0", "heavy-1"}))
+			Expect(scheduled).To(HaveLen(6))
+		})
+	})
+
+	Context("when docker apps cannot run", func() {
+		BeforeEach(func() {
+			cfg.PreflightReturns(config.PreflightScaleDown)
+			for _, app := range apps[6:] {
+				app.(*FakeCfApp).AppDefinitionReturns(config.AppDefinition{AppNamePrefix: "heavy", AppProfile: config.AppProfile{DockerImage: "stress-app"}})
+			}
+			responses["curl /v2/config/feature_flags/diego_docker"] = `{"name": "diego_docker", "enabled": false}`
+		})
+
+		It("drops them when scaling down", func() {
+			Expect(report.Problems).To(ConsistOf("2 docker apps need the diego_docker feature flag, which is disabled"))
+			Expect(report.DroppedApps).To(Equal([]string{"heavy-0", "heavy-1"}))
+			Expect(scheduled).To(HaveLen(6))
+		})
+	})
+
+	Context("when nothing fits", func() {
+		BeforeEach(func() {
+			cfg.PreflightReturns(config.PreflightScaleDown)
+			responses["curl /v2/spaces/space-guid/summary"] = `{"apps": [{"memory": 2048, "instances": 1, "state": "STARTED"}]}`
+		})
+
+		It("refuses the run", func() {
+			Expect(scheduled).To(BeEmpty())
+			Expect(report.Refused()).To(BeTrue())
+		})
+	})
+
+	Context("when the apps are distributed across spaces", func() {
+		BeforeEach(func() {
+			for i, app := range apps {
+				space := fmt.Sprintf("cedar-space-%d", i%2)
+				app.(*FakeCfApp).TargetReturns(cli.Target{Org: "stress", Space: space})
+				responses["curl /v2/spaces?q=name%3A"+space+"&q=organization_guid%3Aorg-guid"] = `{"resources": [{"metadata": {"guid": "` + space + `"}, "entity": {"space_quota_definition_guid": "space-quota-guid"}}]}`
+				responses["curl /v2/spaces/"+space+"/summary"] = `{"apps": [{"memory": 1024, "instances": 1, "state": "STARTED"}]}`
+			}
+			delete(responses, "target")
+		})
+
+		It("checks each space quota against the apps in that space", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Refused()).To(BeFalse())
+			Expect(report.Quotas).To(HaveLen(3))
+			Expect(report.Quotas[0].String()).To(Equal("org quota default of stress"))
+			Expect(scheduled).To(Equal(apps))
+		})
+
+		Context("when the spaces do not exist yet", func() {
+			BeforeEach(func() {
+				responses["curl /v2/spaces?q=name%3Acedar-space-0&q=organization_guid%3Aorg-guid"] = `{"resources": []}`
+				responses["curl /v2/spaces?q=name%3Acedar-space-1&q=organization_guid%3Aorg-guid"] = `{"resources": []}`
+			})
+
+			It("checks the org quota only", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Quotas).To(HaveLen(1))
+				Expect(report.Quotas[0].String()).To(Equal("org quota default of stress"))
+			})
+		})
+
+		Context("when the orgs do not exist yet", func() {
+			BeforeEach(func() {
+				for i, app := range apps {
+					app.(*FakeCfApp).TargetReturns(cli.Target{Org: fmt.Sprintf("new-org-%d", i%2), Space: "cedar"})
+				}
+				responses["curl /v2/organizations?q=name%3Anew-org-0"] = `{"resources": []}`
+				responses["curl /v2/organizations?q=name%3Anew-org-1"] = `{"resources": []}`
+				responses["curl /v2/quota_definitions?q=name%3Adefault"] = `{"resources": [{"entity": {"name": "default", "memory_limit": 1024, "instance_memory_limit": -1, "app_instance_limit": -1}}]}`
+			})
+
+			It("checks them against the default org quota", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Quotas).To(HaveLen(2))
+				Expect(report.Quotas[0].String()).To(Equal("org quota default of new-org-0"))
+				Expect(report.Quotas[1].String()).To(Equal("org quota default of new-org-1"))
+				Expect(report.Problems).To(BeEmpty())
+			})
+
+			Context("when the default org quota is too small", func() {
+				BeforeEach(func() {
+					responses["curl /v2/quota_definitions?q=name%3Adefault"] = `{"resources": [{"entity": {"name": "default", "memory_limit": 512, "instance_memory_limit": -1, "app_instance_limit": -1}}]}`
+				})
+
+				It("refuses the run", func() {
+					Expect(report.Refused()).To(BeTrue())
+					Expect(report.Problems).To(ConsistOf(
+						"the apps need 896MB of memory but only 512MB of the 512MB allowed by org quota default of new-org-0 are free",
+						"the apps need 896MB of memory but only 512MB of the 512MB allowed by org quota default of new-org-1 are free",
+					))
+				})
+			})
+
+			Context("when there is no default org quota", func() {
+				BeforeEach(func() {
+					responses["curl /v2/quota_definitions?q=name%3Adefault"] = `{"resources": []}`
+				})
+
+				It("reports that the orgs could not be checked", func() {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(report.Quotas).To(BeEmpty())
+					Expect(report.Problems).To(ConsistOf(
+						"org new-org-0 does not exist yet and there is no default quota to check it against",
+						"org new-org-1 does not exist yet and there is no default quota to check it against",
+					))
+				})
+			})
+		})
+
+		Context("when the org quota is too small for all of them", func() {
+			BeforeEach(func() {
+				responses["curl /v2/quota_definitions/quota-guid"] = `{"entity": {"name": "default", "memory_limit": 1024, "instance_memory_limit": -1, "app_instance_limit": -1}}`
+			})
+
+			It("refuses the run", func() {
+				Expect(report.Problems).To(ConsistOf(
+					"the apps need 1792MB of memory but only 1024MB of the 1024MB allowed by org quota default of stress are free",
+				))
+			})
+		})
+	})
+
+	Context("when the preflight is off", func() {
+		BeforeEach(func() {
+			cfg.PreflightReturns(config.PreflightOff)
+		})
+
+		It("does not query cf", func() {
+			Expect(scheduled).To(Equal(apps))
+			Expect(report.Refused()).To(BeFalse())
+			Expect(fakeCli.CfCallCount()).To(BeZero())
+		})
+	})
+})
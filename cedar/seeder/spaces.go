@@ -0,0 +1,139 @@
+package seeder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config"
+	"code.cloudfoundry.org/lager"
+)
+
+// SpaceSetup is a space the batches were distributed to, and whether cedar
+// created it or its org, so that teardown only deletes what cedar created.
+type SpaceSetup struct {
+	Org          string `json:"org"`
+	Space        string `json:"space"`
+	CreatedOrg   bool   `json:"created_org"`
+	CreatedSpace bool   `json:"created_space"`
+}
+
+// SetupSpaces creates the orgs and spaces the apps are pushed to unless they
+// already exist.
+func SetupSpaces(logger lager.Logger, cfClient cli.CFClient, targets []cli.Target) ([]SpaceSetup, error) {
+	logger = logger.Session("setting-up-spaces", lager.Data{"spaces": len(targets)})
+	logger.Info("started")
+	defer logger.Info("completed")
+
+	spaces := []SpaceSetup{}
+	for _, target := range targets {
+		createdOrg, createdSpace, err := cli.CreateSpace(logger, cfClient, target)
+		spaces = append(spaces, SpaceSetup{
+			Org:          target.Org,
+			Space:        target.Space,
+			CreatedOrg:   createdOrg,
+			CreatedSpace: createdSpace,
+		})
+		if err != nil {
+			logger.Error("failed-creating-space", err, lager.Data{"org": target.Org, "space": target.Space})
+			return spaces, err
+		}
+	}
+	return spaces, nil
+}
+
+// LoadReport reads the report a previous run wrote to its output file.
+func LoadReport(path string) (CedarReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return CedarReport{}, err
+	}
+	defer file.Close()
+
+	var report CedarReport
+	if err := json.NewDecoder(file).Decode(&report); err != nil {
+		return CedarReport{}, err
+	}
+	return report, nil
+}
+
+// Teardown deletes the apps of a previous run from the spaces they were
+// pushed to, then the spaces and orgs that run created. It keeps going after
+// a failure and returns an error if anything could not be deleted.
+func Teardown(logger lager.Logger, ctx context.Context, cfg config.Config, report CedarReport, cfClient cli.CFClient) error {
+	logger = logger.Session("teardown", lager.Data{"apps": len(report.Apps), "spaces": len(report.Spaces)})
+	logger.Info("started")
+	defer logger.Info("completed")
+
+	failures := 0
+	failuresMutex := &sync.Mutex{}
+	failed := func() {
+		failuresMutex.Lock()
+		failures++
+		failuresMutex.Unlock()
+	}
+
+	wg := sync.WaitGroup{}
+	rateLimiter := make(chan struct{}, cfg.MaxInFlight())
+	for _, app := range report.Apps {
+		if app.AppName == nil {
+			continue
+		}
+		name := *app.AppName
+		client := cfClient
+		if target := (cli.Target{Org: app.Org, Space: app.Space}); target != (cli.Target{}) {
+			client = cfClient.WithTarget(target)
+		}
+
+		wg.Add(1)
+		go func() {
+			rateLimiter <- struct{}{}
+			defer func() {
+				<-rateLimiter
+				wg.Done()
+			}()
+
+			_, err := client.Cf(logger, ctx, cfg.Timeout(), "delete", name, "-f", "-r")
+			if err != nil {
+				logger.Error("failed-deleting-app", err, lager.Data{"app-name": name})
+				failed()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Deleting an org deletes its spaces, so only the spaces cedar created
+	// in orgs it did not create are deleted on their own.
+	createdOrgs := map[string]bool{}
+	orgs := []string{}
+	for _, space := range report.Spaces {
+		if space.CreatedOrg && !createdOrgs[space.Org] {
+			createdOrgs[space.Org] = true
+			orgs = append(orgs, space.Org)
+		}
+	}
+	for _, space := range report.Spaces {
+		if !space.CreatedSpace || createdOrgs[space.Org] {
+			continue
+		}
+		if err := cli.DeleteSpace(logger, cfClient, cli.Target{Org: space.Org, Space: space.Space}); err != nil {
+			logger.Error("failed-deleting-space", err, lager.Data{"org": space.Org, "space": space.Space})
+			failed()
+		}
+	}
+	for _, org := range orgs {
+		if err := cli.DeleteOrg(logger, cfClient, org); err != nil {
+			logger.Error("failed-deleting-org", err, lager.Data{"org": org})
+			failed()
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("teardown failed to delete %d apps, spaces or orgs", failures)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package seeder_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/diego-stress-tests/cedar/cli"
+	. "code.cloudfoundry.org/diego-stress-tests/cedar/cli/fakes"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/config/fakes"
+	"code.cloudfoundry.org/diego-stress-tests/cedar/seeder"
+	"code.cloudfoundry.org/lager"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Spaces", func() {
+	var (
+		fakeCli   *FakeCFClient
+		commands  []string
+		responses map[string]string
+		mutex     *sync.Mutex
+	)
+
+	BeforeEach(func() {
+		mutex = &sync.Mutex{}
+		commands = []string{}
+		responses = map[string]string{}
+		fakeCli = &FakeCFClient{}
+		fakeCli.CfStub = func(logger lager.Logger, ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+			command := strings.Join(args, " ")
+			mutex.Lock()
+			defer mutex.Unlock()
+			commands = append(commands, command)
+			response, ok := responses[command]
+			if !ok {
+				return nil, errors.New("unexpected command: " + command)
+			}
+			return []byte(response), nil
+		}
+	})
+
+	Describe("SetupSpaces", func() {
+		It("creates the spaces and records what it created", func() {
+			responses["curl /v2/organizations?q=name%3Astress"] = `{"resources": [{"metadata": {"guid": "org-guid"}}]}`
+			responses["curl /v2/spaces?q=name%3Aexisting&q=organization_guid%3Aorg-guid"] = `{"resources": [{"metadata": {"guid": "space-guid"}}]}`
+			responses["curl /v2/spaces?q=name%3Anew&q=organization_guid%3Aorg-guid"] = `{"resources": []}`
+			responses["create-space new -o stress"] = "OK"
+
+			spaces, err := seeder.SetupSpaces(fakeLogger, fakeCli, []cli.Target{
+				{Org: "stress", Space: "existing"},
+				{Org: "stress", Space: "new"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spaces).To(Equal([]seeder.SpaceSetup{
+				{Org: "stress", Space: "existing"},
+				{Org: "stress", Space: "new", CreatedSpace: true},
+			}))
+		})
+
+		It("returns the spaces set up before a failure", func() {
+			responses["curl /v2/organizations?q=name%3Astress"] = `{"resources": [{"metadata": {"guid": "org-guid"}}]}`
+			responses["curl /v2/spaces?q=name%3Anew&q=organization_guid%3Aorg-guid"] = `{"resources": []}`
+			responses["create-space new -o stress"] = "OK"
+
+			spaces, err := seeder.SetupSpaces(fakeLogger, fakeCli, []cli.Target{
+				{Org: "stress", Space: "new"},
+				{Org: "other", Space: "new"},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(spaces).To(HaveLen(2))
+			Expect(spaces[0].CreatedSpace).To(BeTrue())
+		})
+	})
+
+	Describe("Teardown", func() {
+		var (
+			cfg         *fakes.FakeConfig
+			targetedCli *FakeCFClient
+			report      seeder.CedarReport
+		)
+
+		appState := func(name, org, space string) seeder.AppStateMetrics {
+			return seeder.AppStateMetrics{AppName: &name, Org: org, Space: space}
+		}
+
+		BeforeEach(func() {
+			cfg = &fakes.FakeConfig{}
+			cfg.MaxInFlightReturns(2)
+			cfg.TimeoutReturns(time.Minute)
+
+			targetedCli = &FakeCFClient{}
+			fakeCli.WithTargetReturns(targetedCli)
+
+			responses["delete app-0 -f -r"] = "OK"
+			responses["delete-space created-space -o stress -f"] = "OK"
+			responses["delete-org cedarapp-org-0 -f"] = "OK"
+
+			report = seeder.CedarReport{
+				Apps: []seeder.AppStateMetrics{
+					appState("app-0", "", ""),
+					appState("app-1", "cedarapp-org-0", "cedarapp-space-0"),
+				},
+				Spaces: []seeder.SpaceSetup{
+					{Org: "stress", Space: "existing"},
+					{Org: "stress", Space: "created-space", CreatedSpace: true},
+					{Org: "cedarapp-org-0", Space: "cedarapp-space-0", CreatedOrg: true, CreatedSpace: true},
+					{Org: "cedarapp-org-0", Space: "cedarapp-space-1", CreatedSpace: true},
+				},
+			}
+		})
+
+		It("deletes the apps in their spaces and what the run created", func() {
+			err := seeder.Teardown(fakeLogger, context.Background(), cfg, report, fakeCli)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeCli.WithTargetArgsForCall(0)).To(Equal(cli.Target{Org: "cedarapp-org-0", Space: "cedarapp-space-0"}))
+			_, _, _, args := targetedCli.CfArgsForCall(0)
+			Expect(args).To(Equal([]string{"delete", "app-1", "-f", "-r"}))
+
+			Expect(commands).To(ConsistOf(
+				"delete app-0 -f -r",
+				"delete-space created-space -o stress -f",
+				"delete-org cedarapp-org-0 -f",
+			))
+		})
+
+		It("keeps going and returns an error when something cannot be deleted", func() {
+			delete(responses, "delete app-0 -f -r")
+
+			err := seeder.Teardown(fakeLogger, context.Background(), cfg, report, fakeCli)
+			Expect(err).To(MatchError(ContainSubstring("failed to delete 1")))
+			Expect(commands).To(ContainElement("delete-org cedarapp-org-0 -f"))
+		})
+	})
+
+	Describe("LoadReport", func() {
+		It("reads the report written by a run", func() {
+			dir, err := ioutil.TempDir("", "cedar-report")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			name := "app-0"
+			written := seeder.CedarReport{
+				Succeeded: true,
+				Apps:      []seeder.AppStateMetrics{{AppName: &name, Org: "cedarapp-org-0", Space: "cedarapp-space-0"}},
+				Spaces:    []seeder.SpaceSetup{{Org: "cedarapp-org-0", Space: "cedarapp-space-0", CreatedOrg: true}},
+			}
+			contents, err := json.Marshal(written)
+			Expect(err).NotTo(HaveOccurred())
+			path := filepath.Join(dir, "output.json")
+			Expect(ioutil.WriteFile(path, contents, 0644)).To(Succeed())
+
+			report, err := seeder.LoadReport(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report).To(Equal(written))
+		})
+	})
+})
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/chug"
+)
+
+// lagerFormat covers both the legacy lager JSON output (epoch-seconds
+// `timestamp` and numeric `log_level`) and the newer output (RFC3339Nano
+// `timestamp` and string `level`).
+type lagerFormat struct {
+	Timestamp json.RawMessage `json:"timestamp"`
+	Level     string          `json:"level"`
+	LogLevel  *lager.LogLevel `json:"log_level"`
+	Source    string          `json:"source"`
+	Message   string          `json:"message"`
+	Data      lager.Data      `json:"data"`
+}
+
+var logLevels = map[string]lager.LogLevel{
+	"debug": lager.DEBUG,
+	"info":  lager.INFO,
+	"error": lager.ERROR,
+	"fatal": lager.FATAL,
+}
+
+// chugLogs behaves like chug.Chug, but accepts lines in either lager format so
+// mixed streams of old and new Diego component logs can be mapped together.
+func chugLogs(reader io.Reader, out chan<- chug.Entry) {
+	scanner := bufio.NewReader(reader)
+	for {
+		line, err := scanner.ReadBytes('\n')
+		if line != nil {
+			out <- parseEntry(bytes.TrimSuffix(line, []byte{'\n'}))
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(out)
+}
+
+func parseEntry(raw []byte) chug.Entry {
+	copiedBytes := make([]byte, len(raw))
+	copy(copiedBytes, raw)
+	entry := chug.Entry{
+		IsLager: false,
+		Raw:     copiedBytes,
+	}
+
+	idx := bytes.IndexByte(raw, '{')
+	if idx == -1 {
+		return entry
+	}
+
+	var lagerLog lagerFormat
+	decoder := json.NewDecoder(bytes.NewReader(raw[idx:]))
+	if err := decoder.Decode(&lagerLog); err != nil {
+		return entry
+	}
+
+	logEntry, err := convertLagerLog(lagerLog)
+	if err != nil {
+		return entry
+	}
+
+	entry.Log = logEntry
+	entry.IsLager = true
+	return entry
+}
+
+func convertLagerLog(lagerLog lagerFormat) (chug.LogEntry, error) {
+	timestamp, err := parseTimestamp(lagerLog.Timestamp)
+	if err != nil {
+		return chug.LogEntry{}, err
+	}
+
+	logLevel, err := parseLogLevel(lagerLog)
+	if err != nil {
+		return chug.LogEntry{}, err
+	}
+
+	data := lagerLog.Data
+	if data == nil {
+		data = lager.Data{}
+	}
+
+	var logErr error
+	if logLevel == lager.ERROR || logLevel == lager.FATAL {
+		errString, err := popString(data, "error")
+		if err != nil {
+			return chug.LogEntry{}, err
+		}
+		if errString != "" {
+			logErr = errors.New(errString)
+		}
+	}
+
+	trace, err := popString(data, "trace")
+	if err != nil {
+		return chug.LogEntry{}, err
+	}
+
+	session, err := popString(data, "session")
+	if err != nil {
+		return chug.LogEntry{}, err
+	}
+
+	return chug.LogEntry{
+		Timestamp: timestamp,
+		LogLevel:  logLevel,
+
+		Source:  lagerLog.Source,
+		Message: lagerLog.Message,
+		Session: session,
+
+		Error: logErr,
+		Trace: trace,
+
+		Data: data,
+	}, nil
+}
+
+// parseTimestamp accepts epoch seconds, either quoted (legacy lager) or as a
+// bare JSON number, and RFC3339Nano strings.
+func parseTimestamp(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 {
+		return time.Time{}, errors.New("missing timestamp")
+	}
+
+	value := string(raw)
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if t, err := parseEpochSeconds(value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// parseEpochSeconds parses decimal seconds such as "1476391200.012500000"
+// without going through a float, which would lose nanosecond precision.
+func parseEpochSeconds(value string) (time.Time, error) {
+	secondsPart, fractionPart := value, ""
+	if idx := strings.IndexByte(value, '.'); idx != -1 {
+		secondsPart, fractionPart = value[:idx], value[idx+1:]
+	}
+
+	seconds, err := strconv.ParseInt(secondsPart, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if len(fractionPart) > 9 {
+		fractionPart = fractionPart[:9]
+	}
+	var nanos uint64
+	if fractionPart != "" {
+		nanos, err = strconv.ParseUint(fractionPart+strings.Repeat("0", 9-len(fractionPart)), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return time.Unix(seconds, int64(nanos)), nil
+}
+
+func parseLogLevel(lagerLog lagerFormat) (lager.LogLevel, error) {
+	if lagerLog.Level != "" {
+		logLevel, ok := logLevels[strings.ToLower(lagerLog.Level)]
+		if !ok {
+			return 0, fmt.Errorf("unknown log level: %s", lagerLog.Level)
+		}
+		return logLevel, nil
+	}
+
+	if lagerLog.LogLevel != nil {
+		return *lagerLog.LogLevel, nil
+	}
+
+	return 0, errors.New("missing log level")
+}
+
+func popString(data lager.Data, key string) (string, error) {
+	value, ok := data[key]
+	if !ok {
+		return "", nil
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("unable to convert %s: %v", key, value)
+	}
+	delete(data, key)
+	return str, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/chug"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("chugLogs", func() {
+	var entries []chug.Entry
+
+	chugString := func(logs string) {
+		out := make(chan chug.Entry, 10)
+		go chugLogs(strings.NewReader(logs), out)
+
+		entries = []chug.Entry{}
+		for entry := range out {
+			entries = append(entries, entry)
+		}
+	}
+
+	Context("with the legacy lager format", func() {
+		BeforeEach(func() {
+			chugString(`{"timestamp":"1476391200.012500000","source":"bbs","message":"bbs.request.done","log_level":2,"data":{"session":"418","error":"boom","trace":"stack","request":"/v1/ping"}}`)
+		})
+
+		It("parses epoch-second timestamps and numeric levels", func() {
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].IsLager).To(BeTrue())
+
+			log := entries[0].Log
+			Expect(log.Timestamp).To(Equal(time.Unix(1476391200, 12500000)))
+			Expect(log.LogLevel).To(Equal(lager.ERROR))
+			Expect(log.Source).To(Equal("bbs"))
+			Expect(log.Message).To(Equal("bbs.request.done"))
+			Expect(log.Session).To(Equal("418"))
+			Expect(log.Error).To(MatchError("boom"))
+			Expect(log.Trace).To(Equal("stack"))
+			Expect(log.Data).To(Equal(lager.Data{"request": "/v1/ping"}))
+		})
+	})
+
+	Context("with the newer lager format", func() {
+		BeforeEach(func() {
+			chugString(`{"timestamp":"2016-10-13T20:40:00.0125Z","level":"info","source":"bbs","message":"bbs.request.done","data":{"session":"418"}}`)
+		})
+
+		It("parses RFC3339 timestamps and string levels", func() {
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].IsLager).To(BeTrue())
+			Expect(entries[0].Log.Timestamp.Equal(time.Unix(1476391200, 12500000))).To(BeTrue())
+			Expect(entries[0].Log.LogLevel).To(Equal(lager.INFO))
+			Expect(entries[0].Log.Session).To(Equal("418"))
+		})
+	})
+
+	Context("with a mixed stream", func() {
+		BeforeEach(func() {
+			chugString(strings.Join([]string{
+				`{"timestamp":"1476391200.000000000","source":"bbs","message":"bbs.a","log_level":0,"data":{}}`,
+				`not a lager line`,
+				`2016-10-13T20:40:00Z rep[42]: {"timestamp":"2016-10-13T20:40:01Z","level":"debug","source":"rep","message":"rep.b","data":{}}`,
+				`{"timestamp":"1476391202","source":"bbs","message":"bbs.c","log_level":1}`,
+				`{"timestamp":"yesterday","level":"info","source":"bbs","message":"bbs.d","data":{}}`,
+				`{"timestamp":"2016-10-13T20:40:01Z","level":"loud","source":"bbs","message":"bbs.e","data":{}}`,
+			}, "\n"))
+		})
+
+		It("parses every lager line and keeps the rest raw", func() {
+			Expect(entries).To(HaveLen(6))
+
+			Expect(entries[0].IsLager).To(BeTrue())
+			Expect(entries[0].Log.Message).To(Equal("bbs.a"))
+
+			Expect(entries[1].IsLager).To(BeFalse())
+			Expect(string(entries[1].Raw)).To(Equal("not a lager line"))
+
+			Expect(entries[2].IsLager).To(BeTrue())
+			Expect(entries[2].Log.Message).To(Equal("rep.b"))
+			Expect(entries[2].Log.LogLevel).To(Equal(lager.DEBUG))
+
+			Expect(entries[3].IsLager).To(BeTrue())
+			Expect(entries[3].Log.Timestamp).To(Equal(time.Unix(1476391202, 0)))
+			Expect(entries[3].Log.Data).To(BeEmpty())
+
+			Expect(entries[4].IsLager).To(BeFalse())
+			Expect(entries[5].IsLager).To(BeFalse())
+		})
+	})
+})
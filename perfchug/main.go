@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"sort"
 	"strings"
 
 	"code.cloudfoundry.org/lager/chug"
@@ -11,37 +15,75 @@ import (
 
 const metricPrefix = "cf.diego."
 
+var (
+	maxPending = flag.Int("max-pending", 0, "max number of pending entries kept per mapper, split between its workers, 0 for unbounded")
+	pendingTTL = flag.Duration("pending-ttl", 0, "max age of a pending entry relative to later log timestamps, 0 for unbounded")
+	workers    = flag.Int("workers", runtime.NumCPU(), "number of goroutines mapping log entries")
+)
+
 func main() {
 	flag.Parse()
 
-	chugOut := make(chan chug.Entry, 1)
-	go chug.Chug(os.Stdin, chugOut)
+	chugOut := make(chan chug.Entry, shardBufferSize)
+	go chugLogs(os.Stdin, chugOut)
 
-	metrics := make(chan Metric)
+	metrics := make(chan Metric, shardBufferSize)
+	written := make(chan error)
 
 	go func() {
-		for metric := range metrics {
-			tags := mapToTags(metric.Tags)
-			fmt.Printf("%s%s,%s value=%s %d\n",
-				metricPrefix,
-				metric.Name,
-				strings.Join(tags, ","),
-				metric.Value,
-				metric.Timestamp.UnixNano(),
-			)
-		}
+		written <- writeMetrics(os.Stdout, metrics)
 	}()
 
-	mapAll(chugOut, metrics,
+	mappers := defaultMappers()
+	for _, mapper := range mappers {
+		mapper.setPendingDefaults(*maxPending, *pendingTTL)
+	}
+
+	mapAll(chugOut, metrics, *workers, mappers...)
+	close(metrics)
+
+	if err := <-written; err != nil {
+		fmt.Fprintf(os.Stderr, "failed writing metrics: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// defaultMappers lists every mapper perfchug runs. Each one needs a fixture
+// under testdata/<mapper name>/ for the golden tests.
+func defaultMappers() []EntryMapper {
+	return []EntryMapper{
 		RequestLatencyMapper,
 		AuctionSchedulingMapper,
 		TaskLifecycleMapper,
 		LRPLifecycleMapper,
+		LRPPlacementMapper,
 		CedarSuccessfulPushMapper,
 		CedarFailedPushMapper,
 		CedarSuccessfulStartMapper,
 		CedarFailedStartMapper,
-	)
+	}
+}
+
+func writeMetrics(w io.Writer, metrics <-chan Metric) error {
+	out := bufio.NewWriter(w)
+	var err error
+	for metric := range metrics {
+		if err != nil {
+			continue
+		}
+		tags := mapToTags(metric.Tags)
+		_, err = fmt.Fprintf(out, "%s%s,%s value=%s %d\n",
+			metricPrefix,
+			metric.Name,
+			strings.Join(tags, ","),
+			metric.Value,
+			metric.Timestamp.UnixNano(),
+		)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Flush()
 }
 
 func mapToTags(m map[string]string) []string {
@@ -49,5 +91,6 @@ func mapToTags(m map[string]string) []string {
 	for key, value := range m {
 		tags = append(tags, fmt.Sprintf("%s=%s", key, value))
 	}
+	sort.Strings(tags)
 	return tags
 }
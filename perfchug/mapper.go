@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"hash/fnv"
 	"os"
 	"strings"
 	"sync"
@@ -18,6 +19,21 @@ type Metric struct {
 	Timestamp time.Time
 }
 
+// EntryMapper turns a stream of lager entries into metrics. match reports
+// whether an entry is relevant to the mapper, along with its key and which of
+// the mapper's stages it belongs to; entries sharing a key are always passed
+// to processEntry in log order, with the index of the shard handling them.
+// setShards is called with the number of shards before any entry is passed.
+type EntryMapper interface {
+	match(entry chug.Entry) (key string, stage int, ok bool)
+	processEntry(shard int, entry chug.Entry, key string, stage int, metrics chan<- Metric)
+	mapperName() string
+	metricsCount() int64
+	expiredCount() int64
+	setPendingDefaults(maxPending int, ttl time.Duration)
+	setShards(shards int)
+}
+
 type Mapper struct {
 	Name string
 
@@ -26,70 +42,178 @@ type Mapper struct {
 	Transform   func(s, e chug.Entry) Metric
 	GetKey      func(entry chug.Entry) (string, error)
 
-	entriesMap map[string]chug.Entry
-	mutex      sync.Mutex
+	// MaxPending and PendingTTL bound the start entries kept while waiting
+	// for their end entry. Zero means unbounded.
+	MaxPending int
+	PendingTTL time.Duration
+
+	entries shardedPending
+	mutex   sync.Mutex
 
 	metricsFound int64
 }
 
-func (m *Mapper) processEntry(entry chug.Entry, metrics chan<- Metric) {
-	if strings.Contains(entry.Log.Message, m.StartString) {
-		key, err := m.GetKey(entry)
-		if err != nil {
-			return
-		}
-		m.setEntry(key, &entry)
-		return
+const (
+	startStage = iota
+	endStage
+)
+
+func (m *Mapper) match(entry chug.Entry) (string, int, bool) {
+	var stage int
+	switch {
+	case strings.Contains(entry.Log.Message, m.StartString):
+		stage = startStage
+	case strings.Contains(entry.Log.Message, m.EndString):
+		stage = endStage
+	default:
+		return "", 0, false
 	}
 
-	if strings.Contains(entry.Log.Message, m.EndString) {
-		key, err := m.GetKey(entry)
-		if err != nil {
-			return
-		}
-		startEntry := m.getEntry(key)
-		if startEntry != nil {
-			metrics <- m.Transform(*startEntry, entry)
-			m.setEntry(key, nil)
-			atomic.AddInt64(&m.metricsFound, 1)
-		}
+	key, err := m.GetKey(entry)
+	if err != nil {
+		return "", 0, false
+	}
+	return key, stage, true
+}
+
+func (m *Mapper) processEntry(shard int, entry chug.Entry, key string, stage int, metrics chan<- Metric) {
+	if stage == startStage {
+		m.setEntry(shard, key, &entry)
 		return
 	}
+
+	startEntry := m.getEntry(shard, key, entry.Log.Timestamp)
+	if startEntry != nil {
+		metrics <- m.Transform(*startEntry, entry)
+		m.setEntry(shard, key, nil)
+		atomic.AddInt64(&m.metricsFound, 1)
+	}
+}
+
+func (m *Mapper) mapperName() string {
+	return m.Name
+}
+
+func (m *Mapper) metricsCount() int64 {
+	return atomic.LoadInt64(&m.metricsFound)
+}
+
+func (m *Mapper) expiredCount() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.entries.expired()
+}
+
+func (m *Mapper) setPendingDefaults(maxPending int, ttl time.Duration) {
+	if m.MaxPending == 0 {
+		m.MaxPending = maxPending
+	}
+	if m.PendingTTL == 0 {
+		m.PendingTTL = ttl
+	}
+}
+
+func (m *Mapper) setShards(shards int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.entries.setShards(shards)
 }
 
-func (m *Mapper) setEntry(key string, entry *chug.Entry) {
+func (m *Mapper) setEntry(shard int, key string, entry *chug.Entry) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	if entry == nil {
-		delete(m.entriesMap, key)
+		m.entries.shard(shard).remove(key)
 	} else {
-		m.entriesMap[key] = *entry
+		m.entries.shard(shard).set(key, entry.Log.Timestamp, *entry, m.entries.maxPending(m.MaxPending), m.PendingTTL)
 	}
 }
 
-func (m *Mapper) getEntry(key string) *chug.Entry {
+func (m *Mapper) getEntry(shard int, key string, now time.Time) *chug.Entry {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if entry, ok := m.entriesMap[key]; ok {
+	if entry, ok := m.entries.shard(shard).get(key, now, m.PendingTTL).(chug.Entry); ok {
 		return &entry
 	}
 	return nil
 }
 
-func mapAll(in <-chan chug.Entry, metrics chan<- Metric, mappers ...*Mapper) {
+type mapperJob struct {
+	shard  int
+	mapper EntryMapper
+	entry  chug.Entry
+	key    string
+	stage  int
+}
+
+const shardBufferSize = 1024
+
+// mapAll runs every entry through every mapper using the given number of
+// workers. Work is sharded by mapper and key, so entries for the same key are
+// always processed in order by the same worker.
+func mapAll(in <-chan chug.Entry, metrics chan<- Metric, workers int, mappers ...EntryMapper) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	for _, mapper := range mappers {
+		mapper.setShards(workers)
+	}
+
+	wg := &sync.WaitGroup{}
+	shards := make([]chan mapperJob, workers)
+	for i := range shards {
+		shards[i] = make(chan mapperJob, shardBufferSize)
+
+		wg.Add(1)
+		go func(jobs <-chan mapperJob) {
+			defer wg.Done()
+			for job := range jobs {
+				job.mapper.processEntry(job.shard, job.entry, job.key, job.stage, metrics)
+			}
+		}(shards[i])
+	}
+
 	for entry := range in {
-		for _, mapper := range mappers {
-			mapper.processEntry(entry, metrics)
+		for i, mapper := range mappers {
+			key, stage, ok := mapper.match(entry)
+			if !ok {
+				continue
+			}
+			shard := shardIndex(i, key, workers)
+			shards[shard] <- mapperJob{
+				shard:  shard,
+				mapper: mapper,
+				entry:  entry,
+				key:    key,
+				stage:  stage,
+			}
 		}
 	}
 
+	for _, shard := range shards {
+		close(shard)
+	}
+	wg.Wait()
+
 	for _, mapper := range mappers {
-		if mapper.metricsFound == 0 {
-			fmt.Fprintf(os.Stderr, "no metrics found for mapper: %s\n", mapper.Name)
+		if found := mapper.metricsCount(); found == 0 {
+			fmt.Fprintf(os.Stderr, "no metrics found for mapper: %s\n", mapper.mapperName())
 		} else {
-			fmt.Fprintf(os.Stderr, "found %d metrics for mapper: %s\n", mapper.metricsFound, mapper.Name)
+			fmt.Fprintf(os.Stderr, "found %d metrics for mapper: %s\n", found, mapper.mapperName())
+		}
+		if expired := mapper.expiredCount(); expired > 0 {
+			fmt.Fprintf(os.Stderr, "expired %d pending entries for mapper: %s\n", expired, mapper.mapperName())
 		}
 	}
 }
+
+func shardIndex(mapperIndex int, key string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int((h.Sum32() + uint32(mapperIndex)) % uint32(shards))
+}
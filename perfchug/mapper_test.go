@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"testing"
+
+	"code.cloudfoundry.org/lager/chug"
+)
+
+func BenchmarkMapAllSerial(b *testing.B) {
+	benchmarkMapAll(b, 1)
+}
+
+func BenchmarkMapAllParallel(b *testing.B) {
+	benchmarkMapAll(b, runtime.NumCPU())
+}
+
+func benchmarkMapAll(b *testing.B, workers int) {
+	logs := benchmarkLogs(10000)
+	b.SetBytes(int64(len(logs)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		entries := make(chan chug.Entry, shardBufferSize)
+		metrics := make(chan Metric, shardBufferSize)
+		written := make(chan error)
+
+		go chugLogs(bytes.NewReader(logs), entries)
+		go func() {
+			written <- writeMetrics(ioutil.Discard, metrics)
+		}()
+
+		mapAll(entries, metrics, workers,
+			copyMapper(RequestLatencyMapper),
+			copyMapper(TaskLifecycleMapper),
+			copyMapper(LRPLifecycleMapper),
+			copySpanMapper(LRPPlacementMapper),
+		)
+		close(metrics)
+
+		if err := <-written; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkLogs generates count interleaved request, task and LRP lifecycles
+// in the legacy lager format.
+func benchmarkLogs(count int) []byte {
+	buf := &bytes.Buffer{}
+	line := func(ts int, message, data string) {
+		fmt.Fprintf(buf, `{"timestamp":"%d.000000000","source":"bbs","message":"%s","log_level":1,"data":{%s}}`+"\n", ts, message, data)
+	}
+
+	for i := 0; i < count; i++ {
+		request := fmt.Sprintf(`"session":"%d","request":"/v1/actual_lrps/list"`, i)
+		task := fmt.Sprintf(`"task_guid":"task-%d"`, i)
+		lrp := fmt.Sprintf(`"actual_lrp_key":{"process_guid":"lrp-%d","index":0}`, i)
+
+		line(i, "bbs.request.serving", request)
+		line(i, "bbs.desire-task.starting", task)
+		line(i, "bbs.create-unclaimed-actual-lrp.starting", lrp)
+		line(i+1, "bbs.claim-actual-lrp.complete", lrp)
+		line(i+2, "bbs.request.done", request)
+		line(i+3, "bbs.start-actual-lrp.complete", lrp)
+		line(i+4, "bbs.complete-task.complete", task)
+	}
+	return buf.Bytes()
+}
+
+// freshMapper returns an unused copy of one of the package-level mappers so
+// tests don't share pending entries or counters.
+func freshMapper(mapper EntryMapper) EntryMapper {
+	switch m := mapper.(type) {
+	case *Mapper:
+		return copyMapper(m)
+	case *SpanMapper:
+		return copySpanMapper(m)
+	default:
+		panic(fmt.Sprintf("unknown mapper type %T", mapper))
+	}
+}
+
+func copyMapper(m *Mapper) *Mapper {
+	return &Mapper{
+		Name:        m.Name,
+		StartString: m.StartString,
+		EndString:   m.EndString,
+		Transform:   m.Transform,
+		GetKey:      m.GetKey,
+		MaxPending:  m.MaxPending,
+		PendingTTL:  m.PendingTTL,
+	}
+}
+
+func copySpanMapper(m *SpanMapper) *SpanMapper {
+	return &SpanMapper{
+		Name:       m.Name,
+		MetricName: m.MetricName,
+		Stages:     m.Stages,
+		GetKey:     m.GetKey,
+		GetGroup:   m.GetGroup,
+		GetTags:    m.GetTags,
+		MaxPending: m.MaxPending,
+		PendingTTL: m.PendingTTL,
+	}
+}
@@ -34,8 +34,6 @@ var RequestLatencyMapper = &Mapper{
 		}
 		return fmt.Sprintf("%s:%s", entry.Log.Data["request"], entry.Log.Session), nil
 	},
-
-	entriesMap: make(map[string]chug.Entry),
 }
 
 var AuctionSchedulingMapper = &Mapper{
@@ -60,8 +58,6 @@ var AuctionSchedulingMapper = &Mapper{
 	GetKey: func(entry chug.Entry) (string, error) {
 		return entry.Log.Session, nil
 	},
-
-	entriesMap: make(map[string]chug.Entry),
 }
 
 var TaskLifecycleMapper = &Mapper{
@@ -90,8 +86,6 @@ var TaskLifecycleMapper = &Mapper{
 		}
 		return fmt.Sprint(entry.Log.Data["task_guid"]), nil
 	},
-
-	entriesMap: make(map[string]chug.Entry),
 }
 
 var LRPLifecycleMapper = &Mapper{
@@ -131,8 +125,6 @@ var LRPLifecycleMapper = &Mapper{
 		}
 		return fmt.Sprintf("%s:%v", keyData["process_guid"], keyData["index"]), nil
 	},
-
-	entriesMap: make(map[string]chug.Entry),
 }
 
 var CedarSuccessfulPushMapper = &Mapper{
@@ -165,8 +157,6 @@ var CedarSuccessfulPushMapper = &Mapper{
 		}
 		return fmt.Sprint(entry.Log.Data["app"]), nil
 	},
-
-	entriesMap: make(map[string]chug.Entry),
 }
 
 var CedarFailedPushMapper = &Mapper{
@@ -198,8 +188,6 @@ var CedarFailedPushMapper = &Mapper{
 		}
 		return fmt.Sprint(entry.Log.Data["app"]), nil
 	},
-
-	entriesMap: make(map[string]chug.Entry),
 }
 
 var CedarSuccessfulStartMapper = &Mapper{
@@ -231,8 +219,6 @@ var CedarSuccessfulStartMapper = &Mapper{
 		}
 		return fmt.Sprint(entry.Log.Data["app"]), nil
 	},
-
-	entriesMap: make(map[string]chug.Entry),
 }
 
 var CedarFailedStartMapper = &Mapper{
@@ -264,6 +250,56 @@ var CedarFailedStartMapper = &Mapper{
 		}
 		return fmt.Sprint(entry.Log.Data["app"]), nil
 	},
+}
+
+var LRPPlacementMapper = &SpanMapper{
+	Name:       "LRPPlacementMapper",
+	MetricName: "LRPPlacement",
+
+	Stages: []Stage{
+		{Name: "desired", String: "desire-lrp.complete"},
+		{Name: "unclaimed", String: "create-unclaimed-actual-lrp.starting"},
+		{Name: "scheduled", String: "auction-perform-work.lrp-allocate-instances"},
+		{Name: "claimed", String: "claim-actual-lrp.complete"},
+		{Name: "container-created", String: "created-container"},
+		{Name: "healthy", String: "transitioned-to-healthy"},
+		{Name: "running", String: "start-actual-lrp.complete"},
+	},
+
+	GetTags: func(entry chug.Entry) map[string]string {
+		processGuid, index := lrpKeyData(entry)
+		return map[string]string{
+			"component":    strings.Split(entry.Log.Message, ".")[0],
+			"process_guid": fmt.Sprint(processGuid),
+			"index":        fmt.Sprint(index),
+		}
+	},
+
+	GetKey: func(entry chug.Entry) (string, error) {
+		processGuid, index := lrpKeyData(entry)
+		if processGuid == nil || index == nil {
+			return "", fmt.Errorf("not an LRP log line")
+		}
+		return fmt.Sprintf("%s:%v", processGuid, index), nil
+	},
 
-	entriesMap: make(map[string]chug.Entry),
+	GetGroup: func(entry chug.Entry) (string, error) {
+		processGuid, _ := lrpKeyData(entry)
+		if processGuid == nil {
+			return "", fmt.Errorf("not an LRP log line")
+		}
+		return fmt.Sprint(processGuid), nil
+	},
+}
+
+// lrpKeyData finds the process guid and index of an LRP log line, which is
+// logged either as an actual_lrp_key, as the rep's lrp-key or as top-level
+// fields. Desired LRP lines only carry the process guid.
+func lrpKeyData(entry chug.Entry) (interface{}, interface{}) {
+	for _, field := range []string{"actual_lrp_key", "lrp-key"} {
+		if keyData, ok := entry.Log.Data[field].(map[string]interface{}); ok {
+			return keyData["process_guid"], keyData["index"]
+		}
+	}
+	return entry.Log.Data["process_guid"], entry.Log.Data["index"]
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager/chug"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden output of every mapper fixture")
+
+// parallelWorkers is fixed rather than taken from the number of CPUs so that
+// fixtures are always spread over several shards.
+const parallelWorkers = 4
+
+// runMappers maps logs and returns the metrics perfchug would print.
+func runMappers(logs []byte, workers int, mappers ...EntryMapper) string {
+	entries := make(chan chug.Entry, shardBufferSize)
+	metrics := make(chan Metric, shardBufferSize)
+	written := make(chan error)
+	output := &bytes.Buffer{}
+
+	go chugLogs(bytes.NewReader(logs), entries)
+	go func() {
+		written <- writeMetrics(output, metrics)
+	}()
+
+	mapAll(entries, metrics, workers, mappers...)
+	close(metrics)
+
+	Expect(<-written).To(Succeed())
+	return output.String()
+}
+
+func sortedLines(output string) []string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+// Every mapper returned by defaultMappers registers its fixture by providing
+// testdata/<mapper name>/input.log. The expected metrics live next to it in
+// output.golden and can be regenerated with `go test -update`.
+var _ = Describe("Mappers", func() {
+	for _, mapper := range defaultMappers() {
+		name := mapper.mapperName()
+		mapper := mapper
+
+		Describe(name, func() {
+			var (
+				fixtureDir string
+				logs       []byte
+			)
+
+			BeforeEach(func() {
+				var err error
+				fixtureDir = filepath.Join("testdata", name)
+				logs, err = ioutil.ReadFile(filepath.Join(fixtureDir, "input.log"))
+				Expect(err).NotTo(HaveOccurred(), "mapper %s has no fixture in %s", name, fixtureDir)
+			})
+
+			It("maps its fixture to the golden output", func() {
+				output := runMappers(logs, 1, freshMapper(mapper))
+				Expect(output).NotTo(BeEmpty())
+
+				goldenFile := filepath.Join(fixtureDir, "output.golden")
+				if *updateGolden {
+					Expect(ioutil.WriteFile(goldenFile, []byte(output), 0644)).To(Succeed())
+				}
+
+				golden, err := ioutil.ReadFile(goldenFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output).To(Equal(string(golden)))
+			})
+
+			It("maps the same metrics when run with several workers", func() {
+				serial := runMappers(logs, 1, freshMapper(mapper))
+				parallel := runMappers(logs, parallelWorkers, freshMapper(mapper))
+				Expect(sortedLines(parallel)).To(Equal(sortedLines(serial)))
+			})
+		})
+	}
+})
+
+var _ = Describe("shardIndex", func() {
+	It("spreads keys over every shard", func() {
+		used := map[int]bool{}
+		for i := 0; i < 100; i++ {
+			shard := shardIndex(0, fmt.Sprintf("key-%d", i), parallelWorkers)
+			Expect(shard).To(BeNumerically(">=", 0))
+			Expect(shard).To(BeNumerically("<", parallelWorkers))
+			used[shard] = true
+		}
+		Expect(used).To(HaveLen(parallelWorkers))
+	})
+
+	It("keeps a key on the same shard", func() {
+		Expect(shardIndex(1, "key", parallelWorkers)).To(Equal(shardIndex(1, "key", parallelWorkers)))
+	})
+})
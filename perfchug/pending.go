@@ -0,0 +1,156 @@
+package main
+
+import (
+	"container/list"
+	"time"
+)
+
+// pendingEntries holds the in-flight values of one shard of a mapper in
+// timestamp order so the oldest ones can be evicted once the shard exceeds its
+// maximum number of pending entries or they fall outside its TTL. Ages are
+// measured against the newest log timestamp the shard has seen rather than
+// wall-clock time, as entries may still be handed over out of order. It is not
+// safe for concurrent use; callers are expected to hold their mapper's lock.
+type pendingEntries struct {
+	entries map[string]*list.Element
+	order   *list.List
+	newest  time.Time
+
+	// onEvict, when set, is called with every entry that is evicted rather
+	// than removed.
+	onEvict func(key string, value interface{})
+	expired int64
+}
+
+type pendingEntry struct {
+	key       string
+	timestamp time.Time
+	value     interface{}
+}
+
+// shardedPending keeps a separate pendingEntries for every worker shard. A
+// shard that lags behind the others would otherwise have its fresh entries
+// aged against timestamps that only a faster shard has reached.
+type shardedPending struct {
+	shards []*pendingEntries
+
+	// onEvict, when set, is called with every entry evicted from a shard.
+	onEvict func(shard int, key string, value interface{})
+}
+
+// setShards prepares count shards, dropping any previous entries.
+func (s *shardedPending) setShards(count int) {
+	s.shards = nil
+	s.shard(count - 1)
+}
+
+func (s *shardedPending) shard(index int) *pendingEntries {
+	for len(s.shards) <= index {
+		shard := len(s.shards)
+		pending := &pendingEntries{}
+		if s.onEvict != nil {
+			pending.onEvict = func(key string, value interface{}) {
+				s.onEvict(shard, key, value)
+			}
+		}
+		s.shards = append(s.shards, pending)
+	}
+	return s.shards[index]
+}
+
+// maxPending splits a mapper's maximum number of pending entries between its
+// shards, rounding up so that no shard is left without room.
+func (s *shardedPending) maxPending(maxPending int) int {
+	if maxPending <= 0 || len(s.shards) <= 1 {
+		return maxPending
+	}
+	return (maxPending + len(s.shards) - 1) / len(s.shards)
+}
+
+func (s *shardedPending) expired() int64 {
+	var expired int64
+	for _, shard := range s.shards {
+		expired += shard.expired
+	}
+	return expired
+}
+
+func (p *pendingEntries) init() {
+	if p.entries == nil {
+		p.entries = make(map[string]*list.Element)
+		p.order = list.New()
+	}
+}
+
+// set stores value under key, replacing any previous value, then evicts
+// entries that are over the limits.
+func (p *pendingEntries) set(key string, timestamp time.Time, value interface{}, maxPending int, ttl time.Duration) {
+	p.init()
+	p.remove(key)
+	if timestamp.After(p.newest) {
+		p.newest = timestamp
+	}
+
+	// Entries mostly arrive in order, so the place of a new one is found by
+	// walking back from the newest.
+	entry := &pendingEntry{key: key, timestamp: timestamp, value: value}
+	mark := p.order.Back()
+	for mark != nil && mark.Value.(*pendingEntry).timestamp.After(timestamp) {
+		mark = mark.Prev()
+	}
+	if mark == nil {
+		p.entries[key] = p.order.PushFront(entry)
+	} else {
+		p.entries[key] = p.order.InsertAfter(entry, mark)
+	}
+	p.evict(p.newest, maxPending, ttl)
+}
+
+// get returns the value stored under key, or nil if there is none. A value
+// that is older than ttl relative to now is evicted instead of returned.
+func (p *pendingEntries) get(key string, now time.Time, ttl time.Duration) interface{} {
+	p.init()
+	elem, ok := p.entries[key]
+	if !ok {
+		return nil
+	}
+
+	entry := elem.Value.(*pendingEntry)
+	if ttl > 0 && now.Sub(entry.timestamp) > ttl {
+		p.remove(key)
+		p.expired++
+		p.evicted(entry)
+		return nil
+	}
+	return entry.value
+}
+
+func (p *pendingEntries) remove(key string) {
+	p.init()
+	if elem, ok := p.entries[key]; ok {
+		p.order.Remove(elem)
+		delete(p.entries, key)
+	}
+}
+
+func (p *pendingEntries) evict(now time.Time, maxPending int, ttl time.Duration) {
+	for front := p.order.Front(); front != nil; front = p.order.Front() {
+		entry := front.Value.(*pendingEntry)
+		overLimit := maxPending > 0 && p.order.Len() > maxPending
+		tooOld := ttl > 0 && now.Sub(entry.timestamp) > ttl
+		if !overLimit && !tooOld {
+			return
+		}
+
+		p.order.Remove(front)
+		delete(p.entries, entry.key)
+		p.expired++
+		p.evicted(entry)
+	}
+}
+
+func (p *pendingEntries) evicted(entry *pendingEntry) {
+	if p.onEvict != nil {
+		p.onEvict(entry.key, entry.value)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/chug"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("pendingEntries", func() {
+	var (
+		pending pendingEntries
+		start   time.Time
+	)
+
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	BeforeEach(func() {
+		pending = pendingEntries{}
+		start = time.Unix(1476391200, 0)
+	})
+
+	It("returns what was set until it is removed", func() {
+		pending.set("a", at(0), "value", 0, 0)
+		Expect(pending.get("a", at(1), 0)).To(Equal("value"))
+
+		pending.remove("a")
+		Expect(pending.get("a", at(1), 0)).To(BeNil())
+		Expect(pending.expired).To(BeZero())
+	})
+
+	Context("with a max number of pending entries", func() {
+		It("evicts the oldest entries", func() {
+			for i, key := range []string{"a", "b", "c", "d"} {
+				pending.set(key, at(i), key, 2, 0)
+			}
+
+			Expect(pending.get("a", at(4), 0)).To(BeNil())
+			Expect(pending.get("b", at(4), 0)).To(BeNil())
+			Expect(pending.get("c", at(4), 0)).To(Equal("c"))
+			Expect(pending.get("d", at(4), 0)).To(Equal("d"))
+			Expect(pending.expired).To(BeEquivalentTo(2))
+		})
+
+		It("evicts the entries with the oldest timestamps", func() {
+			pending.set("b", at(1), "b", 2, 0)
+			pending.set("a", at(0), "a", 2, 0)
+			pending.set("c", at(2), "c", 2, 0)
+
+			Expect(pending.get("a", at(2), 0)).To(BeNil())
+			Expect(pending.get("b", at(2), 0)).To(Equal("b"))
+			Expect(pending.get("c", at(2), 0)).To(Equal("c"))
+		})
+
+		It("does not count replacing a key as an eviction", func() {
+			pending.set("a", at(0), "first", 1, 0)
+			pending.set("a", at(1), "second", 1, 0)
+
+			Expect(pending.get("a", at(1), 0)).To(Equal("second"))
+			Expect(pending.expired).To(BeZero())
+		})
+	})
+
+	Context("with a TTL", func() {
+		It("evicts entries older than the TTL when new entries arrive", func() {
+			pending.set("a", at(0), "a", 0, 5*time.Second)
+			pending.set("b", at(3), "b", 0, 5*time.Second)
+			pending.set("c", at(7), "c", 0, 5*time.Second)
+
+			Expect(pending.entries).To(HaveLen(2))
+			Expect(pending.expired).To(BeEquivalentTo(1))
+		})
+
+		It("measures ages against the newest timestamp when entries arrive out of order", func() {
+			pending.set("b", at(10), "b", 0, 5*time.Second)
+			pending.set("a", at(0), "a", 0, 5*time.Second)
+
+			Expect(pending.get("b", at(10), 5*time.Second)).To(Equal("b"))
+			Expect(pending.entries).To(HaveLen(1))
+			Expect(pending.expired).To(BeEquivalentTo(1))
+		})
+
+		It("evicts old entries that arrived after newer ones", func() {
+			pending.set("b", at(4), "b", 0, 5*time.Second)
+			pending.set("a", at(0), "a", 0, 5*time.Second)
+			pending.set("c", at(7), "c", 0, 5*time.Second)
+
+			Expect(pending.get("a", at(7), 0)).To(BeNil())
+			Expect(pending.get("b", at(7), 0)).To(Equal("b"))
+			Expect(pending.get("c", at(7), 0)).To(Equal("c"))
+			Expect(pending.expired).To(BeEquivalentTo(1))
+		})
+
+		It("expires an entry that is looked up too late", func() {
+			pending.set("a", at(0), "a", 0, 5*time.Second)
+
+			Expect(pending.get("a", at(6), 5*time.Second)).To(BeNil())
+			Expect(pending.entries).To(BeEmpty())
+			Expect(pending.expired).To(BeEquivalentTo(1))
+		})
+	})
+})
+
+var _ = Describe("shardedPending", func() {
+	var (
+		mapper  *Mapper
+		metrics chan Metric
+		start   time.Time
+	)
+
+	entry := func(message, key string, seconds int) chug.Entry {
+		return chug.Entry{
+			IsLager: true,
+			Log: chug.LogEntry{
+				Timestamp: start.Add(time.Duration(seconds) * time.Second),
+				Message:   message,
+				Data:      lager.Data{"key": key},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		start = time.Unix(1476391200, 0)
+		metrics = make(chan Metric, 100)
+		mapper = &Mapper{
+			Name:        "TestMapper",
+			StartString: "start",
+			EndString:   "end",
+			Transform: func(s, e chug.Entry) Metric {
+				return Metric{Name: "Test", Value: fmt.Sprint(e.Log.Timestamp.Sub(s.Log.Timestamp))}
+			},
+			GetKey: func(entry chug.Entry) (string, error) {
+				return fmt.Sprint(entry.Log.Data["key"]), nil
+			},
+			PendingTTL: 5 * time.Second,
+		}
+		mapper.setShards(2)
+	})
+
+	It("ages the entries of a lagging shard against its own timestamps", func() {
+		mapper.processEntry(0, entry("start", "a", 0), "a", startStage, metrics)
+		mapper.processEntry(1, entry("start", "b", 100), "b", startStage, metrics)
+		mapper.processEntry(0, entry("start", "c", 1), "c", startStage, metrics)
+		mapper.processEntry(0, entry("end", "a", 2), "a", endStage, metrics)
+
+		Expect(metrics).To(Receive(Equal(Metric{Name: "Test", Value: "2s"})))
+		Expect(mapper.expiredCount()).To(BeZero())
+	})
+
+	It("expires entries within a shard and sums the shards' expired counts", func() {
+		mapper.processEntry(0, entry("start", "a", 0), "a", startStage, metrics)
+		mapper.processEntry(0, entry("start", "b", 10), "b", startStage, metrics)
+		mapper.processEntry(1, entry("start", "c", 0), "c", startStage, metrics)
+		mapper.processEntry(1, entry("start", "d", 10), "d", startStage, metrics)
+
+		Expect(mapper.expiredCount()).To(BeEquivalentTo(2))
+	})
+
+	It("splits the max number of pending entries between the shards", func() {
+		pending := shardedPending{}
+		pending.setShards(4)
+		Expect(pending.maxPending(0)).To(Equal(0))
+		Expect(pending.maxPending(10)).To(Equal(3))
+		Expect(pending.maxPending(2)).To(Equal(1))
+
+		pending.setShards(1)
+		Expect(pending.maxPending(10)).To(Equal(10))
+	})
+
+	It("maps every pair within the TTL when run with several workers", func() {
+		logs := []byte{}
+		for i := 0; i < 200; i++ {
+			logs = append(logs, fmt.Sprintf(`{"timestamp":"%d.000000000","source":"test","message":"test.start","log_level":1,"data":{"key":"key-%d"}}`+"\n", 1476391200+i, i)...)
+			logs = append(logs, fmt.Sprintf(`{"timestamp":"%d.000000000","source":"test","message":"test.end","log_level":1,"data":{"key":"key-%d"}}`+"\n", 1476391201+i, i)...)
+		}
+
+		output := runMappers(logs, parallelWorkers, mapper)
+		Expect(sortedLines(output)).To(HaveLen(200))
+		Expect(mapper.expiredCount()).To(BeZero())
+	})
+})
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager/chug"
+)
+
+// Stage is a single step of a SpanMapper, matched by a substring of the log
+// message.
+type Stage struct {
+	Name   string
+	String string
+}
+
+// SpanMapper tracks an ordered sequence of stages per key. Each time a key
+// moves forward to a later stage a metric named "<MetricName>.<stage>" is
+// emitted with the time spent since the previous stage; reaching the last
+// stage also emits "<MetricName>" with the end-to-end duration. Stages that
+// never show up for a key are skipped over, and a repeated first stage
+// restarts the span.
+//
+// When GetGroup is set the first stage is logged once for a group of keys,
+// such as a desired LRP and its instances, and is keyed by the group. A key
+// starts from a copy of its group's span the first time it shows up, or at
+// the second stage when its group's first stage was not seen. The group is
+// dropped once the last span started from it completes or is evicted, and a
+// repeated first stage drops the spans started from the previous one. Entries
+// are sharded by group so that they are processed in log order.
+type SpanMapper struct {
+	Name       string
+	MetricName string
+
+	Stages   []Stage
+	GetKey   func(entry chug.Entry) (string, error)
+	GetGroup func(entry chug.Entry) (string, error)
+	GetTags  func(entry chug.Entry) map[string]string
+
+	// MaxPending and PendingTTL bound the spans kept while waiting for
+	// their last stage. Zero means unbounded.
+	MaxPending int
+	PendingTTL time.Duration
+
+	spans  shardedPending
+	groups shardedPending
+	mutex  sync.Mutex
+
+	metricsFound int64
+}
+
+// span tracks a key's progress; tagged is the first entry carrying the key,
+// which differs from first when the span started from its group's span.
+type span struct {
+	first     chug.Entry
+	last      chug.Entry
+	tagged    chug.Entry
+	lastStage int
+
+	group *spanGroup
+}
+
+// spanGroup is the first stage of a group along with the keys of the spans
+// started from it.
+type spanGroup struct {
+	span
+
+	key  string
+	keys map[string]struct{}
+}
+
+func (m *SpanMapper) match(entry chug.Entry) (string, int, bool) {
+	stage := m.stageIndex(entry.Log.Message)
+	if stage == -1 {
+		return "", 0, false
+	}
+
+	key, err := m.GetKey(entry)
+	if m.GetGroup == nil {
+		return key, stage, err == nil
+	}
+	if err != nil && stage != 0 {
+		return "", 0, false
+	}
+	group, err := m.GetGroup(entry)
+	if err != nil {
+		return "", 0, false
+	}
+	return group, stage, true
+}
+
+func (m *SpanMapper) processEntry(shard int, entry chug.Entry, key string, stage int, metrics chan<- Metric) {
+	group := ""
+	if m.GetGroup != nil {
+		group = key
+		if stage != 0 {
+			key, _ = m.GetKey(entry)
+		}
+	}
+
+	for _, metric := range m.advance(shard, entry, group, key, stage) {
+		metrics <- metric
+		atomic.AddInt64(&m.metricsFound, 1)
+	}
+}
+
+func (m *SpanMapper) advance(shard int, entry chug.Entry, group, key string, stage int) []Metric {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	spans, groups := m.spans.shard(shard), m.groups.shard(shard)
+	maxPending := m.spans.maxPending(m.MaxPending)
+
+	if stage == 0 {
+		if m.GetGroup == nil {
+			spans.set(key, entry.Log.Timestamp, &span{first: entry, last: entry, tagged: entry, lastStage: 0}, maxPending, m.PendingTTL)
+			return nil
+		}
+
+		if g, found := groups.get(key, entry.Log.Timestamp, m.PendingTTL).(*spanGroup); found {
+			for instance := range g.keys {
+				spans.remove(instance)
+			}
+		}
+		g := &spanGroup{span: span{first: entry, last: entry, tagged: entry, lastStage: 0}, key: key, keys: map[string]struct{}{}}
+		groups.set(key, entry.Log.Timestamp, g, maxPending, m.PendingTTL)
+		return nil
+	}
+
+	s, ok := spans.get(key, entry.Log.Timestamp, m.PendingTTL).(*span)
+	if !ok && m.GetGroup != nil {
+		if g, found := groups.get(group, entry.Log.Timestamp, m.PendingTTL).(*spanGroup); found {
+			s, ok = &span{first: g.first, last: g.last, tagged: entry, lastStage: g.lastStage, group: g}, true
+			g.keys[key] = struct{}{}
+			spans.set(key, g.first.Log.Timestamp, s, maxPending, m.PendingTTL)
+		} else if stage == 1 {
+			spans.set(key, entry.Log.Timestamp, &span{first: entry, last: entry, tagged: entry, lastStage: 1}, maxPending, m.PendingTTL)
+			return nil
+		}
+	}
+	if !ok || stage <= s.lastStage {
+		return nil
+	}
+
+	tags := m.GetTags(s.tagged)
+	found := []Metric{
+		m.metric(m.MetricName+"."+m.Stages[stage].Name, s.last, entry, tags, map[string]string{
+			"from": m.Stages[s.lastStage].Name,
+		}),
+	}
+
+	if stage == len(m.Stages)-1 {
+		spans.remove(key)
+		m.release(groups, key, s)
+		return append(found, m.metric(m.MetricName, s.first, entry, tags, nil))
+	}
+
+	s.last = entry
+	s.lastStage = stage
+	return found
+}
+
+// release drops key from the group its span started from, and the group
+// itself once no span started from it is left.
+func (m *SpanMapper) release(groups *pendingEntries, key string, s *span) {
+	g := s.group
+	if g == nil {
+		return
+	}
+
+	delete(g.keys, key)
+	if len(g.keys) == 0 && groups.get(g.key, g.first.Log.Timestamp, 0) == g {
+		groups.remove(g.key)
+	}
+}
+
+func (m *SpanMapper) stageIndex(message string) int {
+	for i, stage := range m.Stages {
+		if strings.Contains(message, stage.String) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *SpanMapper) metric(name string, s, e chug.Entry, tags, extraTags map[string]string) Metric {
+	allTags := make(map[string]string, len(tags)+len(extraTags))
+	for k, v := range tags {
+		allTags[k] = v
+	}
+	for k, v := range extraTags {
+		allTags[k] = v
+	}
+
+	timeDiff := e.Log.Timestamp.Sub(s.Log.Timestamp)
+	return Metric{
+		Name:      name,
+		Tags:      allTags,
+		Value:     strconv.FormatInt(int64(timeDiff), 10),
+		Timestamp: s.Log.Timestamp,
+	}
+}
+
+func (m *SpanMapper) mapperName() string {
+	return m.Name
+}
+
+func (m *SpanMapper) metricsCount() int64 {
+	return atomic.LoadInt64(&m.metricsFound)
+}
+
+func (m *SpanMapper) expiredCount() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.spans.expired() + m.groups.expired()
+}
+
+func (m *SpanMapper) setShards(shards int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.spans.onEvict = func(shard int, key string, value interface{}) {
+		m.release(m.groups.shard(shard), key, value.(*span))
+	}
+	m.spans.setShards(shards)
+	m.groups.setShards(shards)
+}
+
+func (m *SpanMapper) setPendingDefaults(maxPending int, ttl time.Duration) {
+	if m.MaxPending == 0 {
+		m.MaxPending = maxPending
+	}
+	if m.PendingTTL == 0 {
+		m.PendingTTL = ttl
+	}
+}
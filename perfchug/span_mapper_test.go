@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/chug"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SpanMapper", func() {
+	Context("with groups", func() {
+		var (
+			mapper  *SpanMapper
+			metrics chan Metric
+			start   time.Time
+		)
+
+		entry := func(message string, seconds int, data lager.Data) chug.Entry {
+			return chug.Entry{
+				IsLager: true,
+				Log: chug.LogEntry{
+					Timestamp: start.Add(time.Duration(seconds) * time.Second),
+					Message:   message,
+					Data:      data,
+				},
+			}
+		}
+
+		process := func(e chug.Entry) {
+			key, stage, ok := mapper.match(e)
+			Expect(ok).To(BeTrue())
+			mapper.processEntry(0, e, key, stage, metrics)
+		}
+
+		desired := func(seconds int) {
+			process(entry("desired", seconds, lager.Data{"group": "g"}))
+		}
+
+		instance := func(message string, seconds int, index int) {
+			process(entry(message, seconds, lager.Data{"group": "g", "index": index}))
+		}
+
+		BeforeEach(func() {
+			start = time.Unix(1476391200, 0)
+			metrics = make(chan Metric, 100)
+			mapper = &SpanMapper{
+				Name:       "TestSpanMapper",
+				MetricName: "Test",
+				Stages: []Stage{
+					{Name: "desired", String: "desired"},
+					{Name: "started", String: "started"},
+					{Name: "running", String: "running"},
+				},
+				GetKey: func(entry chug.Entry) (string, error) {
+					index, ok := entry.Log.Data["index"]
+					if !ok {
+						return "", fmt.Errorf("no index")
+					}
+					return fmt.Sprintf("%v:%v", entry.Log.Data["group"], index), nil
+				},
+				GetGroup: func(entry chug.Entry) (string, error) {
+					return fmt.Sprint(entry.Log.Data["group"]), nil
+				},
+				GetTags: func(entry chug.Entry) map[string]string {
+					return map[string]string{}
+				},
+				PendingTTL: 10 * time.Second,
+			}
+			mapper.setShards(1)
+		})
+
+		It("drops the group once the last span started from it completes", func() {
+			desired(0)
+			instance("started", 1, 0)
+			instance("started", 1, 1)
+
+			instance("running", 2, 0)
+			Expect(mapper.groups.shard(0).entries).To(HaveLen(1))
+
+			instance("running", 3, 1)
+			Expect(mapper.groups.shard(0).entries).To(BeEmpty())
+			Expect(mapper.spans.shard(0).entries).To(BeEmpty())
+			Expect(mapper.expiredCount()).To(BeZero())
+		})
+
+		It("drops the group once the spans started from it are evicted", func() {
+			desired(0)
+			instance("started", 1, 0)
+
+			process(entry("started", 20, lager.Data{"group": "other", "index": 0}))
+
+			Expect(mapper.groups.shard(0).entries).To(BeEmpty())
+			Expect(mapper.spans.shard(0).entries).To(HaveLen(1))
+			Expect(mapper.expiredCount()).To(BeEquivalentTo(1))
+		})
+
+		It("counts evicted groups as expired", func() {
+			desired(0)
+			process(entry("desired", 20, lager.Data{"group": "other"}))
+
+			Expect(mapper.groups.shard(0).entries).To(HaveLen(1))
+			Expect(mapper.expiredCount()).To(BeEquivalentTo(1))
+		})
+
+		It("drops the spans started from a previous first stage when it repeats", func() {
+			desired(0)
+			instance("started", 1, 0)
+			desired(2)
+
+			Expect(mapper.spans.shard(0).entries).To(BeEmpty())
+
+			instance("started", 3, 0)
+			instance("running", 4, 0)
+
+			names := map[string]string{}
+			for len(metrics) > 0 {
+				metric := <-metrics
+				names[metric.Name] = metric.Value
+			}
+			Expect(names).To(Equal(map[string]string{
+				"Test.started": "1000000000",
+				"Test.running": "1000000000",
+				"Test":         "2000000000",
+			}))
+		})
+	})
+})